@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/antonkazachenko/go-todo-list-api/internal/entities"
+	"github.com/antonkazachenko/go-todo-list-api/internal/service"
+)
+
+func runCommand(name string, args []string, taskService *service.TaskService, backupService *service.BackupService, userService *service.UserService, accessTokenService *service.AccessTokenService) error {
+	switch name {
+	case "backup":
+		return runBackup(args, backupService)
+	case "restore":
+		return runRestore(args, backupService)
+	case "import-todotxt":
+		return runImportTodoTxt(args, taskService, userService)
+	case "export-todotxt":
+		return runExportTodoTxt(args, taskService, userService)
+	case "prune-tokens":
+		return runPruneTokens(args, accessTokenService)
+	default:
+		return fmt.Errorf("unknown command %q (available: backup, restore, import-todotxt, export-todotxt, prune-tokens)", name)
+	}
+}
+
+func openInput(args []string, usage string) (io.ReadCloser, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("usage: %s", usage)
+	}
+	if args[0] == "-" {
+		return io.NopCloser(os.Stdin), nil
+	}
+	return os.Open(args[0])
+}
+
+func openOutput(args []string) (io.WriteCloser, error) {
+	if len(args) == 0 || args[0] == "-" {
+		return nopWriteCloser{os.Stdout}, nil
+	}
+	return os.Create(args[0])
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+func runBackup(args []string, backupService *service.BackupService) error {
+	out, err := openOutput(args)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	return backupService.WriteBackup(out)
+}
+
+func runRestore(args []string, backupService *service.BackupService) error {
+	in, err := openInput(args, "main restore <file|->")
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	backup, err := backupService.ReadBackup(in)
+	if err != nil {
+		return err
+	}
+
+	return backupService.Restore(backup)
+}
+
+func runImportTodoTxt(args []string, taskService *service.TaskService, userService *service.UserService) error {
+	in, err := openInput(args, "main import-todotxt <file|-> [login]")
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	owner, err := commandUser(args, userService)
+	if err != nil {
+		return err
+	}
+
+	tasks, report, err := taskService.ParseTodoTxt(in)
+	if err != nil {
+		return err
+	}
+
+	if len(report.Errors) > 0 {
+		for _, rowErr := range report.Errors {
+			fmt.Fprintf(os.Stderr, "line %d: %s\n", rowErr.Row, rowErr.Error)
+		}
+		return fmt.Errorf("%d invalid lines, nothing imported", len(report.Errors))
+	}
+
+	ids, err := taskService.Repo.AddTasks(owner.ID, tasks, entities.Actor{UserID: owner.ID, TokenID: "cli"})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("imported %d tasks, skipped %d completed\n", len(ids), report.Skipped)
+	return nil
+}
+
+func runExportTodoTxt(args []string, taskService *service.TaskService, userService *service.UserService) error {
+	owner, err := commandUser(args, userService)
+	if err != nil {
+		return err
+	}
+
+	out, err := openOutput(args)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	return taskService.WriteTodoTxt(owner.ID, out)
+}
+
+func commandUser(args []string, userService *service.UserService) (*entities.User, error) {
+	if len(args) > 1 {
+		return userService.Repo.GetUserByLogin(args[1])
+	}
+	return userService.Repo.GetFirstAdmin()
+}
+
+func runPruneTokens(args []string, accessTokenService *service.AccessTokenService) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: prune-tokens <unused-for, e.g. 2160h>")
+	}
+
+	unusedFor, err := time.ParseDuration(args[0])
+	if err != nil {
+		return err
+	}
+
+	deleted, err := accessTokenService.Prune(unusedFor)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Deleted %d access tokens\n", deleted)
+	return nil
+}
+
+func runHashPassword(args []string) error {
+	var password string
+	if len(args) > 0 {
+		password = args[0]
+	} else {
+		fmt.Fprint(os.Stderr, "Password: ")
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		password = strings.TrimRight(line, "\r\n")
+	}
+
+	if password == "" {
+		return fmt.Errorf("empty password")
+	}
+
+	hash, err := service.HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(hash)
+	return nil
+}
+
+func runGenerateKey(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: generate-key <RS256|ES256|EdDSA> [file|-]")
+	}
+
+	key, err := service.GenerateKey(args[0])
+	if err != nil {
+		return err
+	}
+
+	path := "-"
+	if len(args) > 1 {
+		path = args[1]
+	}
+	if path == "-" {
+		_, err = os.Stdout.Write(key)
+		return err
+	}
+	return os.WriteFile(path, key, 0o600)
+}
@@ -1,13 +1,44 @@
 package config
 
 import (
+	"log"
 	"os"
+	"strings"
 )
 
 var (
 	TODO_DBFILE = getEnv("TODO_DBFILE", "scheduler.db")
 	TODO_PORT   = getEnv("TODO_PORT", "7540")
 	TODO_PASS   = getEnv("TODO_PASSWORD", "")
+
+	TODO_PASS_HASH  = getEnvOrFile("TODO_PASSWORD_HASH", "")
+	TODO_JWT_SECRET = getEnvOrFile("TODO_JWT_SECRET", "")
+	TODO_JWT_KEYS   = getEnv("TODO_JWT_KEYS", "")
+
+	TODO_ACCESS_TOKEN_TTL  = getEnv("TODO_ACCESS_TOKEN_TTL", "15m")
+	TODO_REFRESH_TOKEN_TTL = getEnv("TODO_REFRESH_TOKEN_TTL", "720h")
+
+	TODO_ALLOW_SIGNUP = getEnv("TODO_ALLOW_SIGNUP", "true")
+
+	TODO_REQUIRE_IF_MATCH = getEnv("TODO_REQUIRE_IF_MATCH", "true")
+
+	TODO_EVENT_REPLAY = getEnv("TODO_EVENT_REPLAY", "1000")
+
+	TODO_WEBHOOK_MAX_ATTEMPTS  = getEnv("TODO_WEBHOOK_MAX_ATTEMPTS", "12")
+	TODO_WEBHOOK_RETRY_DELAY   = getEnv("TODO_WEBHOOK_RETRY_DELAY", "2s")
+	TODO_WEBHOOK_ALLOW_PRIVATE = getEnv("TODO_WEBHOOK_ALLOW_PRIVATE", "false")
+
+	TODO_OIDC_ISSUER        = getEnv("TODO_OIDC_ISSUER", "")
+	TODO_OIDC_CLIENT_ID     = getEnv("TODO_OIDC_CLIENT_ID", "")
+	TODO_OIDC_CLIENT_SECRET = getEnvOrFile("TODO_OIDC_CLIENT_SECRET", "")
+	TODO_OIDC_REDIRECT_URL  = getEnv("TODO_OIDC_REDIRECT_URL", "")
+	TODO_OIDC_SCOPES        = getEnv("TODO_OIDC_SCOPES", "openid profile email")
+	TODO_OIDC_ADMINS        = getEnv("TODO_OIDC_ADMINS", "")
+
+	TODO_SIGNIN_ACCOUNT_ATTEMPTS = getEnv("TODO_SIGNIN_ACCOUNT_ATTEMPTS", "5")
+	TODO_SIGNIN_IP_ATTEMPTS      = getEnv("TODO_SIGNIN_IP_ATTEMPTS", "30")
+	TODO_SIGNIN_BACKOFF          = getEnv("TODO_SIGNIN_BACKOFF", "1s")
+	TODO_SIGNIN_LOCKOUT          = getEnv("TODO_SIGNIN_LOCKOUT", "15m")
 )
 
 func getEnv(key, defaultValue string) string {
@@ -16,3 +47,14 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+func getEnvOrFile(key, defaultValue string) string {
+	if path, exists := os.LookupEnv(key + "_FILE"); exists {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			log.Fatalf("Failed to read %s_FILE: %v", key, err)
+		}
+		return strings.TrimSpace(string(data))
+	}
+	return getEnv(key, defaultValue)
+}
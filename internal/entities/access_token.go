@@ -0,0 +1,20 @@
+package entities
+
+import "time"
+
+const (
+	ScopeTasksRead  = "tasks:read"
+	ScopeTasksWrite = "tasks:write"
+	ScopeAdmin      = "admin"
+)
+
+type AccessToken struct {
+	ID        int64      `json:"id"`
+	UserID    int64      `json:"-"`
+	Name      string     `json:"name"`
+	TokenHash string     `json:"-"`
+	Scopes    []string   `json:"scopes"`
+	Created   time.Time  `json:"created"`
+	LastUsed  *time.Time `json:"last_used"`
+	Expires   *time.Time `json:"expires"`
+}
@@ -0,0 +1,51 @@
+package entities
+
+import "time"
+
+const (
+	AuditCreate = "create"
+	AuditUpdate = "update"
+	AuditDelete = "delete"
+	AuditDone   = "done"
+)
+
+// Actor identifies who made a change: the user, the client IP and the token
+// the request was authenticated with.
+type Actor struct {
+	UserID  int64
+	IP      string
+	TokenID string
+}
+
+type FieldChange struct {
+	Before *string `json:"before,omitempty"`
+	After  *string `json:"after,omitempty"`
+}
+
+type AuditEntry struct {
+	ID        int64                  `json:"id"`
+	Time      time.Time              `json:"time"`
+	UserID    int64                  `json:"user_id"`
+	UserLogin string                 `json:"user_login"`
+	OwnerID   int64                  `json:"owner_id"`
+	TaskID    string                 `json:"task_id"`
+	Action    string                 `json:"action"`
+	Changes   map[string]FieldChange `json:"changes"`
+	IP        string                 `json:"ip"`
+	TokenID   string                 `json:"token_id"`
+}
+
+// AuditFilter selects audit entries; zero values match everything.
+// VisibleTo limits the result to changes of that user's list or made by that
+// user.
+type AuditFilter struct {
+	VisibleTo int64
+	UserID    int64
+	OwnerID   int64
+	TaskID    string
+	Action    string
+	From      time.Time
+	To        time.Time
+	BeforeID  int64
+	Limit     int
+}
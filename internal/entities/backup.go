@@ -0,0 +1,15 @@
+package entities
+
+import "time"
+
+type Backup struct {
+	Format    string                 `json:"format"`
+	Version   int                    `json:"version"`
+	CreatedAt time.Time              `json:"created_at"`
+	Tables    map[string]BackupTable `json:"tables"`
+}
+
+type BackupTable struct {
+	Columns []string        `json:"columns"`
+	Rows    [][]interface{} `json:"rows"`
+}
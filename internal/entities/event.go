@@ -0,0 +1,23 @@
+package entities
+
+import "time"
+
+const (
+	EventCreated   = "created"
+	EventUpdated   = "updated"
+	EventDeleted   = "deleted"
+	EventCompleted = "completed"
+	EventOverdue   = "overdue"
+)
+
+// TaskEvent is a change to a task. Task is the task after the change or, when
+// Removed is set, as it was before it was deleted.
+type TaskEvent struct {
+	ID      int64     `json:"id"`
+	Type    string    `json:"type"`
+	OwnerID int64     `json:"owner_id"`
+	TaskID  string    `json:"task_id"`
+	Task    *Task     `json:"task,omitempty"`
+	Removed bool      `json:"removed,omitempty"`
+	Time    time.Time `json:"time"`
+}
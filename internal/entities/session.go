@@ -0,0 +1,17 @@
+package entities
+
+import "time"
+
+type Session struct {
+	ID          string    `json:"id"`
+	UserID      int64     `json:"-"`
+	RefreshHash string    `json:"-"`
+	PrevHash    string    `json:"-"`
+	UserAgent   string    `json:"user_agent"`
+	IP          string    `json:"ip"`
+	Created     time.Time `json:"created"`
+	LastUsed    time.Time `json:"last_used"`
+	Expires     time.Time `json:"expires"`
+	Revoked     bool      `json:"-"`
+	Current     bool      `json:"current"`
+}
@@ -0,0 +1,21 @@
+package entities
+
+import "time"
+
+const (
+	PermissionViewer = "viewer"
+	PermissionEditor = "editor"
+	PermissionAdmin  = "admin"
+	PermissionOwner  = "owner"
+)
+
+type Share struct {
+	ID         int64     `json:"id"`
+	OwnerID    int64     `json:"owner_id"`
+	OwnerLogin string    `json:"owner_login"`
+	UserID     int64     `json:"user_id"`
+	UserLogin  string    `json:"user_login"`
+	Role       string    `json:"role"`
+	Filter     string    `json:"filter"`
+	Created    time.Time `json:"created"`
+}
@@ -0,0 +1,16 @@
+package entities
+
+import "time"
+
+type ShareLink struct {
+	ID           int64      `json:"id"`
+	OwnerID      int64      `json:"-"`
+	Name         string     `json:"name"`
+	TokenHash    string     `json:"-"`
+	Filter       string     `json:"filter"`
+	TaskIDs      []string   `json:"task_ids"`
+	PasswordHash string     `json:"-"`
+	HasPassword  bool       `json:"has_password"`
+	Created      time.Time  `json:"created"`
+	Expires      *time.Time `json:"expires"`
+}
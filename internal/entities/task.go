@@ -1,9 +1,31 @@
 package entities
 
 type Task struct {
-	ID      string `json:"id"`
-	Date    string `json:"date,omitempty"`
-	Title   string `json:"title"`
-	Comment string `json:"comment,omitempty"`
-	Repeat  string `json:"repeat,omitempty"`
+	ID       string `json:"id"`
+	Date     string `json:"date,omitempty"`
+	Title    string `json:"title"`
+	Comment  string `json:"comment,omitempty"`
+	Repeat   string `json:"repeat,omitempty"`
+	Priority string `json:"priority,omitempty"`
+	Created  string `json:"created,omitempty"`
+	Version  string `json:"version,omitempty"`
+
+	Permission string `json:"permission,omitempty"`
+}
+
+// TaskPatch holds the writable task fields. A nil field is left unchanged.
+type TaskPatch struct {
+	Date     *string
+	Title    *string
+	Comment  *string
+	Repeat   *string
+	Priority *string
+}
+
+// TaskFilter selects tasks for bulk changes. Search works as in the task
+// list; From and To bound the date, both inclusive. Empty fields match all.
+type TaskFilter struct {
+	Search string
+	From   string
+	To     string
 }
@@ -0,0 +1,53 @@
+package entities
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// WebhookEvents are the events a webhook can subscribe to.
+var WebhookEvents = []string{EventCreated, EventUpdated, EventCompleted, EventDeleted, EventOverdue}
+
+type Webhook struct {
+	ID      int64     `json:"id"`
+	UserID  int64     `json:"-"`
+	URL     string    `json:"url"`
+	Events  []string  `json:"events"`
+	Secret  string    `json:"-"`
+	Active  bool      `json:"active"`
+	Created time.Time `json:"created"`
+}
+
+// Subscribed reports whether the webhook wants events of the type.
+func (w Webhook) Subscribed(eventType string) bool {
+	for _, event := range w.Events {
+		if event == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery is one event sent to a webhook. Key is set for events that
+// must be delivered only once, such as a task becoming overdue on a date.
+type WebhookDelivery struct {
+	ID           int64           `json:"id"`
+	WebhookID    int64           `json:"webhook_id"`
+	Event        string          `json:"event"`
+	TaskID       string          `json:"task_id"`
+	Key          string          `json:"-"`
+	Payload      json.RawMessage `json:"payload"`
+	Status       string          `json:"status"`
+	Attempts     int             `json:"attempts"`
+	ResponseCode int             `json:"response_code,omitempty"`
+	Error        string          `json:"error,omitempty"`
+	Created      time.Time       `json:"created"`
+	NextAttempt  *time.Time      `json:"next_attempt"`
+	Delivered    *time.Time      `json:"delivered"`
+}
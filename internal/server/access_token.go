@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/antonkazachenko/go-todo-list-api/internal/entities"
+	"github.com/antonkazachenko/go-todo-list-api/internal/service"
+	"github.com/antonkazachenko/go-todo-list-api/middleware"
+	"github.com/antonkazachenko/go-todo-list-api/utils"
+)
+
+type accessTokenRequest struct {
+	Name          string   `json:"name"`
+	Scopes        []string `json:"scopes"`
+	ExpiresInDays int      `json:"expires_in_days"`
+}
+
+func (h *Handlers) HandleGetAccessTokens(res http.ResponseWriter, req *http.Request) {
+	tokens, err := h.AccessTokenService.Repo.GetAccessTokensByUser(currentUserID(req))
+	if err != nil {
+		utils.SendErrorResponse(res, req, utils.NewError("database_error"), http.StatusInternalServerError)
+		return
+	}
+
+	if len(tokens) == 0 {
+		tokens = []entities.AccessToken{}
+	}
+
+	sendJSONResponse(res, http.StatusOK, map[string][]entities.AccessToken{"tokens": tokens})
+}
+
+func (h *Handlers) HandleAddAccessToken(res http.ResponseWriter, req *http.Request) {
+	if rejectAccessToken(res, req) {
+		return
+	}
+
+	var body accessTokenRequest
+	if err := parseRequestBody(req, &body); err != nil {
+		utils.SendErrorResponse(res, req, utils.NewError("invalid_json"), http.StatusBadRequest)
+		return
+	}
+
+	user, _ := middleware.UserFromContext(req.Context())
+	ttl := time.Duration(body.ExpiresInDays) * 24 * time.Hour
+	plain, token, err := h.AccessTokenService.Create(user, body.Name, body.Scopes, ttl)
+	if errors.Is(err, service.ErrScopeDenied) {
+		utils.SendErrorResponse(res, req, err, http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		utils.SendErrorResponse(res, req, err, http.StatusBadRequest)
+		return
+	}
+
+	sendJSONResponse(res, http.StatusOK, struct {
+		Token string `json:"token"`
+		*entities.AccessToken
+	}{plain, token})
+}
+
+func (h *Handlers) HandleDeleteAccessToken(res http.ResponseWriter, req *http.Request) {
+	if rejectAccessToken(res, req) {
+		return
+	}
+
+	id, err := parseInt64ID(req)
+	if err != nil {
+		utils.SendErrorResponse(res, req, err, http.StatusBadRequest)
+		return
+	}
+
+	deleted, err := h.AccessTokenService.Repo.DeleteAccessToken(currentUserID(req), id)
+	if err != nil {
+		utils.SendErrorResponse(res, req, utils.NewError("database_error"), http.StatusInternalServerError)
+		return
+	}
+	if deleted == 0 {
+		utils.SendErrorResponse(res, req, utils.NewError("token_not_found"), http.StatusNotFound)
+		return
+	}
+
+	sendJSONResponse(res, http.StatusOK, map[string]interface{}{})
+}
+
+// rejectAccessToken keeps personal access tokens from managing credentials;
+// that needs a signed-in session.
+func rejectAccessToken(res http.ResponseWriter, req *http.Request) bool {
+	if _, ok := middleware.AccessTokenFromContext(req.Context()); ok {
+		utils.SendErrorResponse(res, req, utils.NewError("password_session_required"), http.StatusForbidden)
+		return true
+	}
+	return false
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/antonkazachenko/go-todo-list-api/internal/entities"
+	"github.com/antonkazachenko/go-todo-list-api/internal/service"
+	"github.com/antonkazachenko/go-todo-list-api/middleware"
+	"github.com/antonkazachenko/go-todo-list-api/utils"
+)
+
+var auditCSVColumns = []string{"id", "time", "user_id", "user_login", "owner_id", "task_id", "action", "changes", "ip", "token_id"}
+
+func (h *Handlers) HandleGetAudit(res http.ResponseWriter, req *http.Request) {
+	entries, ok := h.auditEntries(res, req, service.DefaultAuditLimit)
+	if !ok {
+		return
+	}
+
+	if len(entries) == 0 {
+		entries = []entities.AuditEntry{}
+	}
+
+	sendJSONResponse(res, http.StatusOK, map[string][]entities.AuditEntry{"entries": entries})
+}
+
+func (h *Handlers) HandleExportAudit(res http.ResponseWriter, req *http.Request) {
+	entries, ok := h.auditEntries(res, req, service.MaxAuditLimit)
+	if !ok {
+		return
+	}
+
+	res.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	res.Header().Set("Content-Disposition", `attachment; filename="audit.csv"`)
+	res.WriteHeader(http.StatusOK)
+
+	writer := csv.NewWriter(res)
+	if err := writer.Write(auditCSVColumns); err != nil {
+		fmt.Println("ошибка записи ответа в HandleExportAudit", err)
+		return
+	}
+
+	for _, entry := range entries {
+		changes, _ := json.Marshal(entry.Changes)
+		record := []string{
+			strconv.FormatInt(entry.ID, 10),
+			entry.Time.Format(time.RFC3339),
+			strconv.FormatInt(entry.UserID, 10),
+			entry.UserLogin,
+			strconv.FormatInt(entry.OwnerID, 10),
+			entry.TaskID,
+			entry.Action,
+			string(changes),
+			entry.IP,
+			entry.TokenID,
+		}
+		if err := writer.Write(record); err != nil {
+			fmt.Println("ошибка записи ответа в HandleExportAudit", err)
+			return
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		fmt.Println("ошибка записи ответа в HandleExportAudit", err)
+	}
+}
+
+// auditEntries reads the filters shared by the JSON and CSV views. Admins
+// see every list unless they use a personal access token without the admin
+// scope.
+func (h *Handlers) auditEntries(res http.ResponseWriter, req *http.Request, defaultLimit int) ([]entities.AuditEntry, bool) {
+	filter, err := parseAuditFilter(req)
+	if err != nil {
+		utils.SendErrorResponse(res, req, err, http.StatusBadRequest)
+		return nil, false
+	}
+	if filter.Limit == 0 {
+		filter.Limit = defaultLimit
+	}
+
+	user, _ := middleware.UserFromContext(req.Context())
+	all := user.Role == entities.RoleAdmin
+	if token, ok := middleware.AccessTokenFromContext(req.Context()); ok && !service.HasScope(token.Scopes, entities.ScopeAdmin) {
+		all = false
+	}
+
+	entries, err := h.AuditService.Entries(user.ID, all, filter)
+	if errors.Is(err, service.ErrInvalidAuditAction) {
+		utils.SendErrorResponse(res, req, err, http.StatusBadRequest)
+		return nil, false
+	}
+	if err != nil {
+		utils.SendErrorResponse(res, req, utils.NewError("database_error"), http.StatusInternalServerError)
+		return nil, false
+	}
+
+	return entries, true
+}
+
+func parseAuditFilter(req *http.Request) (entities.AuditFilter, error) {
+	query := req.URL.Query()
+	filter := entities.AuditFilter{TaskID: query.Get("task_id"), Action: query.Get("action")}
+
+	for name, target := range map[string]*int64{"user_id": &filter.UserID, "owner_id": &filter.OwnerID, "before_id": &filter.BeforeID} {
+		if value := query.Get(name); value != "" {
+			parsed, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return filter, utils.NewError("invalid_number", name)
+			}
+			*target = parsed
+		}
+	}
+
+	if filter.TaskID != "" {
+		if _, err := strconv.ParseInt(filter.TaskID, 10, 64); err != nil {
+			return filter, utils.NewError("invalid_number", "task_id")
+		}
+	}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			return filter, utils.NewError("invalid_value", "limit")
+		}
+		filter.Limit = limit
+	}
+
+	var err error
+	if filter.From, err = parseAuditTime(query.Get("from"), false); err != nil {
+		return filter, utils.NewError("invalid_format", "from")
+	}
+	if filter.To, err = parseAuditTime(query.Get("to"), true); err != nil {
+		return filter, utils.NewError("invalid_format", "to")
+	}
+
+	return filter, nil
+}
+
+// parseAuditTime accepts RFC 3339 or a YYYYMMDD date; a date used as the end
+// of a range includes the whole day.
+func parseAuditTime(value string, end bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if parsed, err := time.Parse(time.RFC3339, value); err == nil {
+		return parsed, nil
+	}
+
+	parsed, err := time.ParseInLocation(service.Format, value, time.Local)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if end {
+		parsed = parsed.AddDate(0, 0, 1)
+	}
+	return parsed, nil
+}
@@ -1,61 +1,272 @@
 package handlers
 
 import (
-	"bytes"
-	"encoding/json"
-	"fmt"
+	"errors"
+	"log"
+	"math"
+	"net"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/antonkazachenko/go-todo-list-api/config"
+	"github.com/antonkazachenko/go-todo-list-api/internal/entities"
+	"github.com/antonkazachenko/go-todo-list-api/internal/service"
+	"github.com/antonkazachenko/go-todo-list-api/middleware"
 	"github.com/antonkazachenko/go-todo-list-api/models"
 	"github.com/antonkazachenko/go-todo-list-api/utils"
-	"github.com/golang-jwt/jwt/v4"
 )
 
+type credentials struct {
+	Login    string `json:"login"`
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
 func (h *Handlers) HandleSignIn(res http.ResponseWriter, req *http.Request) {
-	pass := config.TODO_PASS
-	if len(pass) == 0 {
-		utils.SendErrorResponse(res, "пароль не установлен", http.StatusInternalServerError)
+	if !h.UserService.AuthEnabled() {
+		utils.SendErrorResponse(res, req, utils.NewError("auth_not_configured"), http.StatusInternalServerError)
 		return
 	}
 
-	var buf bytes.Buffer
-	_, err := buf.ReadFrom(req.Body)
+	var body credentials
+	if err := parseRequestBody(req, &body); err != nil {
+		utils.SendErrorResponse(res, req, utils.NewError("invalid_json"), http.StatusBadRequest)
+		return
+	}
+
+	ip := clientIP(req)
+	user, err := h.UserService.SignIn(body.Login, body.Password, ip)
+	if sendRetryAfter(res, req, err, body.Login, ip) {
+		return
+	}
+	if errors.Is(err, service.ErrInvalidCredentials) {
+		log.Printf("Failed sign-in for %q from %s", body.Login, ip)
+		utils.SendErrorResponse(res, req, utils.NewError("invalid_password"), http.StatusUnauthorized)
+		return
+	}
 	if err != nil {
-		utils.SendErrorResponse(res, "ошибка чтения тела запроса", http.StatusBadRequest)
+		utils.SendErrorResponse(res, req, utils.NewError("database_error"), http.StatusInternalServerError)
 		return
 	}
 
-	var body map[string]string
-	err = json.Unmarshal(buf.Bytes(), &body)
+	if user.TOTPEnabled {
+		if body.Code == "" {
+			mfaToken, err := h.TokenService.IssueMFAToken(user)
+			if err != nil {
+				utils.SendErrorResponse(res, req, utils.NewError("token_error"), http.StatusInternalServerError)
+				return
+			}
+			sendJSONResponse(res, http.StatusOK, models.AuthResponse{MFARequired: true, MFAToken: mfaToken})
+			return
+		}
+		if !h.verifySecondFactor(res, req, user, body.Code, ip) {
+			return
+		}
+	}
+
+	h.sendToken(res, req, user)
+}
+
+func (h *Handlers) HandleSignInSecondFactor(res http.ResponseWriter, req *http.Request) {
+	var body struct {
+		MFAToken string `json:"mfa_token"`
+		Code     string `json:"code"`
+	}
+	if err := parseRequestBody(req, &body); err != nil {
+		utils.SendErrorResponse(res, req, utils.NewError("invalid_json"), http.StatusBadRequest)
+		return
+	}
+
+	userID, err := h.TokenService.ParseMFAToken(body.MFAToken)
 	if err != nil {
-		utils.SendErrorResponse(res, "ошибка декодирования JSON", http.StatusBadRequest)
+		utils.SendErrorResponse(res, req, err, http.StatusUnauthorized)
+		return
+	}
+
+	user, err := h.UserService.Repo.GetUserByID(userID)
+	if err != nil || !user.TOTPEnabled {
+		utils.SendErrorResponse(res, req, service.ErrInvalidToken, http.StatusUnauthorized)
+		return
+	}
+
+	if !h.verifySecondFactor(res, req, user, body.Code, clientIP(req)) {
+		return
+	}
+
+	h.sendToken(res, req, user)
+}
+
+func (h *Handlers) HandleSignUp(res http.ResponseWriter, req *http.Request) {
+	if !h.UserService.AuthEnabled() || config.TODO_ALLOW_SIGNUP != "true" {
+		utils.SendErrorResponse(res, req, utils.NewError("signup_disabled"), http.StatusForbidden)
 		return
 	}
 
-	if body["password"] != pass {
-		utils.SendErrorResponse(res, "неверный пароль", http.StatusUnauthorized)
+	var body credentials
+	if err := parseRequestBody(req, &body); err != nil {
+		utils.SendErrorResponse(res, req, utils.NewError("invalid_json"), http.StatusBadRequest)
 		return
 	}
 
-	token := jwt.New(jwt.SigningMethodHS256)
-	tokenString, err := token.SignedString([]byte(pass))
+	user, err := h.UserService.Register(body.Login, body.Password, entities.RoleUser)
+	if errors.Is(err, service.ErrLoginTaken) {
+		utils.SendErrorResponse(res, req, err, http.StatusConflict)
+		return
+	}
 	if err != nil {
-		utils.SendErrorResponse(res, "ошибка создания токена", http.StatusInternalServerError)
+		utils.SendErrorResponse(res, req, err, http.StatusBadRequest)
 		return
 	}
 
-	var resp models.AuthResponse
-	resp.Token = tokenString
-	respBytes, err := json.Marshal(resp)
+	h.sendToken(res, req, user)
+}
+
+func (h *Handlers) HandleRefresh(res http.ResponseWriter, req *http.Request) {
+	if !h.UserService.AuthEnabled() {
+		utils.SendErrorResponse(res, req, utils.NewError("auth_not_configured"), http.StatusInternalServerError)
+		return
+	}
+
+	var body struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := parseRequestBody(req, &body); err != nil {
+		utils.SendErrorResponse(res, req, utils.NewError("invalid_json"), http.StatusBadRequest)
+		return
+	}
+
+	pair, err := h.TokenService.Refresh(body.RefreshToken, req.UserAgent(), clientIP(req))
+	if errors.Is(err, service.ErrInvalidToken) || errors.Is(err, service.ErrSessionRevoked) || errors.Is(err, service.ErrRefreshReused) {
+		utils.SendErrorResponse(res, req, err, http.StatusUnauthorized)
+		return
+	}
 	if err != nil {
-		http.Error(res, "ошибка при сериализации ответа", http.StatusInternalServerError)
+		utils.SendErrorResponse(res, req, utils.NewError("database_error"), http.StatusInternalServerError)
+		return
+	}
+
+	sendTokenPair(res, pair)
+}
+
+func (h *Handlers) HandleSignOut(res http.ResponseWriter, req *http.Request) {
+	claims, ok := middleware.ClaimsFromContext(req.Context())
+	if !ok {
+		utils.SendErrorResponse(res, req, utils.NewError("auth_disabled"), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.TokenService.SignOut(claims); err != nil {
+		utils.SendErrorResponse(res, req, utils.NewError("database_error"), http.StatusInternalServerError)
 		return
 	}
-	res.Header().Set("Content-Type", "application/json")
-	res.WriteHeader(http.StatusOK)
-	_, err = res.Write(respBytes)
+
+	sendJSONResponse(res, http.StatusOK, map[string]interface{}{})
+}
+
+func (h *Handlers) HandleGetSessions(res http.ResponseWriter, req *http.Request) {
+	if rejectAccessToken(res, req) {
+		return
+	}
+
+	var currentSessionID string
+	if claims, ok := middleware.ClaimsFromContext(req.Context()); ok {
+		currentSessionID = claims.SessionID
+	}
+
+	sessions, err := h.TokenService.Sessions(currentUserID(req), currentSessionID)
+	if err != nil {
+		utils.SendErrorResponse(res, req, utils.NewError("database_error"), http.StatusInternalServerError)
+		return
+	}
+
+	if len(sessions) == 0 {
+		sessions = []entities.Session{}
+	}
+
+	sendJSONResponse(res, http.StatusOK, map[string][]entities.Session{"sessions": sessions})
+}
+
+func (h *Handlers) HandleDeleteSession(res http.ResponseWriter, req *http.Request) {
+	if rejectAccessToken(res, req) {
+		return
+	}
+
+	sessionID := req.URL.Query().Get("id")
+	if sessionID == "" {
+		utils.SendErrorResponse(res, req, utils.NewError("required/id"), http.StatusBadRequest)
+		return
+	}
+
+	revoked, err := h.TokenService.Repo.RevokeSession(currentUserID(req), sessionID)
+	if err != nil {
+		utils.SendErrorResponse(res, req, utils.NewError("database_error"), http.StatusInternalServerError)
+		return
+	}
+	if revoked == 0 {
+		utils.SendErrorResponse(res, req, utils.NewError("session_not_found"), http.StatusNotFound)
+		return
+	}
+
+	sendJSONResponse(res, http.StatusOK, map[string]interface{}{})
+}
+
+func (h *Handlers) HandleJWKS(res http.ResponseWriter, req *http.Request) {
+	res.Header().Set("Cache-Control", "public, max-age=300")
+	sendJSONResponse(res, http.StatusOK, h.TokenService.Keys.JWKS())
+}
+
+func (h *Handlers) sendToken(res http.ResponseWriter, req *http.Request, user *entities.User) {
+	pair, err := h.TokenService.IssueTokens(user, req.UserAgent(), clientIP(req))
+	if err != nil {
+		utils.SendErrorResponse(res, req, utils.NewError("token_error"), http.StatusInternalServerError)
+		return
+	}
+
+	sendTokenPair(res, pair)
+}
+
+func (h *Handlers) verifySecondFactor(res http.ResponseWriter, req *http.Request, user *entities.User, code, ip string) bool {
+	err := h.UserService.VerifySecondFactor(user, code, ip)
+	if sendRetryAfter(res, req, err, user.Login, ip) {
+		return false
+	}
+	if errors.Is(err, service.ErrInvalidCode) {
+		log.Printf("Failed second factor for %q from %s", user.Login, ip)
+		utils.SendErrorResponse(res, req, err, http.StatusUnauthorized)
+		return false
+	}
+	if err != nil {
+		utils.SendErrorResponse(res, req, utils.NewError("database_error"), http.StatusInternalServerError)
+		return false
+	}
+	return true
+}
+
+func sendRetryAfter(res http.ResponseWriter, req *http.Request, err error, login, ip string) bool {
+	var retryErr *service.RetryAfterError
+	if !errors.As(err, &retryErr) {
+		return false
+	}
+
+	log.Printf("Sign-in for %q from %s throttled for %s", login, ip, retryErr.RetryAfter.Round(time.Second))
+	res.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryErr.RetryAfter.Seconds()))))
+	utils.SendErrorResponse(res, req, retryErr, http.StatusTooManyRequests)
+	return true
+}
+
+func sendTokenPair(res http.ResponseWriter, pair *service.TokenPair) {
+	sendJSONResponse(res, http.StatusOK, models.AuthResponse{
+		Token:        pair.AccessToken,
+		RefreshToken: pair.RefreshToken,
+		ExpiresIn:    int64(pair.ExpiresIn.Seconds()),
+	})
+}
+
+func clientIP(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
 	if err != nil {
-		fmt.Println("ошибка записи ответа в HandleSignIn", err)
+		return req.RemoteAddr
 	}
+	return host
 }
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/antonkazachenko/go-todo-list-api/utils"
+)
+
+func (h *Handlers) HandleBackup(res http.ResponseWriter, req *http.Request) {
+	backup, err := h.BackupService.Backup()
+	if err != nil {
+		utils.SendErrorResponse(res, req, utils.NewError("database_error"), http.StatusInternalServerError)
+		return
+	}
+
+	filename := fmt.Sprintf("backup-%s.json", backup.CreatedAt.Format("20060102-150405"))
+	res.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	sendJSONResponse(res, http.StatusOK, backup)
+}
+
+func (h *Handlers) HandleRestore(res http.ResponseWriter, req *http.Request) {
+	backup, err := h.BackupService.ReadBackup(req.Body)
+	if err != nil {
+		utils.SendErrorResponse(res, req, err, http.StatusBadRequest)
+		return
+	}
+
+	if err := h.BackupService.ValidateBackup(backup); err != nil {
+		utils.SendErrorResponse(res, req, err, http.StatusBadRequest)
+		return
+	}
+
+	if err := h.BackupService.RestoreValidated(backup); err != nil {
+		utils.SendErrorResponse(res, req, utils.NewError("restore_failed"), http.StatusInternalServerError)
+		return
+	}
+
+	rows := make(map[string]int, len(backup.Tables))
+	for table, data := range backup.Tables {
+		rows[table] = len(data.Rows)
+	}
+
+	sendJSONResponse(res, http.StatusOK, map[string]interface{}{
+		"restored_at": time.Now().UTC(),
+		"tables":      rows,
+	})
+}
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/antonkazachenko/go-todo-list-api/internal/entities"
+	"github.com/antonkazachenko/go-todo-list-api/internal/service"
+	"github.com/antonkazachenko/go-todo-list-api/models"
+	"github.com/antonkazachenko/go-todo-list-api/utils"
+)
+
+const maxBatchOperations = 1000
+
+type batchRequest struct {
+	Operations      []batchOperation `json:"operations"`
+	ContinueOnError bool             `json:"continue_on_error"`
+}
+
+type batchOperation struct {
+	Op      string          `json:"op"`
+	ID      string          `json:"id"`
+	Version json.RawMessage `json:"version"`
+	Task    json.RawMessage `json:"task"`
+}
+
+// HandleBatchTasks runs create, update, delete and done operations in one
+// transaction. The response is 200 when the batch was committed; otherwise
+// nothing is saved and the status is that of the operation that failed.
+func (h *Handlers) HandleBatchTasks(res http.ResponseWriter, req *http.Request) {
+	var body batchRequest
+	if err := parseRequestBody(req, &body); err != nil {
+		utils.SendErrorResponse(res, req, utils.NewError("invalid_json"), http.StatusBadRequest)
+		return
+	}
+
+	if len(body.Operations) == 0 {
+		utils.SendErrorResponse(res, req, utils.NewError("required/operations"), http.StatusBadRequest)
+		return
+	}
+	if len(body.Operations) > maxBatchOperations {
+		utils.SendErrorResponse(res, req, utils.NewError("too_many_operations", maxBatchOperations), http.StatusBadRequest)
+		return
+	}
+
+	ownerID, err := listOwnerID(req)
+	if err != nil {
+		utils.SendErrorResponse(res, req, err, http.StatusBadRequest)
+		return
+	}
+
+	ops := make([]service.BatchOperation, len(body.Operations))
+	for i, op := range body.Operations {
+		ops[i] = h.decodeBatchOperation(op, ownerID)
+	}
+
+	results, committed, err := h.TaskService.Batch(currentActor(req), ops, body.ContinueOnError)
+	if err != nil {
+		sendDatabaseError(res, req, err)
+		return
+	}
+
+	status := http.StatusOK
+	resp := models.BatchResponse{Committed: committed, Results: make([]models.BatchResult, len(results))}
+	for i, result := range results {
+		resp.Results[i] = h.batchResult(req, ops[i], result, committed)
+		if !committed && status == http.StatusOK && result.Err != nil {
+			status = resp.Results[i].Status
+		}
+	}
+
+	sendJSONResponse(res, status, resp)
+}
+
+// decodeBatchOperation checks an operation the way the matching single
+// request would; a failure is kept in Err so that it is reported in place.
+func (h *Handlers) decodeBatchOperation(op batchOperation, ownerID int64) service.BatchOperation {
+	decoded := service.BatchOperation{Op: op.Op, ID: op.ID, OwnerID: ownerID}
+
+	switch op.Op {
+	case service.BatchCreate:
+		if err := json.Unmarshal(op.Task, &decoded.Task); err != nil {
+			decoded.Err = utils.NewError("invalid_json")
+			return decoded
+		}
+		decoded.Err = h.TaskService.ValidateNewTask(&decoded.Task)
+		return decoded
+	case service.BatchUpdate, service.BatchDelete, service.BatchDone:
+	default:
+		decoded.Err = utils.NewError("invalid_value", "op")
+		return decoded
+	}
+
+	if _, err := parseAndValidateID(op.ID); err != nil {
+		decoded.Err = err
+		return decoded
+	}
+
+	version, err := versionValue(op.Version)
+	if err != nil {
+		decoded.Err = err
+		return decoded
+	}
+	if op.Op == service.BatchUpdate {
+		var body map[string]json.RawMessage
+		if err := json.Unmarshal(op.Task, &body); err != nil || body == nil {
+			decoded.Err = utils.NewError("invalid_json")
+			return decoded
+		}
+
+		versionField, patch, err := taskPatch(body, false)
+		if err == nil {
+			err = validateTaskPatch(patch)
+		}
+		if err != nil {
+			decoded.Err = err
+			return decoded
+		}
+
+		decoded.Patch = patch
+		if version == "" {
+			version = versionField
+		}
+	}
+
+	decoded.Version, decoded.Err = fieldVersion(version)
+	return decoded
+}
+
+func (h *Handlers) batchResult(req *http.Request, op service.BatchOperation, result service.BatchResult, committed bool) models.BatchResult {
+	if result.Err == nil {
+		out := models.BatchResult{Status: http.StatusOK, ID: result.ID, Task: result.Task}
+		switch {
+		case op.Op == service.BatchCreate:
+			out.Status = http.StatusCreated
+		case result.Task == nil:
+			out.Status = http.StatusNoContent
+		}
+		if !committed {
+			out.Task = nil
+			if op.Op == service.BatchCreate {
+				out.ID = ""
+			}
+		}
+		return out
+	}
+
+	apiErr, status := batchError(op, result.Err)
+	errResp := utils.LocalizeError(apiErr, status, utils.Language(req))
+	if errors.Is(result.Err, service.ErrVersionConflict) {
+		if current, _, err := h.TaskService.GetTask(currentUserID(req), op.ID, entities.PermissionViewer); err == nil {
+			errResp.Current = current
+		}
+	}
+
+	return models.BatchResult{Status: status, ID: op.ID, Error: &errResp}
+}
+
+func batchError(op service.BatchOperation, err error) (error, int) {
+	switch {
+	case errors.Is(err, service.ErrPreconditionRequired):
+		return err, http.StatusPreconditionRequired
+	case op.Err != nil:
+		return err, http.StatusBadRequest
+	case errors.Is(err, service.ErrBatchAborted):
+		return err, http.StatusFailedDependency
+	case errors.Is(err, service.ErrVersionConflict):
+		return err, http.StatusPreconditionFailed
+	default:
+		return taskAccessError(err, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/antonkazachenko/go-todo-list-api/internal/entities"
+	"github.com/antonkazachenko/go-todo-list-api/internal/service"
+	"github.com/antonkazachenko/go-todo-list-api/models"
+	"github.com/antonkazachenko/go-todo-list-api/utils"
+)
+
+const maxRescheduleDays = 3650
+
+type bulkRequest struct {
+	Action string                     `json:"action"`
+	Days   int                        `json:"days"`
+	Task   map[string]json.RawMessage `json:"task"`
+}
+
+// HandleBulkTasks applies one action to every task selected by the search,
+// from and to query parameters and answers with the ids of those tasks.
+func (h *Handlers) HandleBulkTasks(res http.ResponseWriter, req *http.Request) {
+	var body bulkRequest
+	if err := parseRequestBody(req, &body); err != nil {
+		utils.SendErrorResponse(res, req, utils.NewError("invalid_json"), http.StatusBadRequest)
+		return
+	}
+
+	action, err := bulkAction(body)
+	if err != nil {
+		utils.SendErrorResponse(res, req, err, http.StatusBadRequest)
+		return
+	}
+
+	filter, err := taskFilter(req)
+	if err != nil {
+		utils.SendErrorResponse(res, req, err, http.StatusBadRequest)
+		return
+	}
+
+	ownerID, err := listOwnerID(req)
+	if err != nil {
+		utils.SendErrorResponse(res, req, err, http.StatusBadRequest)
+		return
+	}
+
+	dryRun := req.URL.Query().Get("dry_run") == "true"
+	ids, err := h.TaskService.Bulk(currentActor(req), ownerID, filter, action, dryRun)
+	if err != nil {
+		sendTaskAccessError(res, req, err, http.StatusNotFound)
+		return
+	}
+
+	sendJSONResponse(res, http.StatusOK, models.BulkResponse{DryRun: dryRun, Action: action.Action, IDs: ids})
+}
+
+func bulkAction(body bulkRequest) (service.BulkAction, error) {
+	action := service.BulkAction{Action: body.Action, Days: body.Days}
+
+	switch body.Action {
+	case service.BulkReschedule:
+		if body.Days == 0 {
+			return action, utils.NewError("required", "days")
+		}
+		if body.Days > maxRescheduleDays || body.Days < -maxRescheduleDays {
+			return action, utils.NewError("invalid_value", "days")
+		}
+	case service.BulkDone, service.BulkDelete:
+	case service.BulkSet:
+		if len(body.Task) == 0 {
+			return action, utils.NewError("required", "task")
+		}
+		_, patch, err := taskPatch(body.Task, false)
+		if err == nil {
+			err = validateTaskPatch(patch)
+		}
+		if err != nil {
+			return action, err
+		}
+		action.Patch = patch
+	case "":
+		return action, utils.NewError("required", "action")
+	default:
+		return action, utils.NewError("invalid_value", "action")
+	}
+
+	return action, nil
+}
+
+// taskFilter reads the bulk selection from the query. At least one condition
+// is required so that a forgotten parameter cannot touch the whole list.
+func taskFilter(req *http.Request) (entities.TaskFilter, error) {
+	query := req.URL.Query()
+	filter := entities.TaskFilter{Search: query.Get("search"), From: query.Get("from"), To: query.Get("to")}
+
+	for _, name := range []string{"from", "to"} {
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+		if _, err := time.Parse(service.Format, value); err != nil {
+			return filter, utils.NewError("invalid_format", name)
+		}
+	}
+
+	if filter == (entities.TaskFilter{}) {
+		return filter, utils.NewError("filter_required")
+	}
+	return filter, nil
+}
@@ -0,0 +1,332 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/antonkazachenko/go-todo-list-api/internal/entities"
+	"github.com/antonkazachenko/go-todo-list-api/models"
+	"github.com/antonkazachenko/go-todo-list-api/utils"
+)
+
+type csvColumn struct {
+	Field  string
+	Header string
+}
+
+const (
+	maxImportSize = 10 << 20
+	maxImportRows = 10000
+)
+
+// csvFormulaPrefixes start cells that spreadsheets evaluate as formulas.
+const csvFormulaPrefixes = "=+-@\t\r"
+
+var (
+	csvExportColumns = []string{"id", "date", "title", "comment", "repeat", "priority"}
+	csvImportColumns = []string{"date", "title", "comment", "repeat", "priority"}
+)
+
+func (h *Handlers) HandleExportCSV(res http.ResponseWriter, req *http.Request) {
+	delimiter, err := parseCSVDelimiter(req.URL.Query().Get("delimiter"))
+	if err != nil {
+		utils.SendErrorResponse(res, req, err, http.StatusBadRequest)
+		return
+	}
+
+	columns, err := parseCSVColumns(req.URL.Query().Get("columns"), csvExportColumns)
+	if err != nil {
+		utils.SendErrorResponse(res, req, err, http.StatusBadRequest)
+		return
+	}
+
+	tasks, err := h.TaskService.Repo.GetAllTasks(currentUserID(req))
+	if err != nil {
+		utils.SendErrorResponse(res, req, utils.NewError("database_error"), http.StatusInternalServerError)
+		return
+	}
+
+	res.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	res.Header().Set("Content-Disposition", `attachment; filename="tasks.csv"`)
+	res.WriteHeader(http.StatusOK)
+
+	writer := csv.NewWriter(res)
+	writer.Comma = delimiter
+
+	header := make([]string, len(columns))
+	for i, column := range columns {
+		header[i] = column.Header
+	}
+	if err := writer.Write(header); err != nil {
+		fmt.Println("ошибка записи ответа в HandleExportCSV", err)
+		return
+	}
+
+	for _, task := range tasks {
+		record := make([]string, len(columns))
+		for i, column := range columns {
+			record[i] = escapeCSVFormula(taskField(task, column.Field))
+		}
+		if err := writer.Write(record); err != nil {
+			fmt.Println("ошибка записи ответа в HandleExportCSV", err)
+			return
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		fmt.Println("ошибка записи ответа в HandleExportCSV", err)
+	}
+}
+
+func (h *Handlers) HandleImportCSV(res http.ResponseWriter, req *http.Request) {
+	delimiter, err := parseCSVDelimiter(req.URL.Query().Get("delimiter"))
+	if err != nil {
+		utils.SendErrorResponse(res, req, err, http.StatusBadRequest)
+		return
+	}
+
+	columns, err := parseCSVColumns(req.URL.Query().Get("columns"), csvImportColumns)
+	if err != nil {
+		utils.SendErrorResponse(res, req, err, http.StatusBadRequest)
+		return
+	}
+
+	reader := csv.NewReader(http.MaxBytesReader(res, req.Body, maxImportSize))
+	reader.Comma = delimiter
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		utils.SendErrorResponse(res, req, utils.NewError("invalid_csv/empty"), http.StatusBadRequest)
+		return
+	}
+	if sendImportTooLarge(res, req, err) {
+		return
+	}
+	if err != nil {
+		utils.SendErrorResponse(res, req, utils.NewError("invalid_csv/header"), http.StatusBadRequest)
+		return
+	}
+
+	positions, err := mapCSVHeader(header, columns)
+	if err != nil {
+		utils.SendErrorResponse(res, req, err, http.StatusBadRequest)
+		return
+	}
+
+	var tasks []entities.Task
+	report := models.ImportResponse{Errors: []models.ImportRowError{}}
+	row := 1
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		row++
+		report.Total++
+		if report.Total > maxImportRows {
+			utils.SendErrorResponse(res, req, utils.NewError("too_many_rows", maxImportRows), http.StatusBadRequest)
+			return
+		}
+		if sendImportTooLarge(res, req, err) {
+			return
+		}
+
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			rowErr := utils.NewError("invalid_csv/row")
+			report.Errors = append(report.Errors, models.ImportRowError{Row: row, Error: rowErr.Error(), Err: rowErr})
+			continue
+		}
+		if err != nil {
+			utils.SendErrorResponse(res, req, utils.NewError("invalid_body"), http.StatusBadRequest)
+			return
+		}
+
+		var task entities.Task
+		for field, position := range positions {
+			if position < len(record) {
+				setTaskField(&task, field, strings.TrimSpace(unescapeCSVFormula(record[position])))
+			}
+		}
+
+		if err := h.TaskService.ValidateNewTask(&task); err != nil {
+			report.Errors = append(report.Errors, models.ImportRowError{Row: row, Error: err.Error(), Err: err})
+			continue
+		}
+		tasks = append(tasks, task)
+	}
+
+	h.sendImportResponse(res, req, tasks, report)
+}
+
+// sendImportTooLarge answers 413 when the body went over maxImportSize.
+func sendImportTooLarge(res http.ResponseWriter, req *http.Request, err error) bool {
+	var tooLarge *http.MaxBytesError
+	if !errors.As(err, &tooLarge) {
+		return false
+	}
+	utils.SendErrorResponse(res, req, utils.NewError("payload_too_large", maxImportSize), http.StatusRequestEntityTooLarge)
+	return true
+}
+
+func (h *Handlers) sendImportResponse(res http.ResponseWriter, req *http.Request, tasks []entities.Task, resp models.ImportResponse) {
+	resp.DryRun = req.URL.Query().Get("dry_run") == "true"
+	resp.IDs = []int64{}
+
+	if len(resp.Errors) > 0 {
+		language := utils.Language(req)
+		for i, rowErr := range resp.Errors {
+			localized := utils.LocalizeError(rowErr.Err, http.StatusBadRequest, language)
+			resp.Errors[i].Error, resp.Errors[i].Code, resp.Errors[i].Field = localized.Error, localized.Code, localized.Field
+		}
+		failed := utils.LocalizeError(utils.NewError("import_failed", len(resp.Errors)), http.StatusBadRequest, language)
+		resp.Error, resp.Code = failed.Error, failed.Code
+		res.Header().Set("Content-Language", language)
+		res.Header().Add("Vary", "Accept-Language")
+		sendJSONResponse(res, http.StatusBadRequest, resp)
+		return
+	}
+
+	if !resp.DryRun && len(tasks) > 0 {
+		ids, err := h.TaskService.ImportTasks(currentActor(req), currentUserID(req), tasks)
+		if err != nil {
+			sendDatabaseError(res, req, err)
+			return
+		}
+		resp.IDs = ids
+		resp.Imported = len(ids)
+	}
+
+	sendJSONResponse(res, http.StatusOK, resp)
+}
+
+func parseCSVDelimiter(value string) (rune, error) {
+	switch value {
+	case "":
+		return ',', nil
+	case "tab", `\t`:
+		return '\t', nil
+	}
+
+	delimiter, size := utf8.DecodeRuneInString(value)
+	if size != len(value) || delimiter == '"' || delimiter == '\r' || delimiter == '\n' || delimiter == utf8.RuneError {
+		return 0, utils.NewError("invalid_value/delimiter")
+	}
+	return delimiter, nil
+}
+
+func parseCSVColumns(value string, allowed []string) ([]csvColumn, error) {
+	if strings.TrimSpace(value) == "" {
+		columns := make([]csvColumn, len(allowed))
+		for i, field := range allowed {
+			columns[i] = csvColumn{Field: field, Header: field}
+		}
+		return columns, nil
+	}
+
+	var columns []csvColumn
+	seen := make(map[string]bool)
+	for _, entry := range strings.Split(value, ",") {
+		field, header, found := strings.Cut(entry, ":")
+		field = strings.ToLower(strings.TrimSpace(field))
+		header = strings.TrimSpace(header)
+		if !found || header == "" {
+			header = field
+		}
+
+		if !isAllowedField(field, allowed) {
+			return nil, utils.NewError("invalid_column", field)
+		}
+		if seen[field] {
+			return nil, utils.NewError("duplicate_column", field)
+		}
+		seen[field] = true
+		columns = append(columns, csvColumn{Field: field, Header: header})
+	}
+	return columns, nil
+}
+
+func mapCSVHeader(header []string, columns []csvColumn) (map[string]int, error) {
+	positions := make(map[string]int)
+	for _, column := range columns {
+		for i, name := range header {
+			name = strings.TrimPrefix(name, "\ufeff")
+			if strings.EqualFold(strings.TrimSpace(name), column.Header) {
+				positions[column.Field] = i
+				break
+			}
+		}
+	}
+
+	if _, ok := positions["title"]; !ok {
+		return nil, utils.NewError("required/title_column")
+	}
+	return positions, nil
+}
+
+func isAllowedField(field string, allowed []string) bool {
+	for _, v := range allowed {
+		if field == v {
+			return true
+		}
+	}
+	return false
+}
+
+// escapeCSVFormula prefixes a cell that a spreadsheet would run as a formula
+// with an apostrophe, so that it is shown as text.
+func escapeCSVFormula(value string) string {
+	if value != "" && strings.ContainsRune(csvFormulaPrefixes, rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// unescapeCSVFormula drops the apostrophe added by escapeCSVFormula, so that
+// an exported file imports back unchanged.
+func unescapeCSVFormula(value string) string {
+	if rest, ok := strings.CutPrefix(value, "'"); ok && rest != "" && strings.ContainsRune(csvFormulaPrefixes, rune(rest[0])) {
+		return rest
+	}
+	return value
+}
+
+func taskField(task entities.Task, field string) string {
+	switch field {
+	case "id":
+		return task.ID
+	case "date":
+		return task.Date
+	case "title":
+		return task.Title
+	case "comment":
+		return task.Comment
+	case "repeat":
+		return task.Repeat
+	case "priority":
+		return task.Priority
+	}
+	return ""
+}
+
+func setTaskField(task *entities.Task, field, value string) {
+	switch field {
+	case "date":
+		task.Date = value
+	case "title":
+		task.Title = value
+	case "comment":
+		task.Comment = value
+	case "repeat":
+		task.Repeat = value
+	case "priority":
+		task.Priority = value
+	}
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/antonkazachenko/go-todo-list-api/internal/entities"
+	"github.com/antonkazachenko/go-todo-list-api/utils"
+)
+
+const eventHeartbeat = 25 * time.Second
+
+// HandleEvents streams the task changes the caller can see as Server-Sent
+// Events. A client that reconnects with Last-Event-ID gets the events it
+// missed, or a reset event when they are no longer buffered.
+func (h *Handlers) HandleEvents(res http.ResponseWriter, req *http.Request) {
+	flusher, ok := res.(http.Flusher)
+	if !ok || h.TaskService.Events == nil {
+		utils.SendErrorResponse(res, req, utils.NewError("events_unavailable"), http.StatusServiceUnavailable)
+		return
+	}
+
+	lastID, err := lastEventID(req)
+	if err != nil {
+		utils.SendErrorResponse(res, req, err, http.StatusBadRequest)
+		return
+	}
+
+	replay, events, complete, cancel := h.TaskService.Events.Subscribe(lastID)
+	defer cancel()
+
+	res.Header().Set("Content-Type", "text/event-stream")
+	res.Header().Set("Cache-Control", "no-cache")
+	res.Header().Set("X-Accel-Buffering", "no")
+	res.WriteHeader(http.StatusOK)
+
+	userID := currentUserID(req)
+	send := func(event entities.TaskEvent) error {
+		if !h.TaskService.CanSee(userID, event) {
+			// Moves the client's Last-Event-ID on without dispatching anything.
+			_, err := fmt.Fprintf(res, "id: %d\n\n", event.ID)
+			return err
+		}
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(res, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+		return err
+	}
+
+	fmt.Fprint(res, "retry: 3000\n\n")
+	if !complete {
+		fmt.Fprintf(res, "id: %d\nevent: reset\ndata: {}\n\n", h.TaskService.Events.LastID())
+	}
+	for _, event := range replay {
+		if err := send(event); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(eventHeartbeat)
+	defer heartbeat.Stop()
+
+	// Like the WebSocket, the stream ends when its token expires or is
+	// revoked; the client reconnects with a fresh one.
+	var expired <-chan time.Time
+	if expires, ok := credentialExpiry(req); ok {
+		timer := time.NewTimer(time.Until(expires))
+		defer timer.Stop()
+		expired = timer.C
+	}
+	watch := newCredentialWatch(h, req)
+
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+		case <-expired:
+			return
+		case event, ok := <-events:
+			if !ok || watch.revoked() {
+				return
+			}
+			if err := send(event); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if watch.revoked() {
+				return
+			}
+			if _, err := fmt.Fprint(res, ": ping\n\n"); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// lastEventID reads the Last-Event-ID header, or the last_event_id query
+// parameter for clients that cannot set headers.
+func lastEventID(req *http.Request) (int64, error) {
+	value := req.Header.Get("Last-Event-ID")
+	if value == "" {
+		value = req.URL.Query().Get("last_event_id")
+	}
+	if value == "" {
+		return 0, nil
+	}
+
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || id < 0 {
+		return 0, utils.NewError("invalid_value", "Last-Event-ID")
+	}
+	return id, nil
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/antonkazachenko/go-todo-list-api/internal/entities"
+	"github.com/antonkazachenko/go-todo-list-api/internal/service"
+	"github.com/antonkazachenko/go-todo-list-api/utils"
+)
+
+const oidcStateCookie = "oidc_state"
+
+func (h *Handlers) HandleOIDCLogin(res http.ResponseWriter, req *http.Request) {
+	if !h.OIDCService.Enabled() {
+		utils.SendErrorResponse(res, req, service.ErrOIDCDisabled, http.StatusNotFound)
+		return
+	}
+
+	authURL, state, err := h.OIDCService.Begin(req.Context(), callbackURL(req))
+	if err != nil {
+		log.Printf("OIDC login failed: %v", err)
+		utils.SendErrorResponse(res, req, utils.NewError("oidc_unavailable"), http.StatusBadGateway)
+		return
+	}
+
+	http.SetCookie(res, &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    state,
+		Path:     "/api/signin/oidc",
+		MaxAge:   600,
+		HttpOnly: true,
+		Secure:   req.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(res, req, authURL, http.StatusFound)
+}
+
+func (h *Handlers) HandleOIDCCallback(res http.ResponseWriter, req *http.Request) {
+	query := req.URL.Query()
+	if providerErr := query.Get("error"); providerErr != "" {
+		utils.SendErrorResponse(res, req, utils.NewError("oidc_denied", providerErr), http.StatusUnauthorized)
+		return
+	}
+
+	state := query.Get("state")
+	cookie, err := req.Cookie(oidcStateCookie)
+	if err != nil || state == "" || cookie.Value != state {
+		utils.SendErrorResponse(res, req, service.ErrOIDCInvalidState, http.StatusBadRequest)
+		return
+	}
+	http.SetCookie(res, &http.Cookie{Name: oidcStateCookie, Path: "/api/signin/oidc", MaxAge: -1})
+
+	user, err := h.OIDCService.Finish(req.Context(), state, query.Get("code"))
+	switch {
+	case errors.Is(err, service.ErrOIDCInvalidState):
+		utils.SendErrorResponse(res, req, err, http.StatusBadRequest)
+		return
+	case errors.Is(err, service.ErrOIDCInvalidToken):
+		utils.SendErrorResponse(res, req, err, http.StatusUnauthorized)
+		return
+	case err != nil:
+		log.Printf("OIDC callback failed: %v", err)
+		utils.SendErrorResponse(res, req, utils.NewError("oidc_failed"), http.StatusBadGateway)
+		return
+	}
+
+	h.redirectSignedIn(res, req, user)
+}
+
+// redirectSignedIn ends a browser sign-in on the login page with what
+// HandleSignIn would answer, in the URL fragment so that it is not sent back
+// to the server: an MFA token for users with TOTP, otherwise a session's
+// access and refresh tokens.
+func (h *Handlers) redirectSignedIn(res http.ResponseWriter, req *http.Request, user *entities.User) {
+	fragment := url.Values{}
+	if user.TOTPEnabled {
+		mfaToken, err := h.TokenService.IssueMFAToken(user)
+		if err != nil {
+			utils.SendErrorResponse(res, req, utils.NewError("token_error"), http.StatusInternalServerError)
+			return
+		}
+		fragment.Set("mfa_required", "true")
+		fragment.Set("mfa_token", mfaToken)
+	} else {
+		pair, err := h.TokenService.IssueTokens(user, req.UserAgent(), clientIP(req))
+		if err != nil {
+			utils.SendErrorResponse(res, req, utils.NewError("token_error"), http.StatusInternalServerError)
+			return
+		}
+		fragment.Set("token", pair.AccessToken)
+		fragment.Set("refresh_token", pair.RefreshToken)
+		fragment.Set("expires_in", strconv.FormatInt(int64(pair.ExpiresIn.Seconds()), 10))
+	}
+
+	res.Header().Set("Cache-Control", "no-store")
+	http.Redirect(res, req, "/login.html#"+fragment.Encode(), http.StatusFound)
+}
+
+func callbackURL(req *http.Request) string {
+	scheme := "http"
+	if req.TLS != nil || req.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + req.Host + "/api/signin/oidc/callback"
+}
@@ -0,0 +1,382 @@
+package handlers
+
+import (
+	"encoding/json"
+	"go/token"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/antonkazachenko/go-todo-list-api/internal/entities"
+	"github.com/antonkazachenko/go-todo-list-api/models"
+)
+
+// Who may call an operation besides the scoped task routes; public routes
+// leave access empty.
+const (
+	accessSession = "session"
+	accessAdmin   = "admin"
+)
+
+// apiOperation describes one route for the OpenAPI document. request and
+// response are Go values whose types give the JSON schema, or a schema
+// built by hand; requestType and responseType override application/json.
+type apiOperation struct {
+	method       string
+	path         string
+	tag          string
+	summary      string
+	access       string
+	scope        string
+	query        []string
+	request      interface{}
+	requestType  string
+	status       int
+	response     interface{}
+	responseType string
+}
+
+type schema map[string]interface{}
+
+// listOf is an object with a single array property, the shape of every list
+// endpoint; item is resolved like request and response.
+type listOf struct {
+	key  string
+	item interface{}
+}
+
+var (
+	emptyObject  = schema{"type": "object"}
+	textBody     = schema{"type": "string"}
+	auditFilters = []string{"task_id", "user_id", "owner_id", "action", "from", "to", "before_id", "limit"}
+)
+
+var apiOperations = []apiOperation{
+	{method: "GET", path: "/api/nextdate", tag: "tasks", summary: "Next date of a repeat rule", query: []string{"now", "date", "repeat"}, response: textBody, responseType: "text/plain"},
+	{method: "POST", path: "/api/task", tag: "tasks", summary: "Create a task", scope: entities.ScopeTasksWrite, query: []string{"owner"}, request: entities.Task{}, response: models.IDResponse{}},
+	{method: "GET", path: "/api/tasks", tag: "tasks", summary: "List tasks", scope: entities.ScopeTasksRead, query: []string{"search", "owner"}, response: listOf{"tasks", entities.Task{}}},
+	{method: "GET", path: "/api/task", tag: "tasks", summary: "Get a task", scope: entities.ScopeTasksRead, query: []string{"id"}, response: entities.Task{}},
+	{method: "PUT", path: "/api/task", tag: "tasks", summary: "Replace a task", scope: entities.ScopeTasksWrite, request: entities.Task{}, response: emptyObject},
+	{method: "PATCH", path: "/api/task", tag: "tasks", summary: "Change some fields of a task (JSON Merge Patch)", scope: entities.ScopeTasksWrite, request: entities.Task{}, requestType: "application/merge-patch+json", response: entities.Task{}},
+	{method: "DELETE", path: "/api/task", tag: "tasks", summary: "Delete a task", scope: entities.ScopeTasksWrite, query: []string{"id", "version"}, response: emptyObject},
+	{method: "POST", path: "/api/task/done", tag: "tasks", summary: "Mark a task as done", scope: entities.ScopeTasksWrite, query: []string{"id", "version"}, response: emptyObject},
+	{method: "POST", path: "/api/tasks/batch", tag: "tasks", summary: "Run create, update, delete and done operations in one transaction", scope: entities.ScopeTasksWrite, query: []string{"owner"}, request: batchRequest{}, response: models.BatchResponse{}},
+	{method: "POST", path: "/api/tasks/bulk", tag: "tasks", summary: "Reschedule, complete, delete or change every task matching a filter", scope: entities.ScopeTasksWrite, query: []string{"search", "from", "to", "owner", "dry_run"}, request: bulkRequest{}, response: models.BulkResponse{}},
+	{method: "GET", path: "/api/events", tag: "tasks", summary: "Server-Sent Events stream of task changes; each data line is a TaskEvent", scope: entities.ScopeTasksRead, query: []string{"last_event_id"}, response: entities.TaskEvent{}, responseType: "text/event-stream"},
+	{method: "GET", path: "/api/ws", tag: "tasks", summary: "WebSocket for live task lists; the server sends SocketSnapshot and SocketMessage frames", scope: entities.ScopeTasksRead, status: http.StatusSwitchingProtocols, response: models.SocketMessage{}},
+	{method: "GET", path: "/api/sync", tag: "tasks", summary: "Tasks created, changed and deleted since a sync token", scope: entities.ScopeTasksRead, query: []string{"since", "owner"}, response: models.SyncResponse{}},
+	{method: "POST", path: "/api/sync", tag: "tasks", summary: "Apply offline changes with per-field last-writer-wins", scope: entities.ScopeTasksWrite, query: []string{"owner"}, request: syncRequest{}, response: models.SyncResponse{}},
+
+	{method: "GET", path: "/api/v2/tasks", tag: "tasks v2", summary: "List tasks", scope: entities.ScopeTasksRead, query: []string{"search", "owner"}, response: models.TasksV2Response{}},
+	{method: "POST", path: "/api/v2/tasks", tag: "tasks v2", summary: "Create a task", scope: entities.ScopeTasksWrite, query: []string{"owner"}, request: models.TaskV2{}, status: http.StatusCreated, response: models.TaskV2{}},
+	{method: "GET", path: "/api/v2/tasks/{id}", tag: "tasks v2", summary: "Get a task", scope: entities.ScopeTasksRead, response: models.TaskV2{}},
+	{method: "PUT", path: "/api/v2/tasks/{id}", tag: "tasks v2", summary: "Replace a task", scope: entities.ScopeTasksWrite, request: models.TaskV2{}, response: models.TaskV2{}},
+	{method: "PATCH", path: "/api/v2/tasks/{id}", tag: "tasks v2", summary: "Change some fields of a task (JSON Merge Patch)", scope: entities.ScopeTasksWrite, request: models.TaskV2{}, requestType: "application/merge-patch+json", response: models.TaskV2{}},
+	{method: "DELETE", path: "/api/v2/tasks/{id}", tag: "tasks v2", summary: "Delete a task", scope: entities.ScopeTasksWrite, query: []string{"version"}, status: http.StatusNoContent},
+	{method: "POST", path: "/api/v2/tasks/{id}/done", tag: "tasks v2", summary: "Complete a task; 204 when a one-off task is removed", scope: entities.ScopeTasksWrite, query: []string{"version"}, response: models.TaskV2{}},
+
+	{method: "GET", path: "/api/shares", tag: "sharing", summary: "List shares", scope: entities.ScopeTasksRead, query: []string{"owner"}, response: listOf{"shares", entities.Share{}}},
+	{method: "POST", path: "/api/shares", tag: "sharing", summary: "Share a list", scope: entities.ScopeTasksWrite, request: shareRequest{}, response: entities.Share{}},
+	{method: "PUT", path: "/api/shares", tag: "sharing", summary: "Change a share", scope: entities.ScopeTasksWrite, request: shareRequest{}, response: entities.Share{}},
+	{method: "DELETE", path: "/api/shares", tag: "sharing", summary: "Revoke a share", scope: entities.ScopeTasksWrite, query: []string{"id"}, response: emptyObject},
+	{method: "GET", path: "/api/links", tag: "sharing", summary: "List public links", scope: entities.ScopeTasksRead, response: listOf{"links", entities.ShareLink{}}},
+	{method: "POST", path: "/api/links", tag: "sharing", summary: "Create a public link", scope: entities.ScopeTasksWrite, request: shareLinkRequest{}, response: struct {
+		Token string `json:"token"`
+		URL   string `json:"url"`
+		*entities.ShareLink
+	}{}},
+	{method: "DELETE", path: "/api/links", tag: "sharing", summary: "Revoke a public link", scope: entities.ScopeTasksWrite, query: []string{"id"}, response: emptyObject},
+	{method: "GET", path: "/api/public/{token}", tag: "sharing", summary: "Tasks behind a public link", response: publicTasksResponse{}},
+	{method: "POST", path: "/api/public/{token}", tag: "sharing", summary: "Tasks behind a password-protected public link", request: struct {
+		Password string `json:"password"`
+	}{}, response: publicTasksResponse{}},
+	{method: "GET", path: "/s/{token}", tag: "sharing", summary: "Public link page", response: textBody, responseType: "text/html"},
+	{method: "POST", path: "/s/{token}", tag: "sharing", summary: "Public link page with a password", request: schema{"type": "object", "properties": map[string]interface{}{"password": textBody}}, requestType: "application/x-www-form-urlencoded", response: textBody, responseType: "text/html"},
+
+	{method: "GET", path: "/api/webhooks", tag: "webhooks", summary: "List webhooks, or one webhook with id", scope: entities.ScopeTasksRead, query: []string{"id"}, response: listOf{"webhooks", entities.Webhook{}}},
+	{method: "POST", path: "/api/webhooks", tag: "webhooks", summary: "Register a webhook; the signing secret is only returned here", scope: entities.ScopeTasksWrite, request: webhookRequest{}, status: http.StatusCreated, response: webhookWithSecret{}},
+	{method: "PUT", path: "/api/webhooks", tag: "webhooks", summary: "Change a webhook or rotate its secret", scope: entities.ScopeTasksWrite, query: []string{"id"}, request: webhookRequest{}, response: webhookWithSecret{}},
+	{method: "DELETE", path: "/api/webhooks", tag: "webhooks", summary: "Delete a webhook and its delivery log", scope: entities.ScopeTasksWrite, query: []string{"id"}, response: emptyObject},
+	{method: "GET", path: "/api/webhooks/deliveries", tag: "webhooks", summary: "Latest deliveries of a webhook, newest first", scope: entities.ScopeTasksRead, query: []string{"id"}, response: listOf{"deliveries", entities.WebhookDelivery{}}},
+	{method: "POST", path: "/api/webhooks/redeliver", tag: "webhooks", summary: "Queue a delivery again", scope: entities.ScopeTasksWrite, query: []string{"id"}, status: http.StatusAccepted, response: entities.WebhookDelivery{}},
+
+	{method: "GET", path: "/api/audit", tag: "audit", summary: "Audit log, newest first", scope: entities.ScopeTasksRead, query: auditFilters, response: listOf{"entries", entities.AuditEntry{}}},
+	{method: "GET", path: "/api/audit.csv", tag: "audit", summary: "Audit log as CSV", scope: entities.ScopeTasksRead, query: auditFilters, response: textBody, responseType: "text/csv"},
+
+	{method: "GET", path: "/api/export.csv", tag: "import and export", summary: "Export tasks as CSV", scope: entities.ScopeTasksRead, query: []string{"delimiter", "columns"}, response: textBody, responseType: "text/csv"},
+	{method: "POST", path: "/api/import/csv", tag: "import and export", summary: "Import tasks from CSV", scope: entities.ScopeTasksWrite, query: []string{"delimiter", "columns", "dry_run"}, request: textBody, requestType: "text/csv", response: models.ImportResponse{}},
+	{method: "GET", path: "/api/export.txt", tag: "import and export", summary: "Export tasks as todo.txt", scope: entities.ScopeTasksRead, response: textBody, responseType: "text/plain"},
+	{method: "POST", path: "/api/import/todotxt", tag: "import and export", summary: "Import a todo.txt file", scope: entities.ScopeTasksWrite, query: []string{"dry_run"}, request: textBody, requestType: "text/plain", response: models.ImportResponse{}},
+	{method: "GET", path: "/api/backup", tag: "import and export", summary: "Download a backup", access: accessAdmin, response: entities.Backup{}},
+	{method: "POST", path: "/api/restore", tag: "import and export", summary: "Restore a backup", access: accessAdmin, request: entities.Backup{}, response: struct {
+		RestoredAt time.Time      `json:"restored_at"`
+		Tables     map[string]int `json:"tables"`
+	}{}},
+
+	{method: "POST", path: "/api/signin", tag: "authentication", summary: "Sign in", request: credentials{}, response: models.AuthResponse{}},
+	{method: "POST", path: "/api/signin/2fa", tag: "authentication", summary: "Finish a sign-in with a TOTP or recovery code", request: struct {
+		MFAToken string `json:"mfa_token"`
+		Code     string `json:"code"`
+	}{}, response: models.AuthResponse{}},
+	{method: "GET", path: "/api/signin/oidc", tag: "authentication", summary: "Start an OpenID Connect sign-in", status: http.StatusFound},
+	{method: "GET", path: "/api/signin/oidc/callback", tag: "authentication", summary: "OpenID Connect callback", query: []string{"code", "state"}, status: http.StatusFound},
+	{method: "POST", path: "/api/signup", tag: "authentication", summary: "Create an account", request: credentials{}, response: models.AuthResponse{}},
+	{method: "POST", path: "/api/refresh", tag: "authentication", summary: "Exchange a refresh token", request: struct {
+		RefreshToken string `json:"refresh_token"`
+	}{}, response: models.AuthResponse{}},
+	{method: "POST", path: "/api/signout", tag: "authentication", summary: "Sign out", access: accessSession, response: emptyObject},
+	{method: "GET", path: "/api/sessions", tag: "authentication", summary: "List sessions", access: accessSession, response: listOf{"sessions", entities.Session{}}},
+	{method: "DELETE", path: "/api/sessions", tag: "authentication", summary: "Revoke a session", access: accessSession, query: []string{"id"}, response: emptyObject},
+	{method: "GET", path: "/.well-known/jwks.json", tag: "authentication", summary: "Public signing keys", response: models.JWKSet{}},
+	{method: "GET", path: "/api/me", tag: "authentication", summary: "Current user", access: accessSession, response: entities.User{}},
+	{method: "POST", path: "/api/2fa/enroll", tag: "authentication", summary: "Start TOTP enrollment", access: accessSession, response: struct {
+		Secret     string `json:"secret"`
+		OTPAuthURI string `json:"otpauth_uri"`
+	}{}},
+	{method: "POST", path: "/api/2fa/confirm", tag: "authentication", summary: "Confirm TOTP enrollment", access: accessSession, request: codeRequest{}, response: recoveryCodes},
+	{method: "POST", path: "/api/2fa/disable", tag: "authentication", summary: "Disable TOTP", access: accessSession, request: codeRequest{}, response: emptyObject},
+	{method: "POST", path: "/api/2fa/recovery-codes", tag: "authentication", summary: "Regenerate recovery codes", access: accessSession, request: codeRequest{}, response: recoveryCodes},
+	{method: "GET", path: "/api/tokens", tag: "authentication", summary: "List personal access tokens", access: accessSession, response: listOf{"tokens", entities.AccessToken{}}},
+	{method: "POST", path: "/api/tokens", tag: "authentication", summary: "Create a personal access token", access: accessSession, request: accessTokenRequest{}, response: struct {
+		Token string `json:"token"`
+		*entities.AccessToken
+	}{}},
+	{method: "DELETE", path: "/api/tokens", tag: "authentication", summary: "Revoke a personal access token", access: accessSession, query: []string{"id"}, response: emptyObject},
+
+	{method: "GET", path: "/api/users", tag: "users", summary: "List users", access: accessAdmin, response: listOf{"users", entities.User{}}},
+	{method: "POST", path: "/api/users", tag: "users", summary: "Create a user", access: accessAdmin, request: userRequest{}, response: entities.User{}},
+	{method: "PUT", path: "/api/users", tag: "users", summary: "Change a user", access: accessAdmin, query: []string{"id"}, request: userRequest{}, response: entities.User{}},
+	{method: "DELETE", path: "/api/users", tag: "users", summary: "Delete a user", access: accessAdmin, query: []string{"id"}, response: emptyObject},
+
+	{method: "GET", path: "/api/openapi.json", tag: "meta", summary: "This document", response: emptyObject},
+}
+
+var recoveryCodes = listOf{"recovery_codes", textBody}
+
+var (
+	openAPIOnce     sync.Once
+	openAPIDocument []byte
+)
+
+func (h *Handlers) HandleOpenAPI(res http.ResponseWriter, req *http.Request) {
+	openAPIOnce.Do(func() {
+		openAPIDocument, _ = json.Marshal(OpenAPI())
+	})
+
+	res.Header().Set("Content-Type", "application/json")
+	res.Write(openAPIDocument)
+}
+
+var pathParam = regexp.MustCompile(`\{(\w+)\}`)
+
+// OpenAPI builds the OpenAPI 3 document for every route in apiOperations.
+func OpenAPI() map[string]interface{} {
+	components := map[string]interface{}{}
+	paths := map[string]map[string]interface{}{}
+
+	for _, op := range apiOperations {
+		operation := map[string]interface{}{
+			"tags":        []string{op.tag},
+			"summary":     op.summary,
+			"operationId": operationID(op),
+		}
+
+		var parameters []interface{}
+		for _, match := range pathParam.FindAllStringSubmatch(op.path, -1) {
+			parameters = append(parameters, schema{"name": match[1], "in": "path", "required": true, "schema": textBody})
+		}
+		for _, name := range op.query {
+			parameters = append(parameters, schema{"name": name, "in": "query", "schema": textBody})
+		}
+		if parameters != nil {
+			operation["parameters"] = parameters
+		}
+
+		if op.request != nil {
+			operation["requestBody"] = schema{
+				"required": true,
+				"content":  content(orDefault(op.requestType, "application/json"), schemaOf(op.request, components)),
+			}
+		}
+
+		status := op.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		success := schema{"description": http.StatusText(status)}
+		if op.response != nil {
+			success["content"] = content(orDefault(op.responseType, "application/json"), schemaOf(op.response, components))
+		}
+		operation["responses"] = map[string]interface{}{
+			strconv.Itoa(status): success,
+			"default": schema{
+				"description": "Error",
+				"content":     content("application/json", schemaOf(models.ErrorResponse{}, components)),
+			},
+		}
+
+		switch {
+		case op.access == accessAdmin:
+			operation["security"] = securedBy()
+			operation["x-scopes"] = []string{entities.ScopeAdmin}
+			operation["description"] = "Administrators only; personal access tokens need the admin scope."
+		case op.scope != "":
+			operation["security"] = securedBy()
+			operation["x-scopes"] = []string{op.scope}
+			operation["description"] = "Personal access tokens need the " + op.scope + " scope."
+		case op.access == accessSession:
+			operation["security"] = securedBy()
+		default:
+			operation["security"] = []interface{}{}
+		}
+
+		if paths[op.path] == nil {
+			paths[op.path] = map[string]interface{}{}
+		}
+		paths[op.path][strings.ToLower(op.method)] = operation
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": schema{
+			"title":   "go-todo-list-api",
+			"version": "2",
+		},
+		"paths": paths,
+		"components": schema{
+			"schemas": components,
+			"securitySchemes": schema{
+				"cookieAuth": schema{"type": "apiKey", "in": "cookie", "name": "token"},
+				"bearerAuth": schema{"type": "http", "scheme": "bearer", "description": "A session JWT or a personal access token"},
+			},
+		},
+	}
+}
+
+func securedBy() []interface{} {
+	return []interface{}{schema{"cookieAuth": []string{}}, schema{"bearerAuth": []string{}}}
+}
+
+func content(contentType string, body interface{}) schema {
+	return schema{contentType: schema{"schema": body}}
+}
+
+func orDefault(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+func operationID(op apiOperation) string {
+	id := strings.ToLower(op.method)
+	for _, part := range strings.FieldsFunc(op.path, func(r rune) bool {
+		return !('a' <= r && r <= 'z' || '0' <= r && r <= '9')
+	}) {
+		id += strings.ToUpper(part[:1]) + part[1:]
+	}
+	return id
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// schemaOf returns the JSON schema of value. Exported named structs are
+// registered in components and referenced, everything else is inlined.
+func schemaOf(value interface{}, components map[string]interface{}) interface{} {
+	switch v := value.(type) {
+	case schema:
+		return v
+	case listOf:
+		return schema{
+			"type":       "object",
+			"properties": schema{v.key: schema{"type": "array", "items": schemaOf(v.item, components)}},
+		}
+	}
+	return schemaFor(reflect.TypeOf(value), components)
+}
+
+func schemaFor(t reflect.Type, components map[string]interface{}) schema {
+	if t == rawMessageType {
+		return schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		s := schemaFor(t.Elem(), components)
+		if _, ok := s["$ref"]; ok {
+			return schema{"allOf": []interface{}{s}, "nullable": true}
+		}
+		s["nullable"] = true
+		return s
+	case reflect.String:
+		return schema{"type": "string"}
+	case reflect.Bool:
+		return schema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return schema{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return schema{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return schema{"type": "array", "items": schemaFor(t.Elem(), components)}
+	case reflect.Map:
+		return schema{"type": "object", "additionalProperties": schemaFor(t.Elem(), components)}
+	case reflect.Interface:
+		return schema{}
+	}
+
+	if t == timeType {
+		return schema{"type": "string", "format": "date-time"}
+	}
+
+	name := t.Name()
+	if name == "" || !token.IsExported(name) {
+		return structSchema(t, components)
+	}
+	if _, ok := components[name]; !ok {
+		components[name] = schema{}
+		components[name] = structSchema(t, components)
+	}
+	return schema{"$ref": "#/components/schemas/" + name}
+}
+
+func structSchema(t reflect.Type, components map[string]interface{}) schema {
+	properties := map[string]interface{}{}
+	addFields(t, components, properties)
+	return schema{"type": "object", "properties": properties}
+}
+
+func addFields(t reflect.Type, components map[string]interface{}, properties map[string]interface{}) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			addFields(embedded, components, properties)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		properties[name] = schemaFor(field.Type, components)
+	}
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/antonkazachenko/go-todo-list-api/internal/entities"
+	"github.com/antonkazachenko/go-todo-list-api/internal/service"
+	storage "github.com/antonkazachenko/go-todo-list-api/internal/storage/sqlite"
+	"github.com/antonkazachenko/go-todo-list-api/utils"
+)
+
+type shareRequest struct {
+	ID     int64   `json:"id"`
+	Owner  int64   `json:"owner"`
+	Login  string  `json:"login"`
+	Role   string  `json:"role"`
+	Filter *string `json:"filter"`
+}
+
+func (h *Handlers) HandleGetShares(res http.ResponseWriter, req *http.Request) {
+	ownerID, err := listOwnerID(req)
+	if err != nil {
+		utils.SendErrorResponse(res, req, err, http.StatusBadRequest)
+		return
+	}
+
+	shares, err := h.TaskService.GetShares(currentUserID(req), ownerID)
+	if err != nil {
+		sendShareError(res, req, err)
+		return
+	}
+
+	if len(shares) == 0 {
+		shares = []entities.Share{}
+	}
+
+	sendJSONResponse(res, http.StatusOK, map[string][]entities.Share{"shares": shares})
+}
+
+func (h *Handlers) HandleAddShare(res http.ResponseWriter, req *http.Request) {
+	var body shareRequest
+	if err := parseRequestBody(req, &body); err != nil {
+		utils.SendErrorResponse(res, req, utils.NewError("invalid_json"), http.StatusBadRequest)
+		return
+	}
+
+	ownerID := body.Owner
+	if ownerID == 0 {
+		ownerID = currentUserID(req)
+	}
+
+	target, err := h.UserService.Repo.GetUserByLogin(strings.TrimSpace(body.Login))
+	if errors.Is(err, storage.ErrUserNotFound) {
+		utils.SendErrorResponse(res, req, utils.NewError("user_not_found/login"), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		utils.SendErrorResponse(res, req, utils.NewError("database_error"), http.StatusInternalServerError)
+		return
+	}
+
+	filter := ""
+	if body.Filter != nil {
+		filter = *body.Filter
+	}
+
+	share, err := h.TaskService.AddShare(currentUserID(req), ownerID, target, body.Role, filter)
+	if err != nil {
+		sendShareError(res, req, err)
+		return
+	}
+
+	sendJSONResponse(res, http.StatusOK, share)
+}
+
+func (h *Handlers) HandlePutShare(res http.ResponseWriter, req *http.Request) {
+	var body shareRequest
+	if err := parseRequestBody(req, &body); err != nil {
+		utils.SendErrorResponse(res, req, utils.NewError("invalid_json"), http.StatusBadRequest)
+		return
+	}
+	if body.ID <= 0 {
+		utils.SendErrorResponse(res, req, utils.NewError("required", "id"), http.StatusBadRequest)
+		return
+	}
+
+	share, err := h.TaskService.UpdateShare(currentUserID(req), body.ID, body.Role, body.Filter)
+	if err != nil {
+		sendShareError(res, req, err)
+		return
+	}
+
+	sendJSONResponse(res, http.StatusOK, share)
+}
+
+func (h *Handlers) HandleDeleteShare(res http.ResponseWriter, req *http.Request) {
+	id, err := parseInt64ID(req)
+	if err != nil {
+		utils.SendErrorResponse(res, req, err, http.StatusBadRequest)
+		return
+	}
+
+	if err := h.TaskService.DeleteShare(currentUserID(req), id); err != nil {
+		sendShareError(res, req, err)
+		return
+	}
+
+	sendJSONResponse(res, http.StatusOK, map[string]interface{}{})
+}
+
+func sendShareError(res http.ResponseWriter, req *http.Request, err error) {
+	switch {
+	case errors.Is(err, service.ErrListNotFound), errors.Is(err, service.ErrShareNotFound):
+		utils.SendErrorResponse(res, req, err, http.StatusNotFound)
+	case errors.Is(err, service.ErrPermissionDenied), errors.Is(err, service.ErrOwnShare), errors.Is(err, service.ErrFilterTooWide):
+		utils.SendErrorResponse(res, req, err, http.StatusForbidden)
+	case errors.Is(err, service.ErrShareExists):
+		utils.SendErrorResponse(res, req, err, http.StatusConflict)
+	case errors.Is(err, service.ErrInvalidShareRole), errors.Is(err, service.ErrFilterTooLong), errors.Is(err, service.ErrShareWithOwner):
+		utils.SendErrorResponse(res, req, err, http.StatusBadRequest)
+	default:
+		sendDatabaseError(res, req, err)
+	}
+}
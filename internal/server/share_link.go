@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"errors"
+	"html/template"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/antonkazachenko/go-todo-list-api/internal/entities"
+	"github.com/antonkazachenko/go-todo-list-api/internal/service"
+	"github.com/antonkazachenko/go-todo-list-api/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+type shareLinkRequest struct {
+	Name          string   `json:"name"`
+	Filter        string   `json:"filter"`
+	TaskIDs       []string `json:"task_ids"`
+	Password      string   `json:"password"`
+	ExpiresInDays int      `json:"expires_in_days"`
+}
+
+type publicTasksResponse struct {
+	Name    string          `json:"name"`
+	Expires *time.Time      `json:"expires"`
+	Tasks   []entities.Task `json:"tasks"`
+}
+
+var shareLinkPage = template.Must(template.New("share").Parse(`<!DOCTYPE html>
+<html lang="ru">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>{{if .Name}}{{.Name}}{{else}}Задачи{{end}}</title>
+<link rel="stylesheet" href="/css/style.css">
+</head>
+<body>
+<h1>{{if .Name}}{{.Name}}{{else}}Задачи{{end}}</h1>
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+{{if .AskPassword}}
+<form method="post">
+<input type="password" name="password" placeholder="Пароль" autofocus required>
+<button type="submit">Открыть</button>
+</form>
+{{else if not .Error}}
+<table>
+<thead><tr><th>Дата</th><th>Заголовок</th><th>Комментарий</th><th>Повтор</th></tr></thead>
+<tbody>
+{{range .Tasks}}<tr><td>{{.Date}}</td><td>{{.Title}}</td><td>{{.Comment}}</td><td>{{.Repeat}}</td></tr>
+{{else}}<tr><td colspan="4">Задач нет</td></tr>
+{{end}}</tbody>
+</table>
+{{end}}
+</body>
+</html>
+`))
+
+func (h *Handlers) HandleGetShareLinks(res http.ResponseWriter, req *http.Request) {
+	links, err := h.ShareLinkService.Repo.GetShareLinksByOwner(currentUserID(req))
+	if err != nil {
+		utils.SendErrorResponse(res, req, utils.NewError("database_error"), http.StatusInternalServerError)
+		return
+	}
+
+	if len(links) == 0 {
+		links = []entities.ShareLink{}
+	}
+
+	sendJSONResponse(res, http.StatusOK, map[string][]entities.ShareLink{"links": links})
+}
+
+func (h *Handlers) HandleAddShareLink(res http.ResponseWriter, req *http.Request) {
+	var body shareLinkRequest
+	if err := parseRequestBody(req, &body); err != nil {
+		utils.SendErrorResponse(res, req, utils.NewError("invalid_json"), http.StatusBadRequest)
+		return
+	}
+
+	ttl := time.Duration(body.ExpiresInDays) * 24 * time.Hour
+	token, link, err := h.ShareLinkService.Create(currentUserID(req), body.Name, body.Filter, body.TaskIDs, body.Password, ttl)
+	if err != nil {
+		utils.SendErrorResponse(res, req, err, http.StatusBadRequest)
+		return
+	}
+
+	sendJSONResponse(res, http.StatusOK, struct {
+		Token string `json:"token"`
+		URL   string `json:"url"`
+		*entities.ShareLink
+	}{token, service.ShareLinkPath + token, link})
+}
+
+func (h *Handlers) HandleDeleteShareLink(res http.ResponseWriter, req *http.Request) {
+	id, err := parseInt64ID(req)
+	if err != nil {
+		utils.SendErrorResponse(res, req, err, http.StatusBadRequest)
+		return
+	}
+
+	deleted, err := h.ShareLinkService.Repo.DeleteShareLink(currentUserID(req), id)
+	if err != nil {
+		utils.SendErrorResponse(res, req, utils.NewError("database_error"), http.StatusInternalServerError)
+		return
+	}
+	if deleted == 0 {
+		utils.SendErrorResponse(res, req, utils.NewError("link_not_found/id"), http.StatusNotFound)
+		return
+	}
+
+	sendJSONResponse(res, http.StatusOK, map[string]interface{}{})
+}
+
+// HandlePublicTasks serves the JSON view of a share link. Password protected
+// links take the password in a POST body.
+func (h *Handlers) HandlePublicTasks(res http.ResponseWriter, req *http.Request) {
+	var body struct {
+		Password string `json:"password"`
+	}
+	if req.Method == http.MethodPost {
+		if err := parseRequestBody(req, &body); err != nil {
+			utils.SendErrorResponse(res, req, utils.NewError("invalid_json"), http.StatusBadRequest)
+			return
+		}
+	}
+
+	setPublicHeaders(res)
+	link, tasks, err := h.ShareLinkService.Open(chi.URLParam(req, "token"), body.Password, clientIP(req))
+	if err != nil {
+		if !sendRetryAfter(res, req, err, "share link", clientIP(req)) {
+			status, apiErr := shareLinkError(err)
+			utils.SendErrorResponse(res, req, apiErr, status)
+		}
+		return
+	}
+
+	if len(tasks) == 0 {
+		tasks = []entities.Task{}
+	}
+
+	sendJSONResponse(res, http.StatusOK, publicTasksResponse{Name: link.Name, Expires: link.Expires, Tasks: tasks})
+}
+
+// HandleSharePage renders a share link as a read-only HTML page with a
+// password form for protected links.
+func (h *Handlers) HandleSharePage(res http.ResponseWriter, req *http.Request) {
+	setPublicHeaders(res)
+	res.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'self'; form-action 'self'")
+	res.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+	link, tasks, err := h.ShareLinkService.Open(chi.URLParam(req, "token"), req.PostFormValue("password"), clientIP(req))
+
+	data := struct {
+		Name        string
+		Error       string
+		AskPassword bool
+		Tasks       []entities.Task
+	}{Tasks: tasks}
+	status := http.StatusOK
+	if err != nil {
+		var pageErr error
+		status, pageErr = shareLinkError(err)
+		data.Error = pageErr.Error()
+		data.AskPassword = status == http.StatusUnauthorized
+		if errors.Is(err, service.ErrShareLinkPassword) {
+			data.Error = ""
+		}
+	} else {
+		data.Name = link.Name
+	}
+
+	res.WriteHeader(status)
+	if err := shareLinkPage.Execute(res, data); err != nil {
+		log.Printf("Failed to render share link page: %v", err)
+	}
+}
+
+func setPublicHeaders(res http.ResponseWriter) {
+	res.Header().Set("Cache-Control", "no-store")
+	res.Header().Set("Referrer-Policy", "no-referrer")
+	res.Header().Set("X-Robots-Tag", "noindex")
+}
+
+func shareLinkError(err error) (int, error) {
+	var retryErr *service.RetryAfterError
+	switch {
+	case errors.Is(err, service.ErrShareLinkNotFound):
+		return http.StatusNotFound, err
+	case errors.Is(err, service.ErrShareLinkPassword), errors.Is(err, service.ErrShareLinkBadPassword):
+		return http.StatusUnauthorized, err
+	case errors.As(err, &retryErr):
+		return http.StatusTooManyRequests, err
+	default:
+		return http.StatusInternalServerError, utils.NewError("database_error")
+	}
+}
@@ -0,0 +1,317 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/antonkazachenko/go-todo-list-api/internal/entities"
+	"github.com/antonkazachenko/go-todo-list-api/internal/service"
+	"github.com/antonkazachenko/go-todo-list-api/middleware"
+	"github.com/antonkazachenko/go-todo-list-api/models"
+	"github.com/antonkazachenko/go-todo-list-api/utils"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	maxSocketMessage       = 1 << 20
+	maxSocketSubscriptions = 50
+	socketSnapshotLimit    = 100
+	socketPongWait         = 2 * eventHeartbeat
+	socketWriteWait        = 10 * time.Second
+)
+
+// The default origin check refuses cross-site pages, which would otherwise
+// connect with the user's cookie.
+var socketUpgrader = websocket.Upgrader{}
+
+type socketRequest struct {
+	Type    string          `json:"type"`
+	Ref     string          `json:"ref"`
+	Search  string          `json:"search"`
+	Owner   int64           `json:"owner"`
+	ID      string          `json:"id"`
+	Version json.RawMessage `json:"version"`
+	Task    json.RawMessage `json:"task"`
+
+	err error
+}
+
+// socketQuery is a subscription: a task list and the ids of its tasks the
+// client has been sent. Events up to since are already in the snapshot.
+type socketQuery struct {
+	ownerID int64
+	search  string
+	since   int64
+	ids     map[string]bool
+}
+
+type socketSession struct {
+	h       *Handlers
+	conn    *websocket.Conn
+	req     *http.Request
+	userID  int64
+	queries map[string]*socketQuery
+}
+
+// HandleSocket serves a WebSocket on which the client subscribes to task
+// lists and gets their changes as add, update and remove messages, and can
+// create, update, delete and complete tasks.
+func (h *Handlers) HandleSocket(res http.ResponseWriter, req *http.Request) {
+	if h.TaskService.Events == nil {
+		utils.SendErrorResponse(res, req, utils.NewError("events_unavailable"), http.StatusServiceUnavailable)
+		return
+	}
+
+	conn, err := socketUpgrader.Upgrade(res, req, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	_, events, _, cancel := h.TaskService.Events.Subscribe(0)
+	defer cancel()
+
+	s := &socketSession{h: h, conn: conn, req: req, userID: currentUserID(req), queries: map[string]*socketQuery{}}
+
+	conn.SetReadLimit(maxSocketMessage)
+	conn.SetReadDeadline(time.Now().Add(socketPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(socketPongWait))
+	})
+
+	messages := make(chan socketRequest)
+	done := make(chan struct{})
+	defer close(done)
+	go s.read(messages, done)
+
+	ping := time.NewTicker(eventHeartbeat)
+	defer ping.Stop()
+
+	var expired <-chan time.Time
+	if expires, ok := credentialExpiry(req); ok {
+		timer := time.NewTimer(time.Until(expires))
+		defer timer.Stop()
+		expired = timer.C
+	}
+
+	watch := newCredentialWatch(h, req)
+	for {
+		var err error
+		select {
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+			err = s.handle(msg)
+		case event, ok := <-events:
+			if !ok {
+				s.close(websocket.CloseTryAgainLater, "too slow, reconnect")
+				return
+			}
+			if watch.revoked() {
+				s.close(websocket.ClosePolicyViolation, "token revoked")
+				return
+			}
+			err = s.dispatch(event)
+		case <-ping.C:
+			if watch.revoked() {
+				s.close(websocket.ClosePolicyViolation, "token revoked")
+				return
+			}
+			err = conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(socketWriteWait))
+		case <-expired:
+			s.close(websocket.ClosePolicyViolation, "token expired")
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// credentialExpiry is when the token the socket was opened with expires.
+func credentialExpiry(req *http.Request) (time.Time, bool) {
+	if token, ok := middleware.AccessTokenFromContext(req.Context()); ok && token.Expires != nil {
+		return *token.Expires, true
+	}
+	if claims, ok := middleware.ClaimsFromContext(req.Context()); ok && claims.ExpiresAt != nil {
+		return claims.ExpiresAt.Time, true
+	}
+	return time.Time{}, false
+}
+
+// credentialWatch tells a connection that outlives its request when the
+// credential it was opened with is revoked. It looks the credential up at
+// most once per credentialRecheck.
+type credentialWatch struct {
+	h       *Handlers
+	req     *http.Request
+	checked time.Time
+}
+
+const credentialRecheck = time.Second
+
+func newCredentialWatch(h *Handlers, req *http.Request) *credentialWatch {
+	return &credentialWatch{h: h, req: req, checked: time.Now()}
+}
+
+func (w *credentialWatch) revoked() bool {
+	if time.Since(w.checked) < credentialRecheck {
+		return false
+	}
+	w.checked = time.Now()
+
+	if token, ok := middleware.AccessTokenFromContext(w.req.Context()); ok {
+		return !w.h.AccessTokenService.Active(token)
+	}
+	if claims, ok := middleware.ClaimsFromContext(w.req.Context()); ok {
+		return !w.h.TokenService.Active(claims)
+	}
+	return false
+}
+
+func (s *socketSession) read(messages chan<- socketRequest, done <-chan struct{}) {
+	defer close(messages)
+	for {
+		_, data, err := s.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var msg socketRequest
+		if err := json.Unmarshal(data, &msg); err != nil {
+			msg = socketRequest{err: utils.NewError("invalid_json")}
+		}
+
+		select {
+		case messages <- msg:
+		case <-done:
+			return
+		}
+	}
+}
+
+func (s *socketSession) handle(msg socketRequest) error {
+	if msg.err != nil {
+		return s.sendError(msg.Ref, msg.err, http.StatusBadRequest)
+	}
+
+	switch msg.Type {
+	case "subscribe":
+		return s.subscribe(msg)
+	case "unsubscribe":
+		delete(s.queries, msg.Ref)
+		return nil
+	case service.BatchCreate, service.BatchUpdate, service.BatchDelete, service.BatchDone:
+		return s.mutate(msg)
+	default:
+		return s.sendError(msg.Ref, utils.NewError("invalid_value", "type"), http.StatusBadRequest)
+	}
+}
+
+func (s *socketSession) subscribe(msg socketRequest) error {
+	if msg.Ref == "" {
+		return s.sendError(msg.Ref, utils.NewError("required", "ref"), http.StatusBadRequest)
+	}
+	if _, ok := s.queries[msg.Ref]; !ok && len(s.queries) >= maxSocketSubscriptions {
+		return s.sendError(msg.Ref, utils.NewError("too_many_subscriptions", maxSocketSubscriptions), http.StatusBadRequest)
+	}
+
+	ownerID := msg.Owner
+	if ownerID == 0 {
+		ownerID = s.userID
+	}
+
+	since := s.h.TaskService.Events.LastID()
+	tasks, err := s.h.TaskService.GetTasks(s.userID, ownerID, msg.Search, socketSnapshotLimit)
+	if err != nil {
+		apiErr, status := taskAccessError(err, http.StatusNotFound)
+		return s.sendError(msg.Ref, apiErr, status)
+	}
+
+	query := &socketQuery{ownerID: ownerID, search: msg.Search, since: since, ids: map[string]bool{}}
+	for _, task := range tasks {
+		query.ids[task.ID] = true
+	}
+	s.queries[msg.Ref] = query
+
+	if tasks == nil {
+		tasks = []entities.Task{}
+	}
+	return s.send(models.SocketSnapshot{Type: "snapshot", Ref: msg.Ref, Tasks: tasks})
+}
+
+// mutate runs the message as a one-operation batch, so it is checked and
+// answered the way the matching batch operation would be.
+func (s *socketSession) mutate(msg socketRequest) error {
+	if token, ok := middleware.AccessTokenFromContext(s.req.Context()); ok && !service.HasScope(token.Scopes, entities.ScopeTasksWrite) {
+		return s.sendError(msg.Ref, utils.NewError("insufficient_scope", entities.ScopeTasksWrite), http.StatusForbidden)
+	}
+
+	ownerID := msg.Owner
+	if ownerID == 0 {
+		ownerID = s.userID
+	}
+
+	op := s.h.decodeBatchOperation(batchOperation{Op: msg.Type, ID: msg.ID, Version: msg.Version, Task: msg.Task}, ownerID)
+	results, committed, err := s.h.TaskService.Batch(currentActor(s.req), []service.BatchOperation{op}, false)
+	if err != nil {
+		apiErr, status := databaseError(err)
+		return s.sendError(msg.Ref, apiErr, status)
+	}
+
+	result := s.h.batchResult(s.req, op, results[0], committed)
+	return s.send(models.SocketMessage{Type: "ack", Ref: msg.Ref, Result: &result})
+}
+
+// dispatch turns the event into a change for every subscription it affects.
+func (s *socketSession) dispatch(event entities.TaskEvent) error {
+	refs := make([]string, 0, len(s.queries))
+	for ref := range s.queries {
+		refs = append(refs, ref)
+	}
+	sort.Strings(refs)
+
+	for _, ref := range refs {
+		query := s.queries[ref]
+		if query.ownerID != event.OwnerID || event.ID <= query.since {
+			continue
+		}
+
+		msg := models.SocketMessage{Ref: ref, ID: event.TaskID}
+		inQuery := s.h.TaskService.InQuery(s.userID, query.search, event)
+		switch {
+		case inQuery && query.ids[event.TaskID]:
+			msg.Type, msg.Task = "update", event.Task
+		case inQuery:
+			msg.Type, msg.Task = "add", event.Task
+			query.ids[event.TaskID] = true
+		case query.ids[event.TaskID]:
+			msg.Type = "remove"
+			delete(query.ids, event.TaskID)
+		default:
+			continue
+		}
+
+		if err := s.send(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *socketSession) sendError(ref string, err error, status int) error {
+	errResp := utils.LocalizeError(err, status, utils.Language(s.req))
+	return s.send(models.SocketMessage{Type: "error", Ref: ref, Error: &errResp})
+}
+
+func (s *socketSession) send(msg interface{}) error {
+	s.conn.SetWriteDeadline(time.Now().Add(socketWriteWait))
+	return s.conn.WriteJSON(msg)
+}
+
+func (s *socketSession) close(code int, text string) {
+	s.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, text), time.Now().Add(socketWriteWait))
+}
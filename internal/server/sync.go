@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/antonkazachenko/go-todo-list-api/internal/service"
+	"github.com/antonkazachenko/go-todo-list-api/models"
+	"github.com/antonkazachenko/go-todo-list-api/utils"
+)
+
+const maxSyncRefLength = 255
+
+type syncRequest struct {
+	Since   string       `json:"since"`
+	Changes []syncChange `json:"changes"`
+}
+
+type syncChange struct {
+	Op      string          `json:"op"`
+	Ref     string          `json:"ref"`
+	ID      string          `json:"id"`
+	Changed string          `json:"changed"`
+	Task    json.RawMessage `json:"task"`
+}
+
+// HandleGetSync answers with the tasks created, changed and deleted since
+// the sync token in since, or the whole list without one.
+func (h *Handlers) HandleGetSync(res http.ResponseWriter, req *http.Request) {
+	ownerID, err := listOwnerID(req)
+	if err != nil {
+		utils.SendErrorResponse(res, req, err, http.StatusBadRequest)
+		return
+	}
+
+	delta, err := h.TaskService.Changes(currentUserID(req), ownerID, req.URL.Query().Get("since"))
+	if err != nil {
+		sendSyncError(res, req, err)
+		return
+	}
+
+	sendJSONResponse(res, http.StatusOK, syncResponse(delta))
+}
+
+// HandlePostSync applies changes queued by an offline client and answers
+// with the outcome of each and, like HandleGetSync, everything that changed
+// since the client's token.
+func (h *Handlers) HandlePostSync(res http.ResponseWriter, req *http.Request) {
+	var body syncRequest
+	if err := parseRequestBody(req, &body); err != nil {
+		utils.SendErrorResponse(res, req, utils.NewError("invalid_json"), http.StatusBadRequest)
+		return
+	}
+
+	if len(body.Changes) > maxBatchOperations {
+		utils.SendErrorResponse(res, req, utils.NewError("too_many_changes", maxBatchOperations), http.StatusBadRequest)
+		return
+	}
+	if body.Since != "" {
+		if _, err := service.ParseSyncToken(body.Since); err != nil {
+			utils.SendErrorResponse(res, req, err, http.StatusBadRequest)
+			return
+		}
+	}
+
+	ownerID, err := listOwnerID(req)
+	if err != nil {
+		utils.SendErrorResponse(res, req, err, http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	changes := make([]service.SyncChange, len(body.Changes))
+	for i, change := range body.Changes {
+		changes[i] = h.decodeSyncChange(change, now)
+	}
+
+	results, err := h.TaskService.Sync(currentActor(req), ownerID, changes)
+	if err != nil {
+		sendDatabaseError(res, req, err)
+		return
+	}
+
+	delta, err := h.TaskService.Changes(currentUserID(req), ownerID, body.Since)
+	if err != nil {
+		sendSyncError(res, req, err)
+		return
+	}
+
+	resp := syncResponse(delta)
+	resp.Results = make([]models.SyncResult, len(results))
+	for i, result := range results {
+		resp.Results[i] = syncResult(req, body.Changes[i].Ref, changes[i], result)
+	}
+	sendJSONResponse(res, http.StatusOK, resp)
+}
+
+// decodeSyncChange checks a change the way the matching batch operation is
+// checked. A time in the future is taken as now, so that a client with a
+// fast clock does not win every conflict.
+func (h *Handlers) decodeSyncChange(change syncChange, now time.Time) service.SyncChange {
+	decoded := service.SyncChange{Op: change.Op, Ref: change.Ref, ID: change.ID}
+
+	switch change.Op {
+	case service.SyncCreate, service.SyncUpdate, service.SyncDelete:
+	default:
+		decoded.Err = utils.NewError("invalid_value", "op")
+		return decoded
+	}
+
+	if change.Changed == "" {
+		decoded.Err = utils.NewError("required", "changed")
+		return decoded
+	}
+	changed, err := time.Parse(time.RFC3339Nano, change.Changed)
+	if err != nil {
+		decoded.Err = utils.NewError("invalid_format", "changed")
+		return decoded
+	}
+	if changed.After(now) {
+		changed = now
+	}
+	decoded.Changed = changed
+
+	if change.Op == service.SyncCreate {
+		if len(change.Ref) > maxSyncRefLength {
+			decoded.Err = utils.NewError("too_long", "ref")
+			return decoded
+		}
+		if err := json.Unmarshal(change.Task, &decoded.Task); err != nil {
+			decoded.Err = utils.NewError("invalid_json")
+			return decoded
+		}
+		decoded.Err = h.TaskService.ValidateNewTask(&decoded.Task)
+		return decoded
+	}
+
+	if _, err := parseAndValidateID(change.ID); err != nil {
+		decoded.Err = err
+		return decoded
+	}
+
+	if change.Op == service.SyncUpdate {
+		var body map[string]json.RawMessage
+		if err := json.Unmarshal(change.Task, &body); err != nil || body == nil {
+			decoded.Err = utils.NewError("invalid_json")
+			return decoded
+		}
+
+		_, patch, err := taskPatch(body, false)
+		if err == nil {
+			err = validateTaskPatch(patch)
+		}
+		decoded.Patch, decoded.Err = patch, err
+	}
+	return decoded
+}
+
+func syncResponse(delta *service.SyncDelta) models.SyncResponse {
+	return models.SyncResponse{Token: delta.Token, Reset: delta.Reset, Tasks: delta.Tasks, Deleted: delta.Deleted}
+}
+
+func syncResult(req *http.Request, ref string, change service.SyncChange, result service.SyncResult) models.SyncResult {
+	out := models.SyncResult{Ref: ref, ID: result.ID}
+	if result.Err != nil {
+		apiErr, status := taskAccessError(result.Err, http.StatusNotFound)
+		if change.Err != nil {
+			apiErr, status = change.Err, http.StatusBadRequest
+		}
+		errResp := utils.LocalizeError(apiErr, status, utils.Language(req))
+		out.Status, out.Error = status, &errResp
+		return out
+	}
+
+	out.Task = result.Task
+	for _, conflict := range result.Conflicts {
+		out.Conflicts = append(out.Conflicts, models.SyncConflict{Field: conflict.Field, Server: conflict.Server, Client: conflict.Client})
+	}
+
+	switch {
+	case !result.Applied && len(result.Conflicts) > 0:
+		out.Status = http.StatusConflict
+	case change.Op == service.SyncCreate:
+		out.Status = http.StatusCreated
+	case result.Task == nil:
+		out.Status = http.StatusNoContent
+	default:
+		out.Status = http.StatusOK
+	}
+	return out
+}
+
+func sendSyncError(res http.ResponseWriter, req *http.Request, err error) {
+	if errors.Is(err, service.ErrSyncToken) {
+		utils.SendErrorResponse(res, req, err, http.StatusBadRequest)
+		return
+	}
+	sendTaskAccessError(res, req, err, http.StatusNotFound)
+}
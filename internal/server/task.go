@@ -2,47 +2,63 @@ package handlers
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/antonkazachenko/go-todo-list-api/config"
 	"github.com/antonkazachenko/go-todo-list-api/internal/entities"
 	"github.com/antonkazachenko/go-todo-list-api/internal/service"
+	storage "github.com/antonkazachenko/go-todo-list-api/internal/storage/sqlite"
+	"github.com/antonkazachenko/go-todo-list-api/middleware"
 	"github.com/antonkazachenko/go-todo-list-api/models"
 	"github.com/antonkazachenko/go-todo-list-api/utils"
 )
 
 type Handlers struct {
-	TaskService *service.TaskService
+	TaskService        *service.TaskService
+	BackupService      *service.BackupService
+	UserService        *service.UserService
+	TokenService       *service.TokenService
+	AccessTokenService *service.AccessTokenService
+	OIDCService        *service.OIDCService
+	ShareLinkService   *service.ShareLinkService
+	AuditService       *service.AuditService
+	WebhookService     *service.WebhookService
 }
 
-func NewHandlers(taskService *service.TaskService) *Handlers {
-	return &Handlers{TaskService: taskService}
+func NewHandlers(taskService *service.TaskService, backupService *service.BackupService, userService *service.UserService, tokenService *service.TokenService, accessTokenService *service.AccessTokenService, oidcService *service.OIDCService, shareLinkService *service.ShareLinkService, auditService *service.AuditService, webhookService *service.WebhookService) *Handlers {
+	return &Handlers{TaskService: taskService, BackupService: backupService, UserService: userService, TokenService: tokenService, AccessTokenService: accessTokenService, OIDCService: oidcService, ShareLinkService: shareLinkService, AuditService: auditService, WebhookService: webhookService}
 }
 
 func (h *Handlers) HandleAddTask(res http.ResponseWriter, req *http.Request) {
 	var task entities.Task
 	if err := parseRequestBody(req, &task); err != nil {
-		utils.SendErrorResponse(res, "ошибка декодирования JSON", http.StatusBadRequest)
+		utils.SendErrorResponse(res, req, utils.NewError("invalid_json"), http.StatusBadRequest)
 		return
 	}
 
-	if task.Title == "" {
-		utils.SendErrorResponse(res, "отсутствует обязательное поле title", http.StatusBadRequest)
+	if err := h.TaskService.ValidateNewTask(&task); err != nil {
+		utils.SendErrorResponse(res, req, err, http.StatusBadRequest)
 		return
 	}
 
-	if err := h.validateAndUpdateDate(&task); err != nil {
-		utils.SendErrorResponse(res, err.Error(), http.StatusBadRequest)
+	ownerID, err := listOwnerID(req)
+	if err != nil {
+		utils.SendErrorResponse(res, req, err, http.StatusBadRequest)
 		return
 	}
 
-	id, err := h.TaskService.Repo.AddTask(task)
+	id, err := h.TaskService.AddTask(currentActor(req), ownerID, task)
 	if err != nil {
-		utils.SendErrorResponse(res, "ошибка запроса к базе данных", http.StatusInternalServerError)
+		sendTaskAccessError(res, req, err, http.StatusNotFound)
 		return
 	}
 
@@ -53,9 +69,15 @@ func (h *Handlers) HandleGetTasks(res http.ResponseWriter, req *http.Request) {
 	searchTerm := req.URL.Query().Get("search")
 	limit := 100
 
-	tasks, err := h.TaskService.Repo.GetTasks(searchTerm, limit)
+	ownerID, err := listOwnerID(req)
+	if err != nil {
+		utils.SendErrorResponse(res, req, err, http.StatusBadRequest)
+		return
+	}
+
+	tasks, err := h.TaskService.GetTasks(currentUserID(req), ownerID, searchTerm, limit)
 	if err != nil {
-		utils.SendErrorResponse(res, "ошибка запроса к базе данных", http.StatusInternalServerError)
+		sendTaskAccessError(res, req, err, http.StatusNotFound)
 		return
 	}
 
@@ -63,60 +85,87 @@ func (h *Handlers) HandleGetTasks(res http.ResponseWriter, req *http.Request) {
 		tasks = []entities.Task{}
 	}
 
-	sendJSONResponse(res, http.StatusOK, map[string][]entities.Task{"tasks": tasks})
+	sendCachedJSON(res, req, "", map[string][]entities.Task{"tasks": tasks})
 }
 
 func (h *Handlers) HandleGetTask(res http.ResponseWriter, req *http.Request) {
 	taskID, err := parseAndValidateID(req.URL.Query().Get("id"))
 	if err != nil {
-		utils.SendErrorResponse(res, err.Error(), http.StatusBadRequest)
+		utils.SendErrorResponse(res, req, err, http.StatusBadRequest)
 		return
 	}
 
-	task, err := h.TaskService.Repo.GetTaskByID(taskID)
+	task, _, err := h.TaskService.GetTask(currentUserID(req), taskID, entities.PermissionViewer)
 	if err != nil {
-		utils.SendErrorResponse(res, "задача с указанным id не найдена", http.StatusNotFound)
+		sendTaskAccessError(res, req, err, http.StatusNotFound)
 		return
 	}
 
-	sendJSONResponse(res, http.StatusOK, task)
+	sendCachedJSON(res, req, taskETag(task), task)
 }
 
+// HandlePutTask replaces every writable field of the task.
 func (h *Handlers) HandlePutTask(res http.ResponseWriter, req *http.Request) {
-	var taskUpdates map[string]interface{}
-	if err := parseRequestBody(req, &taskUpdates); err != nil {
-		utils.SendErrorResponse(res, "ошибка декодирования JSON", http.StatusBadRequest)
+	h.writeTask(res, req, true)
+}
+
+// HandlePatchTask changes only the fields present in the body, following JSON
+// Merge Patch.
+func (h *Handlers) HandlePatchTask(res http.ResponseWriter, req *http.Request) {
+	h.writeTask(res, req, false)
+}
+
+func (h *Handlers) writeTask(res http.ResponseWriter, req *http.Request, replace bool) {
+	id, versionField, patch, err := decodeTaskWrite(req, replace)
+	if err != nil {
+		utils.SendErrorResponse(res, req, err, http.StatusBadRequest)
 		return
 	}
 
-	_, err := h.validateAndExtractID(taskUpdates)
-	if err != nil {
-		utils.SendErrorResponse(res, err.Error(), http.StatusBadRequest)
+	if err := validateTaskPatch(patch); err != nil {
+		utils.SendErrorResponse(res, req, err, http.StatusBadRequest)
 		return
 	}
 
-	if err := h.validateTaskUpdates(taskUpdates); err != nil {
-		utils.SendErrorResponse(res, err.Error(), http.StatusBadRequest)
+	version, err := taskVersion(req, versionField)
+	if err != nil {
+		utils.SendErrorResponse(res, req, err, http.StatusPreconditionRequired)
 		return
 	}
 
-	if _, err := h.TaskService.Repo.UpdateTask(taskUpdates); err != nil {
-		utils.SendErrorResponse(res, "ошибка запроса к базе данных", http.StatusInternalServerError)
+	if err := h.TaskService.UpdateTask(currentActor(req), id, version, patch); err != nil {
+		h.sendTaskChangeError(res, req, id, err, http.StatusBadRequest)
 		return
 	}
 
-	sendJSONResponse(res, http.StatusOK, map[string]interface{}{})
+	task, _, err := h.TaskService.GetTask(currentUserID(req), id, entities.PermissionViewer)
+	if err != nil {
+		sendTaskAccessError(res, req, err, http.StatusNotFound)
+		return
+	}
+	res.Header().Set("ETag", taskETag(task))
+	if replace {
+		sendJSONResponse(res, http.StatusOK, map[string]interface{}{})
+		return
+	}
+	sendJSONResponse(res, http.StatusOK, task)
 }
 
 func (h *Handlers) HandleDeleteTask(res http.ResponseWriter, req *http.Request) {
 	taskID, err := parseAndValidateID(req.URL.Query().Get("id"))
 	if err != nil {
-		utils.SendErrorResponse(res, err.Error(), http.StatusBadRequest)
+		utils.SendErrorResponse(res, req, err, http.StatusBadRequest)
 		return
 	}
 
-	if err := h.deleteTaskIfExists(taskID); err != nil {
-		utils.SendErrorResponse(res, err.Error(), http.StatusInternalServerError)
+	version, err := taskVersion(req, req.URL.Query().Get("version"))
+	if err != nil {
+		utils.SendErrorResponse(res, req, err, http.StatusPreconditionRequired)
+		return
+	}
+
+	if err := h.TaskService.DeleteTask(currentActor(req), taskID, version); err != nil {
+		h.sendTaskChangeError(res, req, taskID, err, http.StatusInternalServerError)
 		return
 	}
 
@@ -126,26 +175,19 @@ func (h *Handlers) HandleDeleteTask(res http.ResponseWriter, req *http.Request)
 func (h *Handlers) HandleDoneTask(res http.ResponseWriter, req *http.Request) {
 	taskID, err := parseAndValidateID(req.URL.Query().Get("id"))
 	if err != nil {
-		utils.SendErrorResponse(res, err.Error(), http.StatusBadRequest)
+		utils.SendErrorResponse(res, req, err, http.StatusBadRequest)
 		return
 	}
 
-	task, err := h.TaskService.Repo.GetTaskByID(taskID)
+	version, err := taskVersion(req, req.URL.Query().Get("version"))
 	if err != nil {
-		utils.SendErrorResponse(res, "задача с указанным id не найдена", http.StatusNotFound)
+		utils.SendErrorResponse(res, req, err, http.StatusPreconditionRequired)
 		return
 	}
 
-	if task.Repeat == "" {
-		if _, err := h.TaskService.Repo.DeleteTask(taskID); err != nil {
-			utils.SendErrorResponse(res, "ошибка запроса к базе данных", http.StatusInternalServerError)
-			return
-		}
-	} else {
-		if err := h.markTaskAsDone(taskID, task); err != nil {
-			utils.SendErrorResponse(res, err.Error(), http.StatusInternalServerError)
-			return
-		}
+	if err := h.TaskService.CompleteTask(currentActor(req), taskID, version); err != nil {
+		h.sendTaskChangeError(res, req, taskID, err, http.StatusNotFound)
+		return
 	}
 
 	sendJSONResponse(res, http.StatusOK, map[string]interface{}{})
@@ -158,13 +200,13 @@ func (h *Handlers) HandleNextDate(res http.ResponseWriter, req *http.Request) {
 
 	now, err := time.Parse(service.Format, nowParam)
 	if err != nil {
-		http.Error(res, "Неправильный формат парамeтра now", http.StatusBadRequest)
+		utils.SendErrorResponse(res, req, utils.NewError("invalid_format/parameter", "now"), http.StatusBadRequest)
 		return
 	}
 
 	newDate, err := h.TaskService.NextDate(now, date, repeat)
 	if err != nil {
-		http.Error(res, err.Error(), http.StatusBadRequest)
+		utils.SendErrorResponse(res, req, err, http.StatusBadRequest)
 		return
 	}
 
@@ -177,10 +219,189 @@ func (h *Handlers) HandleNextDate(res http.ResponseWriter, req *http.Request) {
 	}
 }
 
+func currentUserID(req *http.Request) int64 {
+	if user, ok := middleware.UserFromContext(req.Context()); ok {
+		return user.ID
+	}
+	return 0
+}
+
+// currentActor describes the caller for the audit log. The token ID is the jti
+// of a signed-in session's access token or the id of a personal access token.
+func currentActor(req *http.Request) entities.Actor {
+	actor := entities.Actor{UserID: currentUserID(req), IP: clientIP(req)}
+	if token, ok := middleware.AccessTokenFromContext(req.Context()); ok {
+		actor.TokenID = "pat:" + strconv.FormatInt(token.ID, 10)
+	} else if claims, ok := middleware.ClaimsFromContext(req.Context()); ok {
+		actor.TokenID = "jwt:" + claims.ID
+	}
+	return actor
+}
+
+// listOwnerID returns the owner of the list selected by the owner query
+// parameter, the caller's own list by default.
+func listOwnerID(req *http.Request) (int64, error) {
+	owner := req.URL.Query().Get("owner")
+	if owner == "" {
+		return currentUserID(req), nil
+	}
+
+	ownerID, err := strconv.ParseInt(owner, 10, 64)
+	if err != nil {
+		return 0, utils.NewError("invalid_number", "owner")
+	}
+	return ownerID, nil
+}
+
+// sendTaskAccessError answers 403 when the caller's share does not allow the
+// action and notFoundStatus when the task or list is not visible to them.
+func sendTaskAccessError(res http.ResponseWriter, req *http.Request, err error, notFoundStatus int) {
+	apiErr, status := taskAccessError(err, notFoundStatus)
+	utils.SendErrorResponse(res, req, apiErr, status)
+}
+
+func taskAccessError(err error, notFoundStatus int) (error, int) {
+	switch {
+	case errors.Is(err, service.ErrPermissionDenied):
+		return err, http.StatusForbidden
+	case errors.Is(err, service.ErrTaskNotFound), errors.Is(err, service.ErrListNotFound):
+		return err, notFoundStatus
+	default:
+		return databaseError(err)
+	}
+}
+
+// sendDatabaseError answers 400 when a column constraint rejected the values
+// the client sent and 500 for any other database failure.
+func sendDatabaseError(res http.ResponseWriter, req *http.Request, err error) {
+	apiErr, status := databaseError(err)
+	utils.SendErrorResponse(res, req, apiErr, status)
+}
+
+func databaseError(err error) (error, int) {
+	kind, column, ok := storage.Constraint(err)
+	switch {
+	case !ok:
+		return utils.NewError("database_error"), http.StatusInternalServerError
+	case kind == storage.ConstraintTooLong:
+		return utils.NewError("too_long", column), http.StatusBadRequest
+	case kind == storage.ConstraintNotUnique:
+		return utils.NewError("not_unique", column), http.StatusBadRequest
+	default:
+		return utils.NewError("constraint_violation", column), http.StatusBadRequest
+	}
+}
+
+// sendTaskChangeError is sendTaskAccessError for writes: a version conflict
+// answers 412 with the task as it is now.
+func (h *Handlers) sendTaskChangeError(res http.ResponseWriter, req *http.Request, id string, err error, notFoundStatus int) {
+	if !errors.Is(err, service.ErrVersionConflict) {
+		sendTaskAccessError(res, req, err, notFoundStatus)
+		return
+	}
+
+	current, _, err := h.TaskService.GetTask(currentUserID(req), id, entities.PermissionViewer)
+	if err != nil {
+		sendTaskAccessError(res, req, err, http.StatusNotFound)
+		return
+	}
+	res.Header().Set("ETag", taskETag(current))
+	utils.SendConflictResponse(res, req, service.ErrVersionConflict, http.StatusPreconditionFailed, current)
+}
+
+// taskVersion returns the task version the client expects: the If-Match
+// header or, failing that, the version field. An empty version matches any.
+func taskVersion(req *http.Request, field string) (string, error) {
+	if match := req.Header.Get("If-Match"); match != "" {
+		tag, _, _ := strings.Cut(match, ",")
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return "", nil
+		}
+		return strings.Trim(strings.TrimPrefix(tag, "W/"), `"`), nil
+	}
+
+	return fieldVersion(field)
+}
+
+// fieldVersion is taskVersion for requests that can only carry the version
+// in the body.
+func fieldVersion(field string) (string, error) {
+	if field != "" {
+		return field, nil
+	}
+	if config.TODO_REQUIRE_IF_MATCH == "true" {
+		return "", service.ErrPreconditionRequired
+	}
+	return "", nil
+}
+
+// versionValue reads the version field of a body. Versions are integers, so
+// a string or an integer is taken as is; any other value is rejected rather
+// than formatted into a version that would never match.
+func versionValue(raw json.RawMessage) (string, error) {
+	if len(raw) == 0 {
+		return "", nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return "", utils.NewError("invalid_value", "version")
+	}
+
+	switch value := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return value, nil
+	case json.Number:
+		if _, err := value.Int64(); err == nil {
+			return value.String(), nil
+		}
+	}
+	return "", utils.NewError("invalid_value", "version")
+}
+
+func taskETag(task *entities.Task) string {
+	return `"` + task.Version + `"`
+}
+
+// sendCachedJSON answers a GET with an ETag, taken from etag or hashed from
+// the body, and with 304 when If-None-Match already names it.
+func sendCachedJSON(res http.ResponseWriter, req *http.Request, etag string, data interface{}) {
+	respBytes, err := json.Marshal(data)
+	if err != nil {
+		utils.SendErrorResponse(res, req, utils.NewError("internal_error"), http.StatusInternalServerError)
+		return
+	}
+	if etag == "" {
+		sum := sha256.Sum256(respBytes)
+		etag = `"` + hex.EncodeToString(sum[:16]) + `"`
+	}
+
+	res.Header().Set("ETag", etag)
+	res.Header().Set("Cache-Control", "private, no-cache")
+	for _, tag := range strings.Split(req.Header.Get("If-None-Match"), ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == etag || tag == "*" {
+			res.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(http.StatusOK)
+	if _, err := res.Write(respBytes); err != nil {
+		fmt.Printf("Error in writing a response for %s,\n %v", req.URL.Path, err)
+	}
+}
+
 func parseRequestBody(req *http.Request, target interface{}) error {
 	var buf bytes.Buffer
 	if _, err := buf.ReadFrom(req.Body); err != nil {
-		return fmt.Errorf("ошибка чтения тела запроса")
+		return utils.NewError("invalid_body")
 	}
 	return json.Unmarshal(buf.Bytes(), target)
 }
@@ -188,131 +409,137 @@ func parseRequestBody(req *http.Request, target interface{}) error {
 func sendJSONResponse(res http.ResponseWriter, statusCode int, data interface{}) {
 	respBytes, err := json.Marshal(data)
 	if err != nil {
-		utils.SendErrorResponse(res, "ошибка при сериализации ответа", http.StatusInternalServerError)
+		utils.SendErrorResponse(res, nil, utils.NewError("internal_error"), http.StatusInternalServerError)
 		return
 	}
 	res.Header().Set("Content-Type", "application/json")
 	res.WriteHeader(statusCode)
 	_, err = res.Write(respBytes)
 	if err != nil {
-		utils.SendErrorResponse(res, "ошибка записи ответа", http.StatusInternalServerError)
+		utils.SendErrorResponse(res, nil, utils.NewError("internal_error/write"), http.StatusInternalServerError)
 		return
 	}
 }
 
 func parseAndValidateID(idStr string) (string, error) {
 	if idStr == "" {
-		return "", fmt.Errorf("не передан идентификатор")
+		return "", utils.NewError("required/id")
 	}
 
 	if _, err := strconv.ParseInt(idStr, 10, 64); err != nil {
-		return "", fmt.Errorf("id должен быть числом")
+		return "", utils.NewError("invalid_number", "id")
 	}
 	return idStr, nil
 }
 
-func (h *Handlers) validateAndExtractID(taskUpdates map[string]interface{}) (string, error) {
-	id, ok := taskUpdates["id"].(string)
-	if !ok || id == "" {
-		return "", fmt.Errorf("отсутствует обязательное поле id")
+// decodeTaskWrite reads the body of PUT or PATCH into the task id, the raw
+// version field and a patch.
+func decodeTaskWrite(req *http.Request, replace bool) (string, string, entities.TaskPatch, error) {
+	body, err := readTaskBody(req)
+	if err != nil {
+		return "", "", entities.TaskPatch{}, err
 	}
 
-	if _, err := strconv.ParseInt(id, 10, 64); err != nil {
-		return "", fmt.Errorf("id должен быть числом")
+	var id string
+	if err := json.Unmarshal(body["id"], &id); err != nil || id == "" {
+		return "", "", entities.TaskPatch{}, utils.NewError("required", "id")
 	}
-
-	if _, err := h.TaskService.Repo.GetTaskByID(id); err != nil {
-		return "", fmt.Errorf("задача с указанным id не найдена")
+	if _, err := strconv.ParseInt(id, 10, 64); err != nil {
+		return "", "", entities.TaskPatch{}, utils.NewError("invalid_number", "id")
 	}
 
-	return id, nil
+	version, patch, err := taskPatch(body, replace)
+	return id, version, patch, err
 }
 
-func (h *Handlers) validateTaskUpdates(taskUpdates map[string]interface{}) error {
-	date, dateOk := taskUpdates["date"].(string)
-	if title, ok := taskUpdates["title"].(string); !ok || strings.TrimSpace(title) == "" {
-		return fmt.Errorf("отсутствует обязательное поле title")
+func readTaskBody(req *http.Request) (map[string]json.RawMessage, error) {
+	var body map[string]json.RawMessage
+	if err := parseRequestBody(req, &body); err != nil || body == nil {
+		return nil, utils.NewError("invalid_json")
 	}
+	return body, nil
+}
 
-	if !dateOk || strings.TrimSpace(date) == "" {
-		return fmt.Errorf("отсутствует обязательное поле date")
+// taskPatch turns a task body into the version field and a patch. Fields
+// sent as null are cleared; with replace set, absent fields are cleared too.
+// The id and the read-only fields returned by GET are ignored so that a
+// client can send back the task it read.
+func taskPatch(body map[string]json.RawMessage, replace bool) (string, entities.TaskPatch, error) {
+	var patch entities.TaskPatch
+	version, err := versionValue(body["version"])
+	if err != nil {
+		return "", patch, err
 	}
 
-	if _, err := time.Parse(service.Format, date); err != nil {
-		return fmt.Errorf("недопустимый формат date")
+	fields := map[string]**string{
+		"date":     &patch.Date,
+		"title":    &patch.Title,
+		"comment":  &patch.Comment,
+		"repeat":   &patch.Repeat,
+		"priority": &patch.Priority,
 	}
 
-	if repeat, ok := taskUpdates["repeat"].(string); ok && strings.TrimSpace(repeat) != "" {
-		repeatParts := strings.SplitN(repeat, " ", 2)
-		repeatType := repeatParts[0]
-		if !isValidRepeatType(repeatType) {
-			return fmt.Errorf("недопустимый символ")
-		}
+	keys := make([]string, 0, len(body))
+	for key := range body {
+		keys = append(keys, key)
 	}
+	sort.Strings(keys)
 
-	return nil
-}
-
-func (h *Handlers) validateAndUpdateDate(task *entities.Task) error {
-	var dateInTime time.Time
-	var err error
+	for _, key := range keys {
+		field, ok := fields[key]
+		switch {
+		case ok:
+		case key == "id", key == "version", key == "permission", key == "created":
+			continue
+		default:
+			return "", patch, utils.NewError("unknown_field", key)
+		}
 
-	if task.Date != "" {
-		dateInTime, err = time.Parse(service.Format, task.Date)
-		if err != nil {
-			return fmt.Errorf("недопустимый формат date")
+		var value *string
+		if err := json.Unmarshal(body[key], &value); err != nil {
+			return "", patch, utils.NewError("invalid_value", key)
 		}
-	} else {
-		task.Date = time.Now().Format(service.Format)
-		dateInTime = time.Now()
-	}
-
-	if time.Now().After(dateInTime) {
-		if task.Repeat == "" {
-			task.Date = time.Now().Format(service.Format)
-			dateInTime = time.Now()
-		} else {
-			task.Date, err = h.TaskService.NextDate(time.Now(), task.Date, task.Repeat)
-			if err != nil {
-				return err
-			}
+		if value == nil {
+			value = new(string)
 		}
+		*field = value
 	}
 
-	return nil
-}
-
-func (h *Handlers) markTaskAsDone(taskID string, task *entities.Task) error {
-	parsedDate, err := time.Parse(service.Format, task.Date)
-	if err != nil {
-		return fmt.Errorf("недопустимый формат date")
+	if replace {
+		for _, field := range fields {
+			if *field == nil {
+				*field = new(string)
+			}
+		}
 	}
 
-	if parsedDate.Format(service.Format) == time.Now().Format(service.Format) {
-		parsedDate = parsedDate.AddDate(0, 0, -1)
-		task.Date, err = h.TaskService.NextDate(parsedDate, task.Date, task.Repeat)
-	} else {
-		task.Date, err = h.TaskService.NextDate(time.Now(), task.Date, task.Repeat)
-	}
+	return version, patch, nil
+}
 
-	if err != nil {
-		return err
+// validateTaskPatch checks the fields present in the patch.
+func validateTaskPatch(patch entities.TaskPatch) error {
+	if patch.Title != nil && strings.TrimSpace(*patch.Title) == "" {
+		return utils.NewError("required", "title")
 	}
 
-	if err := h.TaskService.Repo.MarkTaskAsDone(taskID, task.Date); err != nil {
-		return fmt.Errorf("ошибка при обновлении задачи")
+	if patch.Date != nil {
+		if strings.TrimSpace(*patch.Date) == "" {
+			return utils.NewError("required", "date")
+		}
+		if _, err := time.Parse(service.Format, *patch.Date); err != nil {
+			return utils.NewError("invalid_format", "date")
+		}
 	}
 
-	return nil
-}
-
-func (h *Handlers) deleteTaskIfExists(taskID string) error {
-	if _, err := h.TaskService.Repo.GetTaskByID(taskID); err != nil {
-		return fmt.Errorf("задача с указанным id не найдена")
+	if patch.Priority != nil && !service.IsValidPriority(*patch.Priority) {
+		return utils.NewError("invalid_value", "priority")
 	}
 
-	if _, err := h.TaskService.Repo.DeleteTask(taskID); err != nil {
-		return fmt.Errorf("ошибка запроса к базе данных")
+	if patch.Repeat != nil && strings.TrimSpace(*patch.Repeat) != "" {
+		repeatType := strings.SplitN(*patch.Repeat, " ", 2)[0]
+		if !isValidRepeatType(repeatType) {
+			return utils.NewError("invalid_repeat")
+		}
 	}
 
 	return nil
@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/antonkazachenko/go-todo-list-api/internal/entities"
+	"github.com/antonkazachenko/go-todo-list-api/internal/service"
+	"github.com/antonkazachenko/go-todo-list-api/models"
+	"github.com/antonkazachenko/go-todo-list-api/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+// TaskV2Path is where the v2 API serves a single task.
+const TaskV2Path = "/api/v2/tasks/"
+
+func (h *Handlers) HandleGetTasksV2(res http.ResponseWriter, req *http.Request) {
+	ownerID, err := listOwnerID(req)
+	if err != nil {
+		utils.SendErrorResponse(res, req, err, http.StatusBadRequest)
+		return
+	}
+
+	tasks, err := h.TaskService.GetTasks(currentUserID(req), ownerID, req.URL.Query().Get("search"), 100)
+	if err != nil {
+		sendTaskAccessError(res, req, err, http.StatusNotFound)
+		return
+	}
+
+	resp := models.TasksV2Response{Tasks: make([]models.TaskV2, 0, len(tasks))}
+	for _, task := range tasks {
+		resp.Tasks = append(resp.Tasks, taskV2(&task))
+	}
+	sendCachedJSON(res, req, "", resp)
+}
+
+func (h *Handlers) HandleAddTaskV2(res http.ResponseWriter, req *http.Request) {
+	body, err := readTaskBody(req)
+	if err != nil {
+		utils.SendErrorResponse(res, req, err, http.StatusBadRequest)
+		return
+	}
+	_, patch, err := taskPatch(body, true)
+	if err != nil {
+		utils.SendErrorResponse(res, req, err, http.StatusBadRequest)
+		return
+	}
+
+	task := entities.Task{
+		Date:     *patch.Date,
+		Title:    *patch.Title,
+		Comment:  *patch.Comment,
+		Repeat:   *patch.Repeat,
+		Priority: *patch.Priority,
+	}
+	if err := h.TaskService.ValidateNewTask(&task); err != nil {
+		utils.SendErrorResponse(res, req, err, http.StatusBadRequest)
+		return
+	}
+
+	ownerID, err := listOwnerID(req)
+	if err != nil {
+		utils.SendErrorResponse(res, req, err, http.StatusBadRequest)
+		return
+	}
+
+	id, err := h.TaskService.AddTask(currentActor(req), ownerID, task)
+	if err != nil {
+		sendTaskAccessError(res, req, err, http.StatusNotFound)
+		return
+	}
+
+	res.Header().Set("Location", TaskV2Path+strconv.FormatInt(id, 10))
+	h.sendTaskV2(res, req, strconv.FormatInt(id, 10), http.StatusCreated)
+}
+
+func (h *Handlers) HandleGetTaskV2(res http.ResponseWriter, req *http.Request) {
+	task, _, err := h.TaskService.GetTask(currentUserID(req), chi.URLParam(req, "id"), entities.PermissionViewer)
+	if err != nil {
+		sendTaskAccessError(res, req, err, http.StatusNotFound)
+		return
+	}
+
+	sendCachedJSON(res, req, taskETag(task), taskV2(task))
+}
+
+func (h *Handlers) HandlePutTaskV2(res http.ResponseWriter, req *http.Request) {
+	h.writeTaskV2(res, req, true)
+}
+
+func (h *Handlers) HandlePatchTaskV2(res http.ResponseWriter, req *http.Request) {
+	h.writeTaskV2(res, req, false)
+}
+
+func (h *Handlers) writeTaskV2(res http.ResponseWriter, req *http.Request, replace bool) {
+	id := chi.URLParam(req, "id")
+	body, err := readTaskBody(req)
+	if err != nil {
+		utils.SendErrorResponse(res, req, err, http.StatusBadRequest)
+		return
+	}
+	versionField, patch, err := taskPatch(body, replace)
+	if err != nil {
+		utils.SendErrorResponse(res, req, err, http.StatusBadRequest)
+		return
+	}
+
+	if err := validateTaskPatch(patch); err != nil {
+		utils.SendErrorResponse(res, req, err, http.StatusBadRequest)
+		return
+	}
+
+	version, err := taskVersion(req, versionField)
+	if err != nil {
+		utils.SendErrorResponse(res, req, err, http.StatusPreconditionRequired)
+		return
+	}
+
+	if err := h.TaskService.UpdateTask(currentActor(req), id, version, patch); err != nil {
+		h.sendTaskV2Error(res, req, id, err)
+		return
+	}
+
+	h.sendTaskV2(res, req, id, http.StatusOK)
+}
+
+func (h *Handlers) HandleDeleteTaskV2(res http.ResponseWriter, req *http.Request) {
+	id := chi.URLParam(req, "id")
+	version, err := taskVersion(req, req.URL.Query().Get("version"))
+	if err != nil {
+		utils.SendErrorResponse(res, req, err, http.StatusPreconditionRequired)
+		return
+	}
+
+	if err := h.TaskService.DeleteTask(currentActor(req), id, version); err != nil {
+		h.sendTaskV2Error(res, req, id, err)
+		return
+	}
+
+	res.WriteHeader(http.StatusNoContent)
+}
+
+// HandleDoneTaskV2 answers 204 when a one-off task is completed and removed,
+// and the task at its next date when it repeats.
+func (h *Handlers) HandleDoneTaskV2(res http.ResponseWriter, req *http.Request) {
+	id := chi.URLParam(req, "id")
+	version, err := taskVersion(req, req.URL.Query().Get("version"))
+	if err != nil {
+		utils.SendErrorResponse(res, req, err, http.StatusPreconditionRequired)
+		return
+	}
+
+	if err := h.TaskService.CompleteTask(currentActor(req), id, version); err != nil {
+		h.sendTaskV2Error(res, req, id, err)
+		return
+	}
+
+	task, _, err := h.TaskService.GetTask(currentUserID(req), id, entities.PermissionViewer)
+	if errors.Is(err, service.ErrTaskNotFound) {
+		res.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if err != nil {
+		sendTaskAccessError(res, req, err, http.StatusNotFound)
+		return
+	}
+
+	res.Header().Set("ETag", taskETag(task))
+	sendJSONResponse(res, http.StatusOK, taskV2(task))
+}
+
+func (h *Handlers) HandleNotFoundV2(res http.ResponseWriter, req *http.Request) {
+	utils.SendErrorResponse(res, req, utils.NewError("not_found"), http.StatusNotFound)
+}
+
+func (h *Handlers) HandleMethodNotAllowedV2(res http.ResponseWriter, req *http.Request) {
+	utils.SendErrorResponse(res, req, utils.NewError("method_not_allowed"), http.StatusMethodNotAllowed)
+}
+
+func (h *Handlers) sendTaskV2(res http.ResponseWriter, req *http.Request, id string, status int) {
+	task, _, err := h.TaskService.GetTask(currentUserID(req), id, entities.PermissionViewer)
+	if err != nil {
+		sendTaskAccessError(res, req, err, http.StatusNotFound)
+		return
+	}
+
+	res.Header().Set("ETag", taskETag(task))
+	sendJSONResponse(res, status, taskV2(task))
+}
+
+// sendTaskV2Error is sendTaskChangeError for the v2 API, where unknown tasks
+// are 404 and the conflicting task is returned in the v2 form.
+func (h *Handlers) sendTaskV2Error(res http.ResponseWriter, req *http.Request, id string, err error) {
+	if !errors.Is(err, service.ErrVersionConflict) {
+		sendTaskAccessError(res, req, err, http.StatusNotFound)
+		return
+	}
+
+	current, _, err := h.TaskService.GetTask(currentUserID(req), id, entities.PermissionViewer)
+	if err != nil {
+		sendTaskAccessError(res, req, err, http.StatusNotFound)
+		return
+	}
+	res.Header().Set("ETag", taskETag(current))
+	utils.SendConflictResponse(res, req, service.ErrVersionConflict, http.StatusPreconditionFailed, taskV2(current))
+}
+
+func taskV2(task *entities.Task) models.TaskV2 {
+	id, _ := strconv.ParseInt(task.ID, 10, 64)
+	version, _ := strconv.ParseInt(task.Version, 10, 64)
+	return models.TaskV2{
+		ID:         id,
+		Date:       task.Date,
+		Title:      task.Title,
+		Comment:    task.Comment,
+		Repeat:     task.Repeat,
+		Priority:   task.Priority,
+		Created:    task.Created,
+		Version:    version,
+		Permission: task.Permission,
+	}
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+
+	"github.com/antonkazachenko/go-todo-list-api/utils"
+)
+
+func (h *Handlers) HandleExportTodoTxt(res http.ResponseWriter, req *http.Request) {
+	var buf bytes.Buffer
+	if err := h.TaskService.WriteTodoTxt(currentUserID(req), &buf); err != nil {
+		utils.SendErrorResponse(res, req, utils.NewError("database_error"), http.StatusInternalServerError)
+		return
+	}
+
+	res.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	res.Header().Set("Content-Disposition", `attachment; filename="todo.txt"`)
+	res.WriteHeader(http.StatusOK)
+	if _, err := res.Write(buf.Bytes()); err != nil {
+		fmt.Println("ошибка записи ответа в HandleExportTodoTxt", err)
+	}
+}
+
+func (h *Handlers) HandleImportTodoTxt(res http.ResponseWriter, req *http.Request) {
+	tasks, report, err := h.TaskService.ParseTodoTxt(req.Body)
+	if err != nil {
+		utils.SendErrorResponse(res, req, err, http.StatusBadRequest)
+		return
+	}
+
+	h.sendImportResponse(res, req, tasks, report)
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/antonkazachenko/go-todo-list-api/internal/service"
+	"github.com/antonkazachenko/go-todo-list-api/middleware"
+	"github.com/antonkazachenko/go-todo-list-api/utils"
+)
+
+type codeRequest struct {
+	Code string `json:"code"`
+}
+
+func (h *Handlers) HandleEnrollTOTP(res http.ResponseWriter, req *http.Request) {
+	if rejectAccessToken(res, req) {
+		return
+	}
+
+	user, _ := middleware.UserFromContext(req.Context())
+	secret, uri, err := h.UserService.EnrollTOTP(user)
+	if err != nil {
+		sendTOTPError(res, req, err)
+		return
+	}
+
+	sendJSONResponse(res, http.StatusOK, map[string]string{"secret": secret, "otpauth_uri": uri})
+}
+
+func (h *Handlers) HandleConfirmTOTP(res http.ResponseWriter, req *http.Request) {
+	if rejectAccessToken(res, req) {
+		return
+	}
+
+	var body codeRequest
+	if err := parseRequestBody(req, &body); err != nil {
+		utils.SendErrorResponse(res, req, utils.NewError("invalid_json"), http.StatusBadRequest)
+		return
+	}
+
+	user, _ := middleware.UserFromContext(req.Context())
+	codes, err := h.UserService.ConfirmTOTP(user, body.Code)
+	if err != nil {
+		sendTOTPError(res, req, err)
+		return
+	}
+
+	sendJSONResponse(res, http.StatusOK, map[string][]string{"recovery_codes": codes})
+}
+
+func (h *Handlers) HandleDisableTOTP(res http.ResponseWriter, req *http.Request) {
+	if rejectAccessToken(res, req) {
+		return
+	}
+
+	var body codeRequest
+	if err := parseRequestBody(req, &body); err != nil {
+		utils.SendErrorResponse(res, req, utils.NewError("invalid_json"), http.StatusBadRequest)
+		return
+	}
+
+	user, _ := middleware.UserFromContext(req.Context())
+	ip := clientIP(req)
+	err := h.UserService.DisableTOTP(user, body.Code, ip)
+	if sendRetryAfter(res, req, err, user.Login, ip) {
+		return
+	}
+	if err != nil {
+		sendTOTPError(res, req, err)
+		return
+	}
+
+	sendJSONResponse(res, http.StatusOK, map[string]interface{}{})
+}
+
+func (h *Handlers) HandleRegenerateRecoveryCodes(res http.ResponseWriter, req *http.Request) {
+	if rejectAccessToken(res, req) {
+		return
+	}
+
+	var body codeRequest
+	if err := parseRequestBody(req, &body); err != nil {
+		utils.SendErrorResponse(res, req, utils.NewError("invalid_json"), http.StatusBadRequest)
+		return
+	}
+
+	user, _ := middleware.UserFromContext(req.Context())
+	ip := clientIP(req)
+	codes, err := h.UserService.RegenerateRecoveryCodes(user, body.Code, ip)
+	if sendRetryAfter(res, req, err, user.Login, ip) {
+		return
+	}
+	if err != nil {
+		sendTOTPError(res, req, err)
+		return
+	}
+
+	sendJSONResponse(res, http.StatusOK, map[string][]string{"recovery_codes": codes})
+}
+
+func sendTOTPError(res http.ResponseWriter, req *http.Request, err error) {
+	switch {
+	case errors.Is(err, service.ErrInvalidCode):
+		utils.SendErrorResponse(res, req, err, http.StatusBadRequest)
+	case errors.Is(err, service.ErrTOTPNotEnrolled), errors.Is(err, service.ErrTOTPAlreadyEnabled):
+		utils.SendErrorResponse(res, req, err, http.StatusConflict)
+	default:
+		utils.SendErrorResponse(res, req, utils.NewError("database_error"), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/antonkazachenko/go-todo-list-api/internal/entities"
+	"github.com/antonkazachenko/go-todo-list-api/internal/service"
+	storage "github.com/antonkazachenko/go-todo-list-api/internal/storage/sqlite"
+	"github.com/antonkazachenko/go-todo-list-api/middleware"
+	"github.com/antonkazachenko/go-todo-list-api/utils"
+)
+
+type userRequest struct {
+	Login    string `json:"login"`
+	Password string `json:"password"`
+	Role     string `json:"role"`
+}
+
+func (h *Handlers) HandleGetMe(res http.ResponseWriter, req *http.Request) {
+	user, _ := middleware.UserFromContext(req.Context())
+	sendJSONResponse(res, http.StatusOK, user)
+}
+
+func (h *Handlers) HandleGetUsers(res http.ResponseWriter, req *http.Request) {
+	users, err := h.UserService.Repo.GetUsers()
+	if err != nil {
+		utils.SendErrorResponse(res, req, utils.NewError("database_error"), http.StatusInternalServerError)
+		return
+	}
+
+	if len(users) == 0 {
+		users = []entities.User{}
+	}
+
+	sendJSONResponse(res, http.StatusOK, map[string][]entities.User{"users": users})
+}
+
+func (h *Handlers) HandleAddUser(res http.ResponseWriter, req *http.Request) {
+	var body userRequest
+	if err := parseRequestBody(req, &body); err != nil {
+		utils.SendErrorResponse(res, req, utils.NewError("invalid_json"), http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.UserService.Register(body.Login, body.Password, body.Role)
+	if errors.Is(err, service.ErrLoginTaken) {
+		utils.SendErrorResponse(res, req, err, http.StatusConflict)
+		return
+	}
+	if err != nil {
+		utils.SendErrorResponse(res, req, err, http.StatusBadRequest)
+		return
+	}
+
+	sendJSONResponse(res, http.StatusOK, user)
+}
+
+func (h *Handlers) HandlePutUser(res http.ResponseWriter, req *http.Request) {
+	id, err := parseInt64ID(req)
+	if err != nil {
+		utils.SendErrorResponse(res, req, err, http.StatusBadRequest)
+		return
+	}
+
+	var body userRequest
+	if err := parseRequestBody(req, &body); err != nil {
+		utils.SendErrorResponse(res, req, utils.NewError("invalid_json"), http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.UserService.UpdateUser(id, body.Role, body.Password)
+	if err != nil {
+		sendUserError(res, req, err)
+		return
+	}
+
+	if body.Password != "" {
+		if err := h.TokenService.Repo.RevokeUserSessions(id); err != nil {
+			utils.SendErrorResponse(res, req, utils.NewError("database_error"), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	sendJSONResponse(res, http.StatusOK, user)
+}
+
+func (h *Handlers) HandleDeleteUser(res http.ResponseWriter, req *http.Request) {
+	id, err := parseInt64ID(req)
+	if err != nil {
+		utils.SendErrorResponse(res, req, err, http.StatusBadRequest)
+		return
+	}
+
+	if err := h.UserService.DeleteUser(id); err != nil {
+		sendUserError(res, req, err)
+		return
+	}
+
+	sendJSONResponse(res, http.StatusOK, map[string]interface{}{})
+}
+
+func parseInt64ID(req *http.Request) (int64, error) {
+	idStr, err := parseAndValidateID(req.URL.Query().Get("id"))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(idStr, 10, 64)
+}
+
+func sendUserError(res http.ResponseWriter, req *http.Request, err error) {
+	switch {
+	case errors.Is(err, storage.ErrUserNotFound):
+		utils.SendErrorResponse(res, req, utils.NewError("user_not_found"), http.StatusNotFound)
+	case errors.Is(err, service.ErrLastAdmin):
+		utils.SendErrorResponse(res, req, err, http.StatusConflict)
+	default:
+		utils.SendErrorResponse(res, req, err, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/antonkazachenko/go-todo-list-api/internal/entities"
+	"github.com/antonkazachenko/go-todo-list-api/internal/service"
+	"github.com/antonkazachenko/go-todo-list-api/utils"
+)
+
+type webhookRequest struct {
+	URL          *string  `json:"url"`
+	Events       []string `json:"events"`
+	Active       *bool    `json:"active"`
+	RotateSecret bool     `json:"rotate_secret"`
+}
+
+// webhookWithSecret is returned when the secret is created or rotated; it
+// is not shown afterwards.
+type webhookWithSecret struct {
+	Secret string `json:"secret"`
+	*entities.Webhook
+}
+
+func (h *Handlers) HandleGetWebhooks(res http.ResponseWriter, req *http.Request) {
+	if req.URL.Query().Has("id") {
+		id, err := parseInt64ID(req)
+		if err != nil {
+			utils.SendErrorResponse(res, req, err, http.StatusBadRequest)
+			return
+		}
+		hook, err := h.WebhookService.Get(currentUserID(req), id)
+		if err != nil {
+			sendWebhookError(res, req, err)
+			return
+		}
+		sendJSONResponse(res, http.StatusOK, hook)
+		return
+	}
+
+	hooks, err := h.WebhookService.Repo.GetWebhooksByUser(currentUserID(req))
+	if err != nil {
+		utils.SendErrorResponse(res, req, utils.NewError("database_error"), http.StatusInternalServerError)
+		return
+	}
+
+	if len(hooks) == 0 {
+		hooks = []entities.Webhook{}
+	}
+
+	sendJSONResponse(res, http.StatusOK, map[string][]entities.Webhook{"webhooks": hooks})
+}
+
+func (h *Handlers) HandleAddWebhook(res http.ResponseWriter, req *http.Request) {
+	var body webhookRequest
+	if err := parseRequestBody(req, &body); err != nil {
+		utils.SendErrorResponse(res, req, utils.NewError("invalid_json"), http.StatusBadRequest)
+		return
+	}
+	if body.URL == nil {
+		utils.SendErrorResponse(res, req, utils.NewError("required", "url"), http.StatusBadRequest)
+		return
+	}
+
+	hook, err := h.WebhookService.Create(currentUserID(req), *body.URL, body.Events)
+	if err != nil {
+		sendWebhookError(res, req, err)
+		return
+	}
+
+	sendJSONResponse(res, http.StatusCreated, webhookWithSecret{hook.Secret, hook})
+}
+
+func (h *Handlers) HandlePutWebhook(res http.ResponseWriter, req *http.Request) {
+	id, err := parseInt64ID(req)
+	if err != nil {
+		utils.SendErrorResponse(res, req, err, http.StatusBadRequest)
+		return
+	}
+
+	var body webhookRequest
+	if err := parseRequestBody(req, &body); err != nil {
+		utils.SendErrorResponse(res, req, utils.NewError("invalid_json"), http.StatusBadRequest)
+		return
+	}
+
+	hook, err := h.WebhookService.Update(currentUserID(req), id, body.URL, body.Events, body.Active, body.RotateSecret)
+	if err != nil {
+		sendWebhookError(res, req, err)
+		return
+	}
+
+	if body.RotateSecret {
+		sendJSONResponse(res, http.StatusOK, webhookWithSecret{hook.Secret, hook})
+		return
+	}
+	sendJSONResponse(res, http.StatusOK, hook)
+}
+
+func (h *Handlers) HandleDeleteWebhook(res http.ResponseWriter, req *http.Request) {
+	id, err := parseInt64ID(req)
+	if err != nil {
+		utils.SendErrorResponse(res, req, err, http.StatusBadRequest)
+		return
+	}
+
+	if err := h.WebhookService.Delete(currentUserID(req), id); err != nil {
+		sendWebhookError(res, req, err)
+		return
+	}
+
+	sendJSONResponse(res, http.StatusOK, map[string]interface{}{})
+}
+
+func (h *Handlers) HandleGetWebhookDeliveries(res http.ResponseWriter, req *http.Request) {
+	id, err := parseInt64ID(req)
+	if err != nil {
+		utils.SendErrorResponse(res, req, err, http.StatusBadRequest)
+		return
+	}
+
+	deliveries, err := h.WebhookService.Deliveries(currentUserID(req), id)
+	if err != nil {
+		sendWebhookError(res, req, err)
+		return
+	}
+
+	if len(deliveries) == 0 {
+		deliveries = []entities.WebhookDelivery{}
+	}
+
+	sendJSONResponse(res, http.StatusOK, map[string][]entities.WebhookDelivery{"deliveries": deliveries})
+}
+
+func (h *Handlers) HandleRedeliverWebhook(res http.ResponseWriter, req *http.Request) {
+	id, err := parseInt64ID(req)
+	if err != nil {
+		utils.SendErrorResponse(res, req, err, http.StatusBadRequest)
+		return
+	}
+
+	delivery, err := h.WebhookService.Redeliver(currentUserID(req), id)
+	if err != nil {
+		sendWebhookError(res, req, err)
+		return
+	}
+
+	sendJSONResponse(res, http.StatusAccepted, delivery)
+}
+
+func sendWebhookError(res http.ResponseWriter, req *http.Request, err error) {
+	switch {
+	case errors.Is(err, service.ErrWebhookNotFound), errors.Is(err, service.ErrDeliveryNotFound):
+		utils.SendErrorResponse(res, req, err, http.StatusNotFound)
+	case errors.Is(err, service.ErrWebhookURL), errors.Is(err, service.ErrWebhookEvents), errors.Is(err, service.ErrTooManyWebhooks):
+		utils.SendErrorResponse(res, req, err, http.StatusBadRequest)
+	default:
+		sendDatabaseError(res, req, err)
+	}
+}
@@ -0,0 +1,144 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/antonkazachenko/go-todo-list-api/internal/entities"
+	storage "github.com/antonkazachenko/go-todo-list-api/internal/storage/sqlite"
+	"github.com/antonkazachenko/go-todo-list-api/utils"
+)
+
+const AccessTokenPrefix = "todo_pat_"
+
+var (
+	ErrInvalidScope = utils.NewError("invalid_value", "scopes")
+	ErrScopeDenied  = utils.NewError("scope_denied")
+)
+
+var knownScopes = []string{entities.ScopeTasksRead, entities.ScopeTasksWrite, entities.ScopeAdmin}
+
+type AccessTokenService struct {
+	Repo *storage.SQLiteAccessTokenRepository
+}
+
+func NewAccessTokenService(repo *storage.SQLiteAccessTokenRepository) *AccessTokenService {
+	return &AccessTokenService{Repo: repo}
+}
+
+func IsAccessToken(token string) bool {
+	return strings.HasPrefix(token, AccessTokenPrefix)
+}
+
+// HasScope reports whether granted covers required; tasks:write implies
+// tasks:read.
+func HasScope(granted []string, required string) bool {
+	for _, scope := range granted {
+		if scope == required || (scope == entities.ScopeTasksWrite && required == entities.ScopeTasksRead) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *AccessTokenService) Create(user *entities.User, name string, scopes []string, ttl time.Duration) (string, *entities.AccessToken, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 64 {
+		return "", nil, utils.NewError("invalid_token_name")
+	}
+	if ttl < 0 {
+		return "", nil, utils.NewError("invalid_expiry/token")
+	}
+
+	scopes, err := normalizeScopes(scopes)
+	if err != nil {
+		return "", nil, err
+	}
+	if HasScope(scopes, entities.ScopeAdmin) && user.Role != entities.RoleAdmin {
+		return "", nil, ErrScopeDenied
+	}
+
+	secret, err := randomToken(32)
+	if err != nil {
+		return "", nil, err
+	}
+	plain := AccessTokenPrefix + secret
+
+	now := time.Now().UTC().Truncate(time.Second)
+	token := &entities.AccessToken{
+		UserID:    user.ID,
+		Name:      name,
+		TokenHash: hashToken(plain),
+		Scopes:    scopes,
+		Created:   now,
+	}
+	if ttl > 0 {
+		expires := now.Add(ttl)
+		token.Expires = &expires
+	}
+
+	token.ID, err = s.Repo.AddAccessToken(*token)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return plain, token, nil
+}
+
+func (s *AccessTokenService) Authenticate(plain string) (*entities.AccessToken, error) {
+	token, err := s.Repo.GetAccessTokenByHash(hashToken(plain))
+	if errors.Is(err, storage.ErrAccessTokenNotFound) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if token.Expires != nil && !now.Before(*token.Expires) {
+		return nil, ErrInvalidToken
+	}
+
+	if err := s.Repo.TouchAccessToken(token.ID, now); err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// Active reports whether the token has not been revoked since it was
+// authenticated.
+func (s *AccessTokenService) Active(token *entities.AccessToken) bool {
+	_, err := s.Repo.GetAccessTokenByHash(token.TokenHash)
+	return err == nil
+}
+
+func (s *AccessTokenService) Prune(unusedFor time.Duration) (int64, error) {
+	now := time.Now()
+	return s.Repo.DeleteStaleAccessTokens(now, now.Add(-unusedFor))
+}
+
+func normalizeScopes(scopes []string) ([]string, error) {
+	if len(scopes) == 0 {
+		return nil, ErrInvalidScope
+	}
+
+	var normalized []string
+	for _, known := range knownScopes {
+		for _, scope := range scopes {
+			if scope == known {
+				normalized = append(normalized, known)
+				break
+			}
+		}
+	}
+
+	for _, scope := range scopes {
+		if !HasScope(normalized, scope) {
+			return nil, ErrInvalidScope
+		}
+	}
+
+	return normalized, nil
+}
@@ -0,0 +1,44 @@
+package service
+
+import (
+	"github.com/antonkazachenko/go-todo-list-api/internal/entities"
+	storage "github.com/antonkazachenko/go-todo-list-api/internal/storage/sqlite"
+	"github.com/antonkazachenko/go-todo-list-api/utils"
+)
+
+const (
+	DefaultAuditLimit = 100
+	MaxAuditLimit     = 10000
+)
+
+var ErrInvalidAuditAction = utils.NewError("invalid_value", "action")
+
+type AuditService struct {
+	Repo *storage.SQLiteAuditRepository
+}
+
+func NewAuditService(repo *storage.SQLiteAuditRepository) *AuditService {
+	return &AuditService{Repo: repo}
+}
+
+// Entries returns audit entries newest first. Unless all is set the caller
+// only sees changes to their own list and changes they made themselves.
+func (s *AuditService) Entries(callerID int64, all bool, filter entities.AuditFilter) ([]entities.AuditEntry, error) {
+	switch filter.Action {
+	case "", entities.AuditCreate, entities.AuditUpdate, entities.AuditDelete, entities.AuditDone:
+	default:
+		return nil, ErrInvalidAuditAction
+	}
+
+	if !all {
+		filter.VisibleTo = callerID
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = DefaultAuditLimit
+	}
+	if filter.Limit > MaxAuditLimit {
+		filter.Limit = MaxAuditLimit
+	}
+
+	return s.Repo.GetAuditEntries(filter)
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/antonkazachenko/go-todo-list-api/internal/entities"
+	storage "github.com/antonkazachenko/go-todo-list-api/internal/storage/sqlite"
+	"github.com/antonkazachenko/go-todo-list-api/utils"
+)
+
+// BackupVersion 2 dumps every table in one transaction and restores tables
+// it lacks as empty. Version 1 backups are still restored; see
+// SQLiteBackupRepository.Restore.
+const (
+	BackupFormat     = "go-todo-list-api/backup"
+	BackupVersion    = 2
+	minBackupVersion = 1
+)
+
+type BackupService struct {
+	Repo *storage.SQLiteBackupRepository
+}
+
+func NewBackupService(repo *storage.SQLiteBackupRepository) *BackupService {
+	return &BackupService{Repo: repo}
+}
+
+func (s *BackupService) Backup() (*entities.Backup, error) {
+	tables, err := s.Repo.Dump()
+	if err != nil {
+		return nil, err
+	}
+
+	return &entities.Backup{
+		Format:    BackupFormat,
+		Version:   BackupVersion,
+		CreatedAt: time.Now().UTC(),
+		Tables:    tables,
+	}, nil
+}
+
+func (s *BackupService) WriteBackup(w io.Writer) error {
+	backup, err := s.Backup()
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(backup)
+}
+
+func (s *BackupService) ReadBackup(r io.Reader) (*entities.Backup, error) {
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(r); err != nil {
+		return nil, utils.NewError("invalid_backup/read")
+	}
+
+	decoder := json.NewDecoder(&buf)
+	decoder.UseNumber()
+
+	var backup entities.Backup
+	if err := decoder.Decode(&backup); err != nil {
+		return nil, utils.NewError("invalid_json")
+	}
+
+	return &backup, nil
+}
+
+func (s *BackupService) Restore(backup *entities.Backup) error {
+	if err := s.ValidateBackup(backup); err != nil {
+		return err
+	}
+
+	return s.RestoreValidated(backup)
+}
+
+// RestoreValidated restores a backup that ValidateBackup accepted.
+func (s *BackupService) RestoreValidated(backup *entities.Backup) error {
+	return s.Repo.Restore(backup.Tables, backup.Version < BackupVersion)
+}
+
+func (s *BackupService) ValidateBackup(backup *entities.Backup) error {
+	if backup.Format != BackupFormat {
+		return utils.NewError("invalid_backup/format")
+	}
+
+	if backup.Version < minBackupVersion || backup.Version > BackupVersion {
+		return utils.NewError("invalid_backup/version", backup.Version)
+	}
+
+	if len(backup.Tables) == 0 {
+		return utils.NewError("invalid_backup/no_tables")
+	}
+
+	schema, err := s.Repo.TableColumns()
+	if err != nil {
+		return err
+	}
+
+	for table, data := range backup.Tables {
+		columns, ok := schema[table]
+		if !ok {
+			return utils.NewError("invalid_backup/table", table)
+		}
+
+		if len(data.Columns) == 0 {
+			return utils.NewError("invalid_backup/no_columns", table)
+		}
+
+		known := make(map[string]bool, len(columns))
+		for _, column := range columns {
+			known[column] = true
+		}
+
+		seen := make(map[string]bool, len(data.Columns))
+		for _, column := range data.Columns {
+			if !known[column] || seen[column] {
+				return utils.NewError("invalid_backup/column", column, table)
+			}
+			seen[column] = true
+		}
+
+		for i, row := range data.Rows {
+			if len(row) != len(data.Columns) {
+				return utils.NewError("invalid_backup/row", table, i+1)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/antonkazachenko/go-todo-list-api/internal/entities"
+	storage "github.com/antonkazachenko/go-todo-list-api/internal/storage/sqlite"
+	"github.com/antonkazachenko/go-todo-list-api/utils"
+)
+
+const (
+	BatchCreate = "create"
+	BatchUpdate = "update"
+	BatchDelete = "delete"
+	BatchDone   = "done"
+)
+
+var ErrBatchAborted = utils.NewError("batch_aborted")
+
+var errBatchRollback = errors.New("batch rolled back")
+
+// BatchOperation is one step of a batch. Err is set by the caller for an
+// operation it could not decode; such an operation fails without running.
+type BatchOperation struct {
+	Op      string
+	ID      string
+	OwnerID int64
+	Version string
+	Task    entities.Task
+	Patch   entities.TaskPatch
+	Err     error
+}
+
+// BatchResult is the outcome of one operation: the task as it is after the
+// operation, nil once it is deleted, or the error that stopped it.
+type BatchResult struct {
+	ID   string
+	Task *entities.Task
+	Err  error
+}
+
+// Batch runs the operations in order in one transaction. By default the first
+// failure rolls everything back and the operations after it fail with
+// ErrBatchAborted; with continueOnError only the failed operation is undone.
+// committed reports whether the changes were kept.
+func (s *TaskService) Batch(actor entities.Actor, ops []BatchOperation, continueOnError bool) ([]BatchResult, bool, error) {
+	results := make([]BatchResult, len(ops))
+
+	var flush func()
+	err := s.Repo.InTx(func(repo *storage.SQLiteTaskRepository) error {
+		var tx *TaskService
+		tx, flush = s.inTx(repo)
+		for i, op := range ops {
+			results[i].ID = op.ID
+			err := tx.savepoint(func() error {
+				return tx.runBatchOperation(actor, op, &results[i])
+			})
+			if err == nil {
+				continue
+			}
+
+			results[i].Task = nil
+			results[i].Err = err
+			if !continueOnError {
+				for j := i + 1; j < len(ops); j++ {
+					results[j] = BatchResult{ID: ops[j].ID, Err: ErrBatchAborted}
+				}
+				return errBatchRollback
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, errBatchRollback) {
+		return results, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	flush()
+	return results, true, nil
+}
+
+func (s *TaskService) runBatchOperation(actor entities.Actor, op BatchOperation, result *BatchResult) error {
+	if op.Err != nil {
+		return op.Err
+	}
+
+	var err error
+	switch op.Op {
+	case BatchCreate:
+		id, err := s.AddTask(actor, op.OwnerID, op.Task)
+		if err != nil {
+			return err
+		}
+		result.ID = strconv.FormatInt(id, 10)
+	case BatchUpdate:
+		err = s.UpdateTask(actor, op.ID, op.Version, op.Patch)
+	case BatchDelete:
+		return s.DeleteTask(actor, op.ID, op.Version)
+	case BatchDone:
+		err = s.CompleteTask(actor, op.ID, op.Version)
+	}
+	if err != nil {
+		return err
+	}
+
+	task, _, err := s.GetTask(actor.UserID, result.ID, entities.PermissionViewer)
+	if errors.Is(err, ErrTaskNotFound) && op.Op == BatchDone {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	result.Task = task
+	return nil
+}
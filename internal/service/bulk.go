@@ -0,0 +1,82 @@
+package service
+
+import (
+	"time"
+
+	"github.com/antonkazachenko/go-todo-list-api/internal/entities"
+	storage "github.com/antonkazachenko/go-todo-list-api/internal/storage/sqlite"
+	"github.com/antonkazachenko/go-todo-list-api/utils"
+)
+
+const (
+	BulkReschedule = "reschedule"
+	BulkDone       = "done"
+	BulkDelete     = "delete"
+	BulkSet        = "set"
+)
+
+// BulkAction is what Bulk does to each task: move it by Days, complete it,
+// delete it or apply Patch.
+type BulkAction struct {
+	Action string
+	Days   int
+	Patch  entities.TaskPatch
+}
+
+// Bulk applies the action to every task of the owner's list that matches the
+// filter, in one transaction, and returns the ids of those tasks. With dryRun
+// nothing is changed.
+func (s *TaskService) Bulk(actor entities.Actor, ownerID int64, filter entities.TaskFilter, action BulkAction, dryRun bool) ([]string, error) {
+	_, shareFilter, err := s.Authorize(actor.UserID, ownerID, entities.PermissionEditor)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []string{}
+	flush := func() {}
+	err = s.Repo.InTx(func(repo *storage.SQLiteTaskRepository) error {
+		tasks, err := repo.FindTasks(ownerID, shareFilter, filter)
+		if err != nil {
+			return err
+		}
+		for _, task := range tasks {
+			ids = append(ids, task.ID)
+		}
+		if dryRun {
+			return nil
+		}
+
+		var tx *TaskService
+		tx, flush = s.inTx(repo)
+		for _, task := range tasks {
+			if err := tx.applyBulkAction(actor, task, action); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	flush()
+	return ids, nil
+}
+
+func (s *TaskService) applyBulkAction(actor entities.Actor, task entities.Task, action BulkAction) error {
+	switch action.Action {
+	case BulkReschedule:
+		date, err := time.Parse(Format, task.Date)
+		if err != nil {
+			return utils.NewError("invalid_format", "date")
+		}
+		next := date.AddDate(0, 0, action.Days).Format(Format)
+		return s.UpdateTask(actor, task.ID, task.Version, entities.TaskPatch{Date: &next})
+	case BulkDone:
+		return s.CompleteTask(actor, task.ID, task.Version)
+	case BulkDelete:
+		return s.DeleteTask(actor, task.ID, task.Version)
+	default:
+		return s.UpdateTask(actor, task.ID, task.Version, action.Patch)
+	}
+}
@@ -0,0 +1,222 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	"github.com/antonkazachenko/go-todo-list-api/internal/entities"
+	storage "github.com/antonkazachenko/go-todo-list-api/internal/storage/sqlite"
+)
+
+const subscriberBuffer = 64
+
+// EventBus fans task events out to subscribers and keeps the last ones so
+// that a client that reconnects can catch up.
+type EventBus struct {
+	mu          sync.Mutex
+	size        int
+	lastID      int64
+	replay      []entities.TaskEvent
+	subscribers map[chan entities.TaskEvent]struct{}
+}
+
+func NewEventBus(size int) *EventBus {
+	if size < 0 {
+		size = 0
+	}
+	return &EventBus{size: size, subscribers: map[chan entities.TaskEvent]struct{}{}}
+}
+
+// Publish numbers the event and hands it to every subscriber. A subscriber
+// that does not keep up is dropped; it can resume from the replay buffer.
+func (b *EventBus) Publish(event entities.TaskEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastID++
+	event.ID = b.lastID
+	event.Time = time.Now().UTC()
+
+	b.replay = append(b.replay, event)
+	if len(b.replay) > b.size {
+		b.replay = append([]entities.TaskEvent(nil), b.replay[len(b.replay)-b.size:]...)
+	}
+
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// LastID is the id of the latest event.
+func (b *EventBus) LastID() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.lastID
+}
+
+// Subscribe returns the buffered events after lastID and a channel for the
+// ones that follow, closed if the subscriber falls behind. complete is false
+// when events after lastID are no longer buffered, or lastID was never
+// issued, so the client has to reload. A lastID of 0 asks for new events only.
+func (b *EventBus) Subscribe(lastID int64) (replay []entities.TaskEvent, events <-chan entities.TaskEvent, complete bool, cancel func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch := make(chan entities.TaskEvent, subscriberBuffer)
+	b.subscribers[ch] = struct{}{}
+	cancel = func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subscribers[ch]; ok {
+			delete(b.subscribers, ch)
+			close(ch)
+		}
+	}
+
+	if lastID == 0 {
+		return nil, ch, true, cancel
+	}
+
+	complete = lastID <= b.lastID
+	if len(b.replay) > 0 && lastID < b.replay[0].ID-1 {
+		complete = false
+	}
+	for _, event := range b.replay {
+		if event.ID > lastID {
+			replay = append(replay, event)
+		}
+	}
+	return replay, ch, complete, cancel
+}
+
+// CanSee reports whether the user may see the event: the list is theirs or
+// shared with them, and the task matches the share filter.
+func (s *TaskService) CanSee(userID int64, event entities.TaskEvent) bool {
+	_, filter, err := s.ListPermission(userID, event.OwnerID)
+	if err != nil {
+		return false
+	}
+	return filter == "" || event.Task == nil || taskMatches(*event.Task, filter)
+}
+
+// InQuery reports whether the task in the event is in the list
+// GetTasks(userID, event.OwnerID, search) would return.
+func (s *TaskService) InQuery(userID int64, search string, event entities.TaskEvent) bool {
+	if event.Removed || event.Task == nil || !s.CanSee(userID, event) {
+		return false
+	}
+	return search == "" || taskMatches(*event.Task, search)
+}
+
+// taskMatches is the search condition of the task repository applied to a
+// task in memory, with the semantics of SQLite's LIKE.
+func taskMatches(task entities.Task, term string) bool {
+	if date, err := time.Parse("02.01.2006", term); err == nil {
+		return task.Date == date.Format(Format)
+	}
+	pattern := "%" + term + "%"
+	return likeMatch(pattern, task.Title) || likeMatch(pattern, task.Comment)
+}
+
+// likeMatch reports whether s matches the pattern as SQLite's LIKE without
+// ESCAPE does: % matches any run of characters, _ any one character, and
+// only ASCII letters are compared ignoring case.
+func likeMatch(pattern, s string) bool {
+	p, str := []rune(foldASCII(pattern)), []rune(foldASCII(s))
+
+	// star is the position after the last % and retry the position in s it
+	// is tried from; on a mismatch the % takes one more character.
+	star, retry := -1, 0
+	i, j := 0, 0
+	for j < len(str) {
+		switch {
+		case i < len(p) && p[i] == '%':
+			star, retry = i+1, j
+			i++
+		case i < len(p) && (p[i] == '_' || p[i] == str[j]):
+			i++
+			j++
+		case star >= 0:
+			retry++
+			i, j = star, retry
+		default:
+			return false
+		}
+	}
+	for i < len(p) && p[i] == '%' {
+		i++
+	}
+	return i == len(p)
+}
+
+func (s *TaskService) publish(eventType string, ownerID int64, id string, task *entities.Task, removed bool) {
+	event := entities.TaskEvent{Type: eventType, OwnerID: ownerID, TaskID: id, Task: task, Removed: removed}
+	if s.pending != nil {
+		*s.pending = append(*s.pending, event)
+		return
+	}
+	if s.Events != nil {
+		s.Events.Publish(event)
+	}
+}
+
+// publishTask publishes the task as it is now.
+func (s *TaskService) publishTask(eventType string, ownerID int64, id string) {
+	if !s.publishing() {
+		return
+	}
+	task, err := s.Repo.GetTaskByID(ownerID, id)
+	if err != nil {
+		return
+	}
+	s.publish(eventType, ownerID, id, task, false)
+}
+
+// publishRemoved publishes a task that no longer exists, as it was read
+// before the change.
+func (s *TaskService) publishRemoved(eventType string, ownerID int64, task *entities.Task) {
+	removed := *task
+	removed.Permission = ""
+	s.publish(eventType, ownerID, task.ID, &removed, true)
+}
+
+func (s *TaskService) publishing() bool {
+	return s.Events != nil || s.pending != nil
+}
+
+// inTx returns a copy of the service bound to repo that holds its events
+// back until flush is called after the commit.
+func (s *TaskService) inTx(repo *storage.SQLiteTaskRepository) (tx *TaskService, flush func()) {
+	tx = &TaskService{Repo: repo, Shares: s.Shares}
+	if !s.publishing() {
+		return tx, func() {}
+	}
+
+	var pending []entities.TaskEvent
+	tx.pending = &pending
+	return tx, func() {
+		for _, event := range pending {
+			s.publish(event.Type, event.OwnerID, event.TaskID, event.Task, event.Removed)
+		}
+	}
+}
+
+// savepoint runs fn in a savepoint of the transaction and drops the events fn
+// queued if it fails.
+func (s *TaskService) savepoint(fn func() error) error {
+	queued := 0
+	if s.pending != nil {
+		queued = len(*s.pending)
+	}
+
+	err := s.Repo.Savepoint(fn)
+	if err != nil && s.pending != nil {
+		*s.pending = (*s.pending)[:queued]
+	}
+	return err
+}
@@ -0,0 +1,387 @@
+package service
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/antonkazachenko/go-todo-list-api/models"
+	"github.com/antonkazachenko/go-todo-list-api/utils"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+var ErrUnknownKey = utils.NewError("unknown_signing_key")
+
+type SigningKey struct {
+	ID      string
+	Method  jwt.SigningMethod
+	Private interface{}
+	Public  interface{}
+}
+
+func (k *SigningKey) CanSign() bool {
+	return k.Private != nil
+}
+
+func (k *SigningKey) Symmetric() bool {
+	_, ok := k.Method.(*jwt.SigningMethodHMAC)
+	return ok
+}
+
+type Keyring struct {
+	keys []*SigningKey
+	byID map[string]*SigningKey
+}
+
+func NewKeyring() *Keyring {
+	return &Keyring{byID: map[string]*SigningKey{}}
+}
+
+func LoadKeyring(paths []string, secret string) (*Keyring, error) {
+	keyring := NewKeyring()
+
+	for _, path := range paths {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		key, err := LoadKeyFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if err := keyring.Add(key); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	if secret != "" {
+		if err := keyring.Add(NewSecretKey([]byte(secret))); err != nil {
+			return nil, err
+		}
+	}
+
+	return keyring, nil
+}
+
+func (k *Keyring) Add(key *SigningKey) error {
+	if _, exists := k.byID[key.ID]; exists {
+		return fmt.Errorf("duplicate key id %q", key.ID)
+	}
+	k.keys = append(k.keys, key)
+	k.byID[key.ID] = key
+	return nil
+}
+
+func (k *Keyring) Signer() (*SigningKey, error) {
+	for _, key := range k.keys {
+		if key.CanSign() {
+			return key, nil
+		}
+	}
+	return nil, errors.New("no signing key configured")
+}
+
+func (k *Keyring) Sign(claims jwt.Claims) (string, error) {
+	key, err := k.Signer()
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.Private)
+}
+
+// Keyfunc looks the key up by kid and refuses tokens whose alg does not
+// match it. Tokens issued before kid was introduced are checked against the
+// HMAC secret.
+func (k *Keyring) Keyfunc(token *jwt.Token) (interface{}, error) {
+	if kid, ok := token.Header["kid"].(string); ok {
+		key, found := k.byID[kid]
+		if !found || key.Method.Alg() != token.Method.Alg() {
+			return nil, ErrUnknownKey
+		}
+		return key.Public, nil
+	}
+
+	for _, key := range k.keys {
+		if key.Symmetric() && key.Method.Alg() == token.Method.Alg() {
+			return key.Public, nil
+		}
+	}
+	return nil, ErrUnknownKey
+}
+
+func (k *Keyring) JWKS() models.JWKSet {
+	set := models.JWKSet{Keys: []models.JWK{}}
+	for _, key := range k.keys {
+		if key.Symmetric() {
+			continue
+		}
+		jwk, err := publicJWK(key.Public)
+		if err != nil {
+			continue
+		}
+		jwk.Kid = key.ID
+		jwk.Alg = key.Method.Alg()
+		jwk.Use = "sig"
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
+
+func NewSecretKey(secret []byte) *SigningKey {
+	sum := sha256.Sum256(secret)
+	return &SigningKey{
+		ID:      "hs-" + base64.RawURLEncoding.EncodeToString(sum[:6]),
+		Method:  jwt.SigningMethodHS256,
+		Private: secret,
+		Public:  secret,
+	}
+}
+
+func LoadKeyFile(path string) (*SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseKey(data)
+}
+
+// ParseKey accepts a PEM private key (signs and verifies), a PEM public key
+// (verifies only) or, for anything that is not PEM, a raw HMAC secret.
+func ParseKey(data []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		secret := []byte(strings.TrimSpace(string(data)))
+		if len(secret) < 32 {
+			return nil, errors.New("HMAC secret must be at least 32 bytes")
+		}
+		return NewSecretKey(secret), nil
+	}
+
+	var private crypto.Signer
+	var public crypto.PublicKey
+	var err error
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		var parsed interface{}
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err == nil {
+			signer, ok := parsed.(crypto.Signer)
+			if !ok {
+				return nil, fmt.Errorf("unsupported private key type %T", parsed)
+			}
+			private = signer
+		}
+	case "RSA PRIVATE KEY":
+		private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		private, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		public, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "RSA PUBLIC KEY":
+		public, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if private != nil {
+		public = private.Public()
+	}
+
+	method, err := signingMethodFor(public)
+	if err != nil {
+		return nil, err
+	}
+
+	jwk, err := publicJWK(public)
+	if err != nil {
+		return nil, err
+	}
+
+	key := &SigningKey{
+		ID:     jwkThumbprint(jwk),
+		Method: method,
+		Public: public,
+	}
+	if private != nil {
+		key.Private = private
+	}
+	return key, nil
+}
+
+// GenerateKey returns a new PKCS#8 PEM private key for RS256, ES256 or EdDSA.
+func GenerateKey(alg string) ([]byte, error) {
+	var private interface{}
+	var err error
+
+	switch strings.ToUpper(alg) {
+	case "RS256":
+		private, err = rsa.GenerateKey(rand.Reader, 2048)
+	case "ES256":
+		private, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case "EDDSA", "ED25519":
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q, use RS256, ES256 or EdDSA", alg)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+func signingMethodFor(public crypto.PublicKey) (jwt.SigningMethod, error) {
+	switch key := public.(type) {
+	case *rsa.PublicKey:
+		if key.N.BitLen() < 2048 {
+			return nil, errors.New("RSA keys must be at least 2048 bits")
+		}
+		return jwt.SigningMethodRS256, nil
+	case *ecdsa.PublicKey:
+		switch key.Curve {
+		case elliptic.P256():
+			return jwt.SigningMethodES256, nil
+		case elliptic.P384():
+			return jwt.SigningMethodES384, nil
+		case elliptic.P521():
+			return jwt.SigningMethodES512, nil
+		}
+		return nil, fmt.Errorf("unsupported curve %s", key.Curve.Params().Name)
+	case ed25519.PublicKey:
+		return jwt.SigningMethodEdDSA, nil
+	}
+	return nil, fmt.Errorf("unsupported public key type %T", public)
+}
+
+func publicJWK(public crypto.PublicKey) (models.JWK, error) {
+	switch key := public.(type) {
+	case *rsa.PublicKey:
+		return models.JWK{
+			Kty: "RSA",
+			N:   encodeBase64URL(key.N.Bytes()),
+			E:   encodeBase64URL(big.NewInt(int64(key.E)).Bytes()),
+		}, nil
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		return models.JWK{
+			Kty: "EC",
+			Crv: key.Curve.Params().Name,
+			X:   encodeBase64URL(key.X.FillBytes(make([]byte, size))),
+			Y:   encodeBase64URL(key.Y.FillBytes(make([]byte, size))),
+		}, nil
+	case ed25519.PublicKey:
+		return models.JWK{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			X:   encodeBase64URL(key),
+		}, nil
+	}
+	return models.JWK{}, fmt.Errorf("unsupported public key type %T", public)
+}
+
+// parseJWK turns a public JWK published by another party into a verification
+// key and the algorithm it is used with.
+func parseJWK(jwk models.JWK) (crypto.PublicKey, jwt.SigningMethod, error) {
+	decode := base64.RawURLEncoding.DecodeString
+
+	var public crypto.PublicKey
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decode(jwk.N)
+		if err != nil {
+			return nil, nil, err
+		}
+		e, err := decode(jwk.E)
+		if err != nil {
+			return nil, nil, err
+		}
+		public = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, nil, fmt.Errorf("unsupported curve %q", jwk.Crv)
+		}
+		x, err := decode(jwk.X)
+		if err != nil {
+			return nil, nil, err
+		}
+		y, err := decode(jwk.Y)
+		if err != nil {
+			return nil, nil, err
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(key.X, key.Y) {
+			return nil, nil, errors.New("EC point is not on the curve")
+		}
+		public = key
+	case "OKP":
+		x, err := decode(jwk.X)
+		if err != nil {
+			return nil, nil, err
+		}
+		if jwk.Crv != "Ed25519" || len(x) != ed25519.PublicKeySize {
+			return nil, nil, fmt.Errorf("unsupported OKP key %q", jwk.Crv)
+		}
+		public = ed25519.PublicKey(x)
+	default:
+		return nil, nil, fmt.Errorf("unsupported key type %q", jwk.Kty)
+	}
+
+	method, err := signingMethodFor(public)
+	if err != nil {
+		return nil, nil, err
+	}
+	if jwk.Alg != "" && jwk.Alg != method.Alg() {
+		return nil, nil, fmt.Errorf("unsupported algorithm %q", jwk.Alg)
+	}
+	return public, method, nil
+}
+
+// jwkThumbprint is the RFC 7638 thumbprint, used as a stable kid.
+func jwkThumbprint(jwk models.JWK) string {
+	var canonical string
+	switch jwk.Kty {
+	case "RSA":
+		canonical = fmt.Sprintf(`{"e":%q,"kty":%q,"n":%q}`, jwk.E, jwk.Kty, jwk.N)
+	case "EC":
+		canonical = fmt.Sprintf(`{"crv":%q,"kty":%q,"x":%q,"y":%q}`, jwk.Crv, jwk.Kty, jwk.X, jwk.Y)
+	default:
+		canonical = fmt.Sprintf(`{"crv":%q,"kty":%q,"x":%q}`, jwk.Crv, jwk.Kty, jwk.X)
+	}
+	sum := sha256.Sum256([]byte(canonical))
+	return encodeBase64URL(sum[:])
+}
+
+func encodeBase64URL(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
@@ -0,0 +1,157 @@
+package service
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/antonkazachenko/go-todo-list-api/utils"
+)
+
+var errTooManyAttempts = utils.NewError("too_many_attempts")
+
+type RetryAfterError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RetryAfterError) Error() string {
+	return errTooManyAttempts.Error()
+}
+
+func (e *RetryAfterError) Unwrap() error {
+	return errTooManyAttempts
+}
+
+// LoginLimiter tracks failed sign-ins per account and per IP. Once a key
+// reaches its threshold every further failure doubles the wait, starting at
+// Backoff and capped at Lockout. Counters are forgotten after Lockout without
+// failures.
+type LoginLimiter struct {
+	AccountAttempts int
+	IPAttempts      int
+	Backoff         time.Duration
+	Lockout         time.Duration
+
+	mu        sync.Mutex
+	attempts  map[string]*loginAttempts
+	lastPrune time.Time
+}
+
+type loginAttempts struct {
+	failures     int
+	lastFailure  time.Time
+	blockedUntil time.Time
+}
+
+func NewLoginLimiter(accountAttempts, ipAttempts int, backoff, lockout time.Duration) *LoginLimiter {
+	return &LoginLimiter{
+		AccountAttempts: accountAttempts,
+		IPAttempts:      ipAttempts,
+		Backoff:         backoff,
+		Lockout:         lockout,
+		attempts:        map[string]*loginAttempts{},
+	}
+}
+
+// Check returns how long the caller has to wait before the next attempt, or
+// zero if it may proceed.
+func (l *LoginLimiter) Check(login, ip string) time.Duration {
+	if l == nil {
+		return 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	var wait time.Duration
+	for _, key := range []string{accountKey(login), ipKey(ip)} {
+		if entry := l.entry(key, now, false); entry != nil && entry.blockedUntil.After(now) {
+			if remaining := entry.blockedUntil.Sub(now); remaining > wait {
+				wait = remaining
+			}
+		}
+	}
+	return wait
+}
+
+func (l *LoginLimiter) Fail(login, ip string) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.prune(now)
+
+	l.fail(l.entry(accountKey(login), now, true), l.AccountAttempts, now)
+	l.fail(l.entry(ipKey(ip), now, true), l.IPAttempts, now)
+}
+
+func (l *LoginLimiter) Succeed(login string) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.attempts, accountKey(login))
+}
+
+func (l *LoginLimiter) fail(entry *loginAttempts, threshold int, now time.Time) {
+	entry.failures++
+	entry.lastFailure = now
+	if threshold <= 0 || entry.failures < threshold {
+		return
+	}
+
+	delay := l.Backoff
+	for i := threshold; i < entry.failures && delay < l.Lockout; i++ {
+		delay *= 2
+	}
+	if delay > l.Lockout {
+		delay = l.Lockout
+	}
+	entry.blockedUntil = now.Add(delay)
+}
+
+func (l *LoginLimiter) entry(key string, now time.Time, create bool) *loginAttempts {
+	entry, ok := l.attempts[key]
+	if ok && l.expired(entry, now) {
+		delete(l.attempts, key)
+		ok = false
+	}
+	if !ok && create {
+		entry = &loginAttempts{}
+		l.attempts[key] = entry
+	}
+	return entry
+}
+
+func (l *LoginLimiter) expired(entry *loginAttempts, now time.Time) bool {
+	return now.Sub(entry.lastFailure) > l.Lockout && !entry.blockedUntil.After(now)
+}
+
+func (l *LoginLimiter) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < time.Minute {
+		return
+	}
+	l.lastPrune = now
+
+	for key, entry := range l.attempts {
+		if l.expired(entry, now) {
+			delete(l.attempts, key)
+		}
+	}
+}
+
+func accountKey(login string) string {
+	return "login:" + strings.ToLower(login)
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
@@ -0,0 +1,389 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/antonkazachenko/go-todo-list-api/internal/entities"
+	"github.com/antonkazachenko/go-todo-list-api/models"
+	"github.com/antonkazachenko/go-todo-list-api/utils"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const oidcLoginTTL = 10 * time.Minute
+
+var (
+	ErrOIDCDisabled     = utils.NewError("oidc_disabled")
+	ErrOIDCInvalidState = utils.NewError("oidc_invalid_state")
+	ErrOIDCInvalidToken = utils.NewError("oidc_invalid_token")
+
+	loginUnsafeChars = regexp.MustCompile(`[^a-zA-Z0-9._@-]+`)
+)
+
+type OIDCConfig struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	Admins       []string
+}
+
+func (c OIDCConfig) Enabled() bool {
+	return c.Issuer != "" && c.ClientID != ""
+}
+
+type OIDCService struct {
+	Config      OIDCConfig
+	UserService *UserService
+	Client      *http.Client
+
+	mu        sync.Mutex
+	discovery *oidcDiscovery
+	keys      map[string]models.JWK
+	pending   map[string]oidcLogin
+}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type oidcLogin struct {
+	nonce       string
+	verifier    string
+	redirectURL string
+	expires     time.Time
+}
+
+type idTokenClaims struct {
+	jwt.RegisteredClaims
+	Nonce             string `json:"nonce"`
+	Email             string `json:"email"`
+	PreferredUsername string `json:"preferred_username"`
+}
+
+func NewOIDCService(config OIDCConfig, userService *UserService) *OIDCService {
+	config.Issuer = strings.TrimSuffix(config.Issuer, "/")
+	return &OIDCService{
+		Config:      config,
+		UserService: userService,
+		Client:      &http.Client{Timeout: 10 * time.Second},
+		pending:     map[string]oidcLogin{},
+	}
+}
+
+func (s *OIDCService) Enabled() bool {
+	return s.Config.Enabled()
+}
+
+// Begin starts an authorization code flow with PKCE and returns the provider
+// URL to redirect to and the state that the callback must bring back.
+func (s *OIDCService) Begin(ctx context.Context, redirectURL string) (string, string, error) {
+	if !s.Enabled() {
+		return "", "", ErrOIDCDisabled
+	}
+	if s.Config.RedirectURL != "" {
+		redirectURL = s.Config.RedirectURL
+	}
+
+	discovery, err := s.discover(ctx)
+	if err != nil {
+		return "", "", err
+	}
+
+	state, err := randomToken(16)
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := randomToken(16)
+	if err != nil {
+		return "", "", err
+	}
+	verifier, err := randomToken(32)
+	if err != nil {
+		return "", "", err
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	for key, login := range s.pending {
+		if now.After(login.expires) {
+			delete(s.pending, key)
+		}
+	}
+	s.pending[state] = oidcLogin{nonce: nonce, verifier: verifier, redirectURL: redirectURL, expires: now.Add(oidcLoginTTL)}
+	s.mu.Unlock()
+
+	challenge := sha256.Sum256([]byte(verifier))
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {s.Config.ClientID},
+		"redirect_uri":          {redirectURL},
+		"scope":                 {strings.Join(s.scopes(), " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+
+	separator := "?"
+	if strings.Contains(discovery.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return discovery.AuthorizationEndpoint + separator + query.Encode(), state, nil
+}
+
+// Finish redeems the authorization code, verifies the ID token and returns the
+// local account mapped to its sub claim, creating one on first sign-in.
+func (s *OIDCService) Finish(ctx context.Context, state, code string) (*entities.User, error) {
+	if !s.Enabled() {
+		return nil, ErrOIDCDisabled
+	}
+
+	s.mu.Lock()
+	login, ok := s.pending[state]
+	delete(s.pending, state)
+	s.mu.Unlock()
+	if !ok || time.Now().After(login.expires) || code == "" {
+		return nil, ErrOIDCInvalidState
+	}
+
+	discovery, err := s.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	idToken, err := s.exchange(ctx, discovery, code, login)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := s.verifyIDToken(ctx, discovery, idToken, login.nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.UserService.ExternalUser(discovery.Issuer, claims.Subject, loginHint(claims), s.isAdmin(claims))
+}
+
+func (s *OIDCService) exchange(ctx context.Context, discovery *oidcDiscovery, code string, login oidcLogin) (string, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {login.redirectURL},
+		"code_verifier": {login.verifier},
+	}
+	if s.Config.ClientSecret == "" {
+		form.Set("client_id", s.Config.ClientID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if s.Config.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(s.Config.ClientID), url.QueryEscape(s.Config.ClientSecret))
+	}
+
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	status, err := s.doJSON(req, &body)
+	if err != nil {
+		return "", err
+	}
+	if status != http.StatusOK || body.IDToken == "" {
+		return "", fmt.Errorf("token endpoint returned %d: %s %s", status, body.Error, body.ErrorDescription)
+	}
+
+	return body.IDToken, nil
+}
+
+func (s *OIDCService) verifyIDToken(ctx context.Context, discovery *oidcDiscovery, idToken, nonce string) (*idTokenClaims, error) {
+	claims := &idTokenClaims{}
+	token, err := jwt.ParseWithClaims(idToken, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, method, err := s.providerKey(ctx, discovery, kid)
+		if err != nil {
+			return nil, err
+		}
+		if method.Alg() != token.Method.Alg() {
+			return nil, ErrOIDCInvalidToken
+		}
+		return key, nil
+	})
+	if err != nil || !token.Valid {
+		return nil, ErrOIDCInvalidToken
+	}
+
+	if claims.Issuer != discovery.Issuer || !claims.VerifyAudience(s.Config.ClientID, true) ||
+		claims.ExpiresAt == nil || claims.Subject == "" || claims.Nonce != nonce {
+		return nil, ErrOIDCInvalidToken
+	}
+
+	return claims, nil
+}
+
+// providerKey looks the kid up in the cached provider JWKS and refetches it
+// once if the key is unknown, so provider key rotation is picked up.
+func (s *OIDCService) providerKey(ctx context.Context, discovery *oidcDiscovery, kid string) (interface{}, jwt.SigningMethod, error) {
+	for attempt := 0; attempt < 2; attempt++ {
+		s.mu.Lock()
+		keys := s.keys
+		s.mu.Unlock()
+
+		if keys == nil || attempt > 0 {
+			var err error
+			if keys, err = s.fetchKeys(ctx, discovery); err != nil {
+				return nil, nil, err
+			}
+		}
+
+		if jwk, ok := keys[kid]; ok {
+			return parseJWK(jwk)
+		}
+		if kid == "" && len(keys) == 1 {
+			for _, jwk := range keys {
+				return parseJWK(jwk)
+			}
+		}
+	}
+	return nil, nil, ErrOIDCInvalidToken
+}
+
+func (s *OIDCService) fetchKeys(ctx context.Context, discovery *oidcDiscovery) (map[string]models.JWK, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, discovery.JWKSURI, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var set models.JWKSet
+	status, err := s.doJSON(req, &set)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("jwks endpoint returned %d", status)
+	}
+
+	keys := map[string]models.JWK{}
+	for _, jwk := range set.Keys {
+		if jwk.Use == "" || jwk.Use == "sig" {
+			keys[jwk.Kid] = jwk
+		}
+	}
+
+	s.mu.Lock()
+	s.keys = keys
+	s.mu.Unlock()
+	return keys, nil
+}
+
+func (s *OIDCService) discover(ctx context.Context) (*oidcDiscovery, error) {
+	s.mu.Lock()
+	discovery := s.discovery
+	s.mu.Unlock()
+	if discovery != nil {
+		return discovery, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.Config.Issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	discovery = &oidcDiscovery{}
+	status, err := s.doJSON(req, discovery)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("discovery endpoint returned %d", status)
+	}
+	if strings.TrimSuffix(discovery.Issuer, "/") != s.Config.Issuer {
+		return nil, fmt.Errorf("discovery issuer %q does not match %q", discovery.Issuer, s.Config.Issuer)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, errors.New("discovery document is incomplete")
+	}
+
+	s.mu.Lock()
+	s.discovery = discovery
+	s.mu.Unlock()
+	return discovery, nil
+}
+
+func (s *OIDCService) doJSON(req *http.Request, target interface{}) (int, error) {
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return resp.StatusCode, err
+	}
+	if err := json.Unmarshal(data, target); err != nil && resp.StatusCode == http.StatusOK {
+		return resp.StatusCode, err
+	}
+	return resp.StatusCode, nil
+}
+
+func (s *OIDCService) scopes() []string {
+	scopes := s.Config.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "profile", "email"}
+	}
+	for _, scope := range scopes {
+		if scope == "openid" {
+			return scopes
+		}
+	}
+	return append([]string{"openid"}, scopes...)
+}
+
+func (s *OIDCService) isAdmin(claims *idTokenClaims) bool {
+	for _, admin := range s.Config.Admins {
+		admin = strings.TrimSpace(admin)
+		if admin != "" && (admin == claims.Subject || strings.EqualFold(admin, claims.Email)) {
+			return true
+		}
+	}
+	return false
+}
+
+func loginHint(claims *idTokenClaims) string {
+	hint := claims.PreferredUsername
+	if hint == "" {
+		hint = claims.Email
+	}
+
+	hint = loginUnsafeChars.ReplaceAllString(hint, "-")
+	if len(hint) > 56 {
+		hint = hint[:56]
+	}
+	if len(hint) < 3 {
+		sum := sha256.Sum256([]byte(claims.Subject))
+		hint = "sso-" + base64.RawURLEncoding.EncodeToString(sum[:6])
+	}
+	return hint
+}
@@ -0,0 +1,235 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/antonkazachenko/go-todo-list-api/internal/entities"
+	storage "github.com/antonkazachenko/go-todo-list-api/internal/storage/sqlite"
+	"github.com/antonkazachenko/go-todo-list-api/utils"
+)
+
+var (
+	ErrTaskNotFound     = utils.NewError("task_not_found")
+	ErrListNotFound     = utils.NewError("list_not_found")
+	ErrPermissionDenied = utils.NewError("permission_denied")
+	ErrShareNotFound    = utils.NewError("share_not_found")
+	ErrShareExists      = utils.NewError("share_exists")
+	ErrShareWithOwner   = utils.NewError("share_with_owner")
+	ErrInvalidShareRole = utils.NewError("invalid_value", "role")
+	ErrFilterTooLong    = utils.NewError("too_long/filter")
+	ErrOwnShare         = utils.NewError("own_share")
+	ErrFilterTooWide    = utils.NewError("filter_too_wide")
+)
+
+var permissionLevels = map[string]int{
+	entities.PermissionViewer: 1,
+	entities.PermissionEditor: 2,
+	entities.PermissionAdmin:  3,
+	entities.PermissionOwner:  4,
+}
+
+// Grants reports whether permission allows an action that needs required:
+// editors can do everything viewers can, admins can also manage shares.
+func Grants(permission, required string) bool {
+	return permissionLevels[required] > 0 && permissionLevels[permission] >= permissionLevels[required]
+}
+
+// ListPermission returns the caller's permission on the owner's list and the
+// filter that limits which of its tasks the caller sees.
+func (s *TaskService) ListPermission(userID, ownerID int64) (string, string, error) {
+	if userID == ownerID {
+		return entities.PermissionOwner, "", nil
+	}
+
+	share, err := s.Shares.GetShare(ownerID, userID)
+	if errors.Is(err, storage.ErrShareNotFound) {
+		return "", "", ErrListNotFound
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	return share.Role, share.Filter, nil
+}
+
+func (s *TaskService) Authorize(userID, ownerID int64, required string) (string, string, error) {
+	permission, filter, err := s.ListPermission(userID, ownerID)
+	if err != nil {
+		return "", "", err
+	}
+	if !Grants(permission, required) {
+		return "", "", ErrPermissionDenied
+	}
+	return permission, filter, nil
+}
+
+func (s *TaskService) GetShares(userID, ownerID int64) ([]entities.Share, error) {
+	if userID == ownerID {
+		return s.Shares.GetSharesByUser(userID)
+	}
+
+	if _, _, err := s.Authorize(userID, ownerID, entities.PermissionAdmin); err != nil {
+		return nil, err
+	}
+	return s.Shares.GetSharesByOwner(ownerID)
+}
+
+func (s *TaskService) AddShare(userID, ownerID int64, target *entities.User, role, filter string) (*entities.Share, error) {
+	_, limit, err := s.Authorize(userID, ownerID, entities.PermissionAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	filter, err = validateShare(role, filter)
+	if err != nil {
+		return nil, err
+	}
+	if !narrowerFilter(filter, limit) {
+		return nil, ErrFilterTooWide
+	}
+	if target.ID == ownerID {
+		return nil, ErrShareWithOwner
+	}
+
+	_, err = s.Shares.GetShare(ownerID, target.ID)
+	if err == nil {
+		return nil, ErrShareExists
+	}
+	if !errors.Is(err, storage.ErrShareNotFound) {
+		return nil, err
+	}
+
+	id, err := s.Shares.AddShare(entities.Share{
+		OwnerID: ownerID,
+		UserID:  target.ID,
+		Role:    role,
+		Filter:  filter,
+		Created: time.Now().UTC(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Shares.GetShareByID(id)
+}
+
+// UpdateShare changes the role and, when filter is not nil, the filter of a
+// share. Admins cannot change their own share.
+func (s *TaskService) UpdateShare(userID, id int64, role string, filter *string) (*entities.Share, error) {
+	share, limit, err := s.manageableShare(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if share.UserID == userID {
+		return nil, ErrOwnShare
+	}
+
+	if role == "" {
+		role = share.Role
+	}
+	if filter == nil {
+		filter = &share.Filter
+	}
+	normalized, err := validateShare(role, *filter)
+	if err != nil {
+		return nil, err
+	}
+	if !narrowerFilter(normalized, limit) {
+		return nil, ErrFilterTooWide
+	}
+
+	if _, err := s.Shares.UpdateShare(id, role, normalized); err != nil {
+		return nil, err
+	}
+
+	return s.Shares.GetShareByID(id)
+}
+
+// DeleteShare revokes a share; recipients may also remove their own access.
+func (s *TaskService) DeleteShare(userID, id int64) error {
+	share, err := s.Shares.GetShareByID(id)
+	if errors.Is(err, storage.ErrShareNotFound) {
+		return ErrShareNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if share.UserID != userID {
+		if _, _, err := s.manageableShare(userID, id); err != nil {
+			return err
+		}
+	}
+
+	_, err = s.Shares.DeleteShare(id)
+	return err
+}
+
+// manageableShare loads a share the caller administers together with the
+// caller's own filter on the list, which bounds the filters they can grant.
+func (s *TaskService) manageableShare(userID, id int64) (*entities.Share, string, error) {
+	share, err := s.Shares.GetShareByID(id)
+	if errors.Is(err, storage.ErrShareNotFound) {
+		return nil, "", ErrShareNotFound
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	_, limit, err := s.Authorize(userID, share.OwnerID, entities.PermissionAdmin)
+	if errors.Is(err, ErrListNotFound) {
+		return nil, "", ErrShareNotFound
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	return share, limit, nil
+}
+
+// narrowerFilter reports whether every task matching filter also matches
+// limit. A date only covers the same date; a text filter covers any text
+// filter that contains it, ignoring ASCII case as LIKE does.
+func narrowerFilter(filter, limit string) bool {
+	if limit == "" {
+		return true
+	}
+	if filter == "" {
+		return false
+	}
+
+	filterDate, filterErr := time.Parse("02.01.2006", filter)
+	limitDate, limitErr := time.Parse("02.01.2006", limit)
+	switch {
+	case limitErr == nil:
+		return filterErr == nil && filterDate.Equal(limitDate)
+	case filterErr == nil:
+		return false
+	default:
+		return strings.Contains(foldASCII(filter), foldASCII(limit))
+	}
+}
+
+// foldASCII lowercases ASCII letters only, like SQLite's LIKE and lower().
+func foldASCII(s string) string {
+	return strings.Map(func(r rune) rune {
+		if 'A' <= r && r <= 'Z' {
+			return r + 'a' - 'A'
+		}
+		return r
+	}, s)
+}
+
+func validateShare(role, filter string) (string, error) {
+	if role != entities.PermissionViewer && role != entities.PermissionEditor && role != entities.PermissionAdmin {
+		return "", ErrInvalidShareRole
+	}
+
+	filter = strings.TrimSpace(filter)
+	if len(filter) > 255 {
+		return "", ErrFilterTooLong
+	}
+	return filter, nil
+}
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/antonkazachenko/go-todo-list-api/internal/entities"
+	storage "github.com/antonkazachenko/go-todo-list-api/internal/storage/sqlite"
+	"github.com/antonkazachenko/go-todo-list-api/utils"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	ShareLinkPath = "/s/"
+
+	shareLinkTaskLimit = 100
+)
+
+var (
+	ErrShareLinkNotFound     = utils.NewError("link_not_found")
+	ErrShareLinkPassword     = utils.NewError("password_required")
+	ErrShareLinkBadPassword  = utils.NewError("invalid_password")
+	ErrShareLinkInvalidTasks = utils.NewError("task_not_found")
+)
+
+type ShareLinkService struct {
+	Repo    *storage.SQLiteShareLinkRepository
+	Tasks   *storage.SQLiteTaskRepository
+	Limiter *LoginLimiter
+}
+
+func NewShareLinkService(repo *storage.SQLiteShareLinkRepository, tasks *storage.SQLiteTaskRepository, limiter *LoginLimiter) *ShareLinkService {
+	return &ShareLinkService{Repo: repo, Tasks: tasks, Limiter: limiter}
+}
+
+// Create makes a read-only link to the owner's tasks matching filter and,
+// if taskIDs is not empty, only to those tasks. It returns the secret link
+// token, which is stored hashed.
+func (s *ShareLinkService) Create(ownerID int64, name, filter string, taskIDs []string, password string, ttl time.Duration) (string, *entities.ShareLink, error) {
+	name = strings.TrimSpace(name)
+	if len(name) > 64 {
+		return "", nil, utils.NewError("too_long/link_name")
+	}
+	if ttl < 0 {
+		return "", nil, utils.NewError("invalid_expiry/link")
+	}
+
+	filter, err := validateShare(entities.PermissionViewer, filter)
+	if err != nil {
+		return "", nil, err
+	}
+
+	for _, id := range taskIDs {
+		if _, err := strconv.ParseInt(id, 10, 64); err != nil {
+			return "", nil, ErrShareLinkInvalidTasks
+		}
+		if _, err := s.Tasks.GetTaskByID(ownerID, id); err != nil {
+			return "", nil, ErrShareLinkInvalidTasks
+		}
+	}
+
+	token, err := randomToken(24)
+	if err != nil {
+		return "", nil, err
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	link := &entities.ShareLink{
+		OwnerID:   ownerID,
+		Name:      name,
+		TokenHash: hashToken(token),
+		Filter:    filter,
+		TaskIDs:   taskIDs,
+		Created:   now,
+	}
+	if link.TaskIDs == nil {
+		link.TaskIDs = []string{}
+	}
+	if password != "" {
+		if link.PasswordHash, err = HashPassword(password); err != nil {
+			return "", nil, err
+		}
+		link.HasPassword = true
+	}
+	if ttl > 0 {
+		expires := now.Add(ttl)
+		link.Expires = &expires
+	}
+
+	link.ID, err = s.Repo.AddShareLink(*link)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return token, link, nil
+}
+
+// Open resolves a link token and returns the shared tasks. Wrong passwords
+// count towards the sign-in limiter under a key no account login can take.
+func (s *ShareLinkService) Open(token, password, ip string) (*entities.ShareLink, []entities.Task, error) {
+	link, err := s.Repo.GetShareLinkByHash(hashToken(token))
+	if errors.Is(err, storage.ErrShareLinkNotFound) {
+		return nil, nil, ErrShareLinkNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if link.Expires != nil && !time.Now().Before(*link.Expires) {
+		return nil, nil, ErrShareLinkNotFound
+	}
+
+	if link.HasPassword {
+		if password == "" {
+			return nil, nil, ErrShareLinkPassword
+		}
+
+		key := "link:" + strconv.FormatInt(link.ID, 10)
+		if wait := s.Limiter.Check(key, ip); wait > 0 {
+			return nil, nil, &RetryAfterError{RetryAfter: wait}
+		}
+		if bcrypt.CompareHashAndPassword([]byte(link.PasswordHash), []byte(password)) != nil {
+			s.Limiter.Fail(key, ip)
+			return nil, nil, ErrShareLinkBadPassword
+		}
+		s.Limiter.Succeed(key)
+	}
+
+	var tasks []entities.Task
+	if len(link.TaskIDs) > 0 {
+		tasks, err = s.Tasks.GetTasksByIDs(link.OwnerID, link.TaskIDs, link.Filter, shareLinkTaskLimit)
+	} else {
+		tasks, err = s.Tasks.GetTasks(link.OwnerID, link.Filter, "", shareLinkTaskLimit)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return link, tasks, nil
+}
@@ -0,0 +1,273 @@
+package service
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/antonkazachenko/go-todo-list-api/internal/entities"
+	storage "github.com/antonkazachenko/go-todo-list-api/internal/storage/sqlite"
+	"github.com/antonkazachenko/go-todo-list-api/utils"
+)
+
+const (
+	SyncCreate = "create"
+	SyncUpdate = "update"
+	SyncDelete = "delete"
+)
+
+const syncTokenPrefix = "sync:"
+
+var ErrSyncToken = utils.NewError("invalid_value", "since")
+
+// SyncDelta is what changed in a list since a sync token. With Reset the
+// client has to replace its copy by Tasks; otherwise Tasks were created or
+// changed and Deleted are ids of tasks that are gone or no longer visible.
+type SyncDelta struct {
+	Token   string
+	Reset   bool
+	Tasks   []entities.Task
+	Deleted []string
+}
+
+// SyncChange is a change a client made offline at the time Changed. Err is
+// set by the caller for a change it could not decode.
+type SyncChange struct {
+	Op      string
+	Ref     string
+	ID      string
+	Task    entities.Task
+	Patch   entities.TaskPatch
+	Changed time.Time
+	Err     error
+}
+
+// SyncConflict is a field the client changed that was written later on the
+// server, so the server's value was kept. Client is nil when the client
+// deleted the task.
+type SyncConflict struct {
+	Field  string
+	Server string
+	Client *string
+}
+
+// SyncResult is the outcome of one change: the task as it is now, nil once
+// it is deleted, and the fields that lost to newer server writes.
+type SyncResult struct {
+	ID        string
+	Task      *entities.Task
+	Applied   bool
+	Conflicts []SyncConflict
+	Err       error
+}
+
+// SyncToken encodes a change sequence number as an opaque token.
+func SyncToken(seq int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(syncTokenPrefix + strconv.FormatInt(seq, 10)))
+}
+
+func ParseSyncToken(token string) (int64, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || !strings.HasPrefix(string(data), syncTokenPrefix) {
+		return 0, ErrSyncToken
+	}
+	seq, err := strconv.ParseInt(strings.TrimPrefix(string(data), syncTokenPrefix), 10, 64)
+	if err != nil || seq < 0 {
+		return 0, ErrSyncToken
+	}
+	return seq, nil
+}
+
+// Changes returns what changed in the owner's list since the token. An empty
+// token, or one the server has not issued, gets the whole list.
+func (s *TaskService) Changes(userID, ownerID int64, token string) (*SyncDelta, error) {
+	var since int64
+	if token != "" {
+		var err error
+		if since, err = ParseSyncToken(token); err != nil {
+			return nil, err
+		}
+	}
+
+	permission, filter, err := s.Authorize(userID, ownerID, entities.PermissionViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	delta := &SyncDelta{Tasks: []entities.Task{}, Deleted: []string{}}
+	err = s.Repo.InTx(func(repo *storage.SQLiteTaskRepository) error {
+		last, err := repo.LastChange()
+		if err != nil {
+			return err
+		}
+		delta.Token = SyncToken(last)
+
+		if token == "" || since > last {
+			delta.Reset = true
+			tasks, err := repo.FindTasks(ownerID, filter, entities.TaskFilter{})
+			delta.Tasks = append(delta.Tasks, tasks...)
+			return err
+		}
+
+		tasks, deleted, err := repo.ChangesSince(ownerID, since)
+		if err != nil {
+			return err
+		}
+		for _, task := range tasks {
+			if filter == "" || taskMatches(task, filter) {
+				delta.Tasks = append(delta.Tasks, task)
+			} else {
+				delta.Deleted = append(delta.Deleted, task.ID)
+			}
+		}
+		delta.Deleted = append(delta.Deleted, deleted...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range delta.Tasks {
+		delta.Tasks[i].Permission = permission
+	}
+	return delta, nil
+}
+
+// Sync applies offline changes in order in one transaction. Each field of an
+// update is kept only if no later write reached the server; a delete loses to
+// any later write. A change that fails is undone without stopping the rest.
+func (s *TaskService) Sync(actor entities.Actor, ownerID int64, changes []SyncChange) ([]SyncResult, error) {
+	results := make([]SyncResult, len(changes))
+
+	flush := func() {}
+	err := s.Repo.InTx(func(repo *storage.SQLiteTaskRepository) error {
+		var tx *TaskService
+		tx, flush = s.inTx(repo)
+		for i, change := range changes {
+			results[i].ID = change.ID
+			err := tx.savepoint(func() error {
+				return tx.applySyncChange(actor, ownerID, change, &results[i])
+			})
+			if err != nil {
+				results[i] = SyncResult{ID: change.ID, Err: err}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	flush()
+	return results, nil
+}
+
+func (s *TaskService) applySyncChange(actor entities.Actor, ownerID int64, change SyncChange, result *SyncResult) error {
+	if change.Err != nil {
+		return change.Err
+	}
+
+	if change.Op == SyncCreate {
+		if change.Ref != "" {
+			id, err := s.Repo.SyncRef(ownerID, actor.UserID, change.Ref)
+			if err == nil {
+				result.ID = id
+				result.Applied = true
+				result.Task, _, err = s.GetTask(actor.UserID, id, entities.PermissionViewer)
+				if errors.Is(err, ErrTaskNotFound) {
+					return nil
+				}
+				return err
+			}
+			if !errors.Is(err, storage.ErrSyncRefNotFound) {
+				return err
+			}
+		}
+
+		id, err := s.AddTask(actor, ownerID, change.Task)
+		if err != nil {
+			return err
+		}
+		if change.Ref != "" {
+			if err := s.Repo.AddSyncRef(ownerID, actor.UserID, change.Ref, id); err != nil {
+				return err
+			}
+		}
+		result.ID = strconv.FormatInt(id, 10)
+		result.Applied = true
+		result.Task, _, err = s.GetTask(actor.UserID, result.ID, entities.PermissionViewer)
+		return err
+	}
+
+	task, taskOwner, err := s.GetTask(actor.UserID, change.ID, entities.PermissionEditor)
+	if errors.Is(err, ErrTaskNotFound) && change.Op == SyncDelete {
+		result.Applied = true
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	times, err := s.Repo.FieldTimes(taskOwner, change.ID)
+	if err != nil {
+		return err
+	}
+
+	changed := change.Changed.UnixMilli()
+	winning := entities.TaskPatch{}
+	for _, field := range syncFields(task, &change.Patch, &winning) {
+		if change.Op == SyncDelete {
+			if times[field.name] > changed {
+				result.Conflicts = append(result.Conflicts, SyncConflict{Field: field.name, Server: *field.current})
+			}
+			continue
+		}
+		if *field.patch == nil || **field.patch == *field.current {
+			continue
+		}
+		if times[field.name] > changed {
+			result.Conflicts = append(result.Conflicts, SyncConflict{Field: field.name, Server: *field.current, Client: *field.patch})
+			continue
+		}
+		*field.winning = *field.patch
+		result.Applied = true
+	}
+
+	if change.Op == SyncDelete {
+		if len(result.Conflicts) > 0 {
+			result.Task = task
+			return nil
+		}
+		result.Applied = true
+		return s.DeleteTask(actor, change.ID, "")
+	}
+
+	if result.Applied {
+		if _, err := s.Repo.UpdateTaskAt(taskOwner, change.ID, winning, "", actor, change.Changed); err != nil {
+			return changeError(err)
+		}
+		s.publishTask(entities.EventUpdated, taskOwner, change.ID)
+	}
+	result.Task, _, err = s.GetTask(actor.UserID, change.ID, entities.PermissionViewer)
+	return err
+}
+
+// syncField is a writable field: its value in the task, in the client's
+// patch and in the patch of the values that win.
+type syncField struct {
+	name    string
+	current *string
+	patch   **string
+	winning **string
+}
+
+func syncFields(task *entities.Task, patch, winning *entities.TaskPatch) []syncField {
+	return []syncField{
+		{"date", &task.Date, &patch.Date, &winning.Date},
+		{"title", &task.Title, &patch.Title, &winning.Title},
+		{"comment", &task.Comment, &patch.Comment, &winning.Comment},
+		{"repeat", &task.Repeat, &patch.Repeat, &winning.Repeat},
+		{"priority", &task.Priority, &patch.Priority, &winning.Priority},
+	}
+}
@@ -6,23 +6,194 @@ import (
 	"strings"
 	"time"
 
+	"github.com/antonkazachenko/go-todo-list-api/internal/entities"
 	storage "github.com/antonkazachenko/go-todo-list-api/internal/storage/sqlite"
+	"github.com/antonkazachenko/go-todo-list-api/utils"
 )
 
 const Format = "20060102"
 
+var (
+	ErrVersionConflict      = utils.NewError("version_conflict")
+	ErrPreconditionRequired = utils.NewError("precondition_required")
+)
+
 type TaskService struct {
-	Repo *storage.SQLiteTaskRepository
+	Repo   *storage.SQLiteTaskRepository
+	Shares *storage.SQLiteShareRepository
+	Events *EventBus
+
+	pending *[]entities.TaskEvent
+}
+
+func NewTaskService(repo *storage.SQLiteTaskRepository, shares *storage.SQLiteShareRepository) *TaskService {
+	return &TaskService{Repo: repo, Shares: shares}
+}
+
+// GetTask loads a task from any list shared with the caller and checks the
+// caller's permission on it. Tasks outside the caller's shares are reported as
+// not found.
+func (s *TaskService) GetTask(userID int64, id, required string) (*entities.Task, int64, error) {
+	ownerID, err := s.Repo.GetTaskOwner(id)
+	if errors.Is(err, storage.ErrTaskNotFound) {
+		return nil, 0, ErrTaskNotFound
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	permission, filter, err := s.ListPermission(userID, ownerID)
+	if errors.Is(err, ErrListNotFound) {
+		return nil, 0, ErrTaskNotFound
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	task, err := s.Repo.GetFilteredTaskByID(ownerID, id, filter)
+	if errors.Is(err, storage.ErrTaskNotFound) {
+		return nil, 0, ErrTaskNotFound
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if !Grants(permission, required) {
+		return nil, 0, ErrPermissionDenied
+	}
+
+	task.Permission = permission
+	return task, ownerID, nil
+}
+
+func (s *TaskService) GetTasks(userID, ownerID int64, searchTerm string, limit int) ([]entities.Task, error) {
+	permission, filter, err := s.Authorize(userID, ownerID, entities.PermissionViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	tasks, err := s.Repo.GetTasks(ownerID, filter, searchTerm, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range tasks {
+		tasks[i].Permission = permission
+	}
+	return tasks, nil
+}
+
+func (s *TaskService) AddTask(actor entities.Actor, ownerID int64, task entities.Task) (int64, error) {
+	if _, _, err := s.Authorize(actor.UserID, ownerID, entities.PermissionEditor); err != nil {
+		return 0, err
+	}
+
+	id, err := s.Repo.AddTask(ownerID, task, actor)
+	if err != nil {
+		return 0, err
+	}
+
+	s.publishTask(entities.EventCreated, ownerID, strconv.FormatInt(id, 10))
+	return id, nil
+}
+
+// ImportTasks adds the tasks to the owner's list in one transaction.
+func (s *TaskService) ImportTasks(actor entities.Actor, ownerID int64, tasks []entities.Task) ([]int64, error) {
+	ids, err := s.Repo.AddTasks(ownerID, tasks, actor)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range ids {
+		s.publishTask(entities.EventCreated, ownerID, strconv.FormatInt(id, 10))
+	}
+	return ids, nil
+}
+
+// UpdateTask changes the task if the caller may edit it. A non-empty version
+// must match the task's current one.
+func (s *TaskService) UpdateTask(actor entities.Actor, id, version string, patch entities.TaskPatch) error {
+	_, ownerID, err := s.GetTask(actor.UserID, id, entities.PermissionEditor)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.Repo.UpdateTask(ownerID, id, patch, version, actor); err != nil {
+		return changeError(err)
+	}
+
+	s.publishTask(entities.EventUpdated, ownerID, id)
+	return nil
+}
+
+func (s *TaskService) DeleteTask(actor entities.Actor, id, version string) error {
+	task, ownerID, err := s.GetTask(actor.UserID, id, entities.PermissionEditor)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.Repo.DeleteTask(ownerID, id, version, actor); err != nil {
+		return changeError(err)
+	}
+
+	s.publishRemoved(entities.EventDeleted, ownerID, task)
+	return nil
+}
+
+// CompleteTask marks the task as done: one-off tasks are deleted and
+// repeating ones move to their next date.
+func (s *TaskService) CompleteTask(actor entities.Actor, id, version string) error {
+	task, ownerID, err := s.GetTask(actor.UserID, id, entities.PermissionEditor)
+	if err != nil {
+		return err
+	}
+
+	if task.Repeat == "" {
+		if _, err := s.Repo.CompleteTask(ownerID, id, version, actor); err != nil {
+			return changeError(err)
+		}
+		s.publishRemoved(entities.EventCompleted, ownerID, task)
+		return nil
+	}
+
+	parsedDate, err := time.Parse(Format, task.Date)
+	if err != nil {
+		return utils.NewError("invalid_format", "date")
+	}
+
+	now := time.Now()
+	if parsedDate.Format(Format) == now.Format(Format) {
+		now = parsedDate.AddDate(0, 0, -1)
+	}
+	nextDate, err := s.NextDate(now, task.Date, task.Repeat)
+	if err != nil {
+		return err
+	}
+
+	if err := s.Repo.MarkTaskAsDone(ownerID, id, nextDate, version, actor); err != nil {
+		return changeError(err)
+	}
+
+	s.publishTask(entities.EventCompleted, ownerID, id)
+	return nil
 }
 
-func NewTaskService(repo *storage.SQLiteTaskRepository) *TaskService {
-	return &TaskService{Repo: repo}
+// changeError maps the storage errors of a task change to the ones reported
+// to clients. No event is published for a change that failed.
+func changeError(err error) error {
+	switch {
+	case errors.Is(err, storage.ErrVersionConflict):
+		return ErrVersionConflict
+	case errors.Is(err, storage.ErrTaskNotFound):
+		return ErrTaskNotFound
+	}
+	return err
 }
 
 func (s *TaskService) NextDate(now time.Time, date string, repeat string) (string, error) {
 	parsedDate, err := time.Parse(Format, date)
 	if err != nil {
-		return "", errors.New("недопустимый формат date")
+		return "", utils.NewError("invalid_format", "date")
 	}
 
 	repeatType, repeatRule := parseRepeatRule(repeat)
@@ -37,10 +208,65 @@ func (s *TaskService) NextDate(now time.Time, date string, repeat string) (strin
 	case "m":
 		return calculateMonthlyRepeat(now, parsedDate, repeatRule)
 	default:
-		return "", errors.New("недопустимый символ")
+		return "", utils.NewError("invalid_repeat")
 	}
 }
 
+func (s *TaskService) ValidateNewTask(task *entities.Task) error {
+	if task.Title == "" {
+		return utils.NewError("required", "title")
+	}
+
+	if !IsValidPriority(task.Priority) {
+		return utils.NewError("invalid_value", "priority")
+	}
+
+	if err := s.validateAndUpdateDate(task); err != nil {
+		return err
+	}
+
+	if task.Repeat != "" {
+		if _, err := s.NextDate(time.Now(), task.Date, task.Repeat); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func IsValidPriority(priority string) bool {
+	return priority == "" || (len(priority) == 1 && priority[0] >= 'A' && priority[0] <= 'Z')
+}
+
+func (s *TaskService) validateAndUpdateDate(task *entities.Task) error {
+	var dateInTime time.Time
+	var err error
+
+	if task.Date != "" {
+		dateInTime, err = time.Parse(Format, task.Date)
+		if err != nil {
+			return utils.NewError("invalid_format", "date")
+		}
+	} else {
+		task.Date = time.Now().Format(Format)
+		dateInTime = time.Now()
+	}
+
+	if time.Now().After(dateInTime) {
+		if task.Repeat == "" {
+			task.Date = time.Now().Format(Format)
+			dateInTime = time.Now()
+		} else {
+			task.Date, err = s.NextDate(time.Now(), task.Date, task.Repeat)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
 func parseRepeatRule(repeat string) (string, string) {
 	repeatParts := strings.SplitN(repeat, " ", 2)
 	repeatType := ""
@@ -58,16 +284,16 @@ func parseRepeatRule(repeat string) (string, string) {
 
 func calculateDailyRepeat(now, parsedDate time.Time, repeatRule string) (string, error) {
 	if repeatRule == "" {
-		return "", errors.New("не указан интервал в днях")
+		return "", utils.NewError("invalid_repeat/no_interval")
 	}
 
 	numberOfDays, err := strconv.Atoi(repeatRule)
 	if err != nil {
-		return "", errors.New("некорректно указано правило repeat")
+		return "", utils.NewError("invalid_repeat/rule")
 	}
 
 	if numberOfDays > 400 {
-		return "", errors.New("превышен максимально допустимый интервал")
+		return "", utils.NewError("invalid_repeat/interval")
 	}
 
 	if now.Format(Format) != parsedDate.Format(Format) {
@@ -125,10 +351,10 @@ func parseDaysOfWeek(repeatRule string) (map[int]bool, error) {
 	for _, value := range substrings {
 		number, err := strconv.Atoi(value)
 		if err != nil {
-			return nil, errors.New("ошибка конвертации значения дня недели")
+			return nil, utils.NewError("invalid_repeat/weekday")
 		}
 		if number < 1 || number > 7 {
-			return nil, errors.New("недопустимое значение дня недели")
+			return nil, utils.NewError("invalid_repeat/weekday_range")
 		}
 		if number == 7 {
 			number = 0
@@ -210,10 +436,10 @@ func parseDays(daysPart string) (map[int]bool, error) {
 	for _, dayStr := range days {
 		day, err := strconv.Atoi(dayStr)
 		if err != nil {
-			return nil, errors.New("ошибка конвертации значения дня месяца")
+			return nil, utils.NewError("invalid_repeat/monthday")
 		}
 		if day < -2 || day > 31 || day == 0 {
-			return nil, errors.New("недопустимое значение дня месяца")
+			return nil, utils.NewError("invalid_repeat/monthday_range")
 		}
 		dayMap[day] = true
 	}
@@ -228,10 +454,10 @@ func parseMonths(monthsPart string) (map[int]bool, error) {
 		if monthStr != "" {
 			month, err := strconv.Atoi(monthStr)
 			if err != nil {
-				return nil, errors.New("ошибка конвертации значения месяца")
+				return nil, utils.NewError("invalid_repeat/month")
 			}
 			if month < 1 || month > 12 {
-				return nil, errors.New("недопустимое значение месяца")
+				return nil, utils.NewError("invalid_repeat/month_range")
 			}
 			monthMap[month] = true
 		}
@@ -0,0 +1,288 @@
+package service
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/antonkazachenko/go-todo-list-api/internal/entities"
+	"github.com/antonkazachenko/go-todo-list-api/models"
+	"github.com/antonkazachenko/go-todo-list-api/utils"
+)
+
+const todoTxtDateFormat = "2006-01-02"
+
+var (
+	todoTxtPriority = regexp.MustCompile(`^\([A-Z]\)$`)
+	todoTxtRecur    = regexp.MustCompile(`^\+?(\d+)([dwmy])$`)
+)
+
+type TodoTxtItem struct {
+	Completed   bool
+	Priority    string
+	Completion  string
+	Creation    string
+	Description string
+	Tags        map[string]string
+}
+
+func ParseTodoTxtLine(line string) (TodoTxtItem, error) {
+	item := TodoTxtItem{Tags: make(map[string]string)}
+	fields := strings.Fields(line)
+
+	if len(fields) > 0 && fields[0] == "x" {
+		item.Completed = true
+		fields = fields[1:]
+	}
+
+	if len(fields) > 0 && todoTxtPriority.MatchString(fields[0]) {
+		item.Priority = fields[0][1:2]
+		fields = fields[1:]
+	}
+
+	var dates []string
+	for len(fields) > 0 && len(dates) < 2 {
+		if _, err := time.Parse(todoTxtDateFormat, fields[0]); err != nil {
+			break
+		}
+		dates = append(dates, fields[0])
+		fields = fields[1:]
+	}
+
+	switch {
+	case len(dates) == 2 && item.Completed:
+		item.Completion, item.Creation = dates[0], dates[1]
+	case len(dates) == 2:
+		item.Creation = dates[0]
+		fields = append([]string{dates[1]}, fields...)
+	case len(dates) == 1 && item.Completed:
+		item.Completion = dates[0]
+	case len(dates) == 1:
+		item.Creation = dates[0]
+	}
+
+	var words []string
+	for _, field := range fields {
+		key, value, found := strings.Cut(field, ":")
+		if found && isTodoTxtTag(key) && value != "" && !strings.Contains(value, ":") {
+			item.Tags[key] = value
+			continue
+		}
+		words = append(words, field)
+	}
+	item.Description = strings.Join(words, " ")
+
+	if item.Description == "" {
+		return item, utils.NewError("required/title")
+	}
+
+	return item, nil
+}
+
+func (item TodoTxtItem) String() string {
+	var parts []string
+	if item.Completed {
+		parts = append(parts, "x")
+	}
+	if item.Priority != "" {
+		parts = append(parts, "("+item.Priority+")")
+	}
+	if item.Completed && item.Completion != "" {
+		parts = append(parts, item.Completion)
+	}
+	if item.Creation != "" {
+		parts = append(parts, item.Creation)
+	}
+	parts = append(parts, item.Description)
+
+	for _, key := range []string{"due", "rec", "comment"} {
+		if value, ok := item.Tags[key]; ok {
+			parts = append(parts, key+":"+value)
+		}
+	}
+
+	return strings.Join(parts, " ")
+}
+
+func isTodoTxtTag(key string) bool {
+	return key == "due" || key == "rec" || key == "comment"
+}
+
+func TaskToTodoTxt(task entities.Task) TodoTxtItem {
+	item := TodoTxtItem{
+		Priority:    task.Priority,
+		Creation:    formatTodoTxtDate(task.Created),
+		Description: task.Title,
+		Tags:        make(map[string]string),
+	}
+
+	if task.Date != "" {
+		item.Tags["due"] = formatTodoTxtDate(task.Date)
+	}
+	if task.Repeat != "" {
+		item.Tags["rec"] = formatTodoTxtRecur(task.Repeat)
+	}
+	if task.Comment != "" {
+		item.Tags["comment"] = url.PathEscape(task.Comment)
+	}
+
+	return item
+}
+
+func TodoTxtToTask(item TodoTxtItem) (entities.Task, error) {
+	task := entities.Task{
+		Title:    item.Description,
+		Priority: item.Priority,
+	}
+
+	if item.Creation != "" {
+		task.Created = parseTodoTxtDate(item.Creation)
+	}
+
+	if due, ok := item.Tags["due"]; ok {
+		date, err := time.Parse(todoTxtDateFormat, due)
+		if err != nil {
+			return task, utils.NewError("invalid_format", "due")
+		}
+		task.Date = date.Format(Format)
+	}
+
+	if rec, ok := item.Tags["rec"]; ok {
+		repeat, err := parseTodoTxtRecur(rec, task.Date)
+		if err != nil {
+			return task, err
+		}
+		task.Repeat = repeat
+	}
+
+	if comment, ok := item.Tags["comment"]; ok {
+		value, err := url.PathUnescape(comment)
+		if err != nil {
+			return task, utils.NewError("invalid_value", "comment")
+		}
+		task.Comment = value
+	}
+
+	return task, nil
+}
+
+func (s *TaskService) ParseTodoTxt(r io.Reader) ([]entities.Task, models.ImportResponse, error) {
+	var tasks []entities.Task
+	report := models.ImportResponse{Errors: []models.ImportRowError{}}
+
+	scanner := bufio.NewScanner(r)
+	row := 0
+	for scanner.Scan() {
+		row++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		report.Total++
+		item, err := ParseTodoTxtLine(line)
+		if err != nil {
+			report.Errors = append(report.Errors, models.ImportRowError{Row: row, Error: err.Error(), Err: err})
+			continue
+		}
+		if item.Completed {
+			report.Skipped++
+			continue
+		}
+
+		task, err := TodoTxtToTask(item)
+		if err == nil {
+			err = s.ValidateNewTask(&task)
+		}
+		if err != nil {
+			report.Errors = append(report.Errors, models.ImportRowError{Row: row, Error: err.Error(), Err: err})
+			continue
+		}
+		tasks = append(tasks, task)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, report, utils.NewError("invalid_body")
+	}
+
+	return tasks, report, nil
+}
+
+func (s *TaskService) WriteTodoTxt(ownerID int64, w io.Writer) error {
+	tasks, err := s.Repo.GetAllTasks(ownerID)
+	if err != nil {
+		return err
+	}
+
+	for _, task := range tasks {
+		if _, err := fmt.Fprintln(w, TaskToTodoTxt(task).String()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func formatTodoTxtDate(date string) string {
+	parsed, err := time.Parse(Format, date)
+	if err != nil {
+		return ""
+	}
+	return parsed.Format(todoTxtDateFormat)
+}
+
+func parseTodoTxtDate(date string) string {
+	parsed, err := time.Parse(todoTxtDateFormat, date)
+	if err != nil {
+		return ""
+	}
+	return parsed.Format(Format)
+}
+
+func formatTodoTxtRecur(repeat string) string {
+	repeatType, repeatRule := parseRepeatRule(repeat)
+	switch {
+	case repeatType == "y" && repeatRule == "":
+		return "1y"
+	case repeatType == "d" && !strings.Contains(repeatRule, " "):
+		if _, err := strconv.Atoi(repeatRule); err == nil {
+			return repeatRule + "d"
+		}
+	}
+	return strings.ReplaceAll(repeat, " ", "_")
+}
+
+func parseTodoTxtRecur(rec, date string) (string, error) {
+	matches := todoTxtRecur.FindStringSubmatch(rec)
+	if matches == nil {
+		return strings.ReplaceAll(rec, "_", " "), nil
+	}
+
+	count, err := strconv.Atoi(matches[1])
+	if err != nil || count < 1 {
+		return "", utils.NewError("invalid_rec")
+	}
+
+	switch matches[2] {
+	case "d":
+		return "d " + strconv.Itoa(count), nil
+	case "w":
+		return "d " + strconv.Itoa(count*7), nil
+	case "m":
+		parsed, err := time.Parse(Format, date)
+		if count != 1 || err != nil {
+			return "", utils.NewError("invalid_rec/monthly")
+		}
+		return "m " + strconv.Itoa(parsed.Day()), nil
+	default:
+		if count != 1 {
+			return "", utils.NewError("invalid_rec/yearly")
+		}
+		return "y", nil
+	}
+}
@@ -0,0 +1,295 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/antonkazachenko/go-todo-list-api/internal/entities"
+	storage "github.com/antonkazachenko/go-todo-list-api/internal/storage/sqlite"
+	"github.com/antonkazachenko/go-todo-list-api/utils"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+var (
+	ErrInvalidToken   = utils.NewError("invalid_token")
+	ErrRefreshReused  = utils.NewError("refresh_token_reused")
+	ErrSessionRevoked = utils.NewError("session_revoked")
+)
+
+const (
+	mfaPurpose  = "mfa"
+	mfaTokenTTL = 5 * time.Minute
+)
+
+type AccessClaims struct {
+	jwt.RegisteredClaims
+	SessionID string `json:"sid,omitempty"`
+	Purpose   string `json:"pur,omitempty"`
+}
+
+func (c *AccessClaims) UserID() (int64, error) {
+	return strconv.ParseInt(c.Subject, 10, 64)
+}
+
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    time.Duration
+}
+
+type TokenService struct {
+	Repo       *storage.SQLiteSessionRepository
+	Keys       *Keyring
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+}
+
+func NewTokenService(repo *storage.SQLiteSessionRepository, keys *Keyring, accessTTL, refreshTTL time.Duration) *TokenService {
+	return &TokenService{Repo: repo, Keys: keys, AccessTTL: accessTTL, RefreshTTL: refreshTTL}
+}
+
+func (s *TokenService) IssueTokens(user *entities.User, userAgent, ip string) (*TokenPair, error) {
+	now := time.Now()
+	if err := s.Repo.DeleteExpired(now); err != nil {
+		return nil, err
+	}
+
+	sessionID, err := randomToken(16)
+	if err != nil {
+		return nil, err
+	}
+	refreshSecret, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	session := entities.Session{
+		ID:          sessionID,
+		UserID:      user.ID,
+		RefreshHash: hashToken(refreshSecret),
+		UserAgent:   truncate(userAgent, 255),
+		IP:          ip,
+		Created:     now,
+		LastUsed:    now,
+		Expires:     now.Add(s.RefreshTTL),
+	}
+	if err := s.Repo.AddSession(session); err != nil {
+		return nil, err
+	}
+
+	return s.newPair(user.ID, sessionID, refreshSecret, now)
+}
+
+func (s *TokenService) Refresh(refreshToken, userAgent, ip string) (*TokenPair, error) {
+	sessionID, refreshSecret, ok := strings.Cut(refreshToken, ".")
+	if !ok || sessionID == "" || refreshSecret == "" {
+		return nil, ErrInvalidToken
+	}
+
+	session, err := s.Repo.GetSession(sessionID)
+	if errors.Is(err, storage.ErrSessionNotFound) {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if session.Revoked || !now.Before(session.Expires) {
+		return nil, ErrSessionRevoked
+	}
+
+	presented := hashToken(refreshSecret)
+	if session.PrevHash != "" && subtle.ConstantTimeCompare([]byte(presented), []byte(session.PrevHash)) == 1 {
+		if _, err := s.Repo.RevokeSession(session.UserID, session.ID); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshReused
+	}
+	if subtle.ConstantTimeCompare([]byte(presented), []byte(session.RefreshHash)) != 1 {
+		return nil, ErrInvalidToken
+	}
+
+	newSecret, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	session.PrevHash = session.RefreshHash
+	session.RefreshHash = hashToken(newSecret)
+	session.UserAgent = truncate(userAgent, 255)
+	session.IP = ip
+	session.LastUsed = now
+	session.Expires = now.Add(s.RefreshTTL)
+
+	rotated, err := s.Repo.RotateSession(*session)
+	if err != nil {
+		return nil, err
+	}
+	if rotated == 0 {
+		return nil, ErrInvalidToken
+	}
+
+	return s.newPair(session.UserID, session.ID, newSecret, now)
+}
+
+func (s *TokenService) ParseAccessToken(tokenString string) (*AccessClaims, error) {
+	claims := &AccessClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, s.Keys.Keyfunc)
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidToken
+	}
+
+	if claims.ExpiresAt == nil || claims.ID == "" || claims.Subject == "" || claims.SessionID == "" || claims.Purpose != "" {
+		return nil, ErrInvalidToken
+	}
+
+	if err := s.checkRevoked(claims); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// Active reports whether the token of the claims is still accepted: it was
+// not signed out and its session was not revoked. Connections that outlive a
+// request use it to notice revocations.
+func (s *TokenService) Active(claims *AccessClaims) bool {
+	return s.checkRevoked(claims) == nil
+}
+
+func (s *TokenService) checkRevoked(claims *AccessClaims) error {
+	revoked, err := s.Repo.IsTokenRevoked(claims.ID)
+	if err != nil {
+		return err
+	}
+	if revoked {
+		return ErrInvalidToken
+	}
+
+	session, err := s.Repo.GetSession(claims.SessionID)
+	if err != nil || session.Revoked || strconv.FormatInt(session.UserID, 10) != claims.Subject {
+		return ErrSessionRevoked
+	}
+	return nil
+}
+
+// IssueMFAToken returns a short-lived token that proves the password step of
+// sign-in. It is not accepted as an access token.
+func (s *TokenService) IssueMFAToken(user *entities.User) (string, error) {
+	jti, err := randomToken(16)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	return s.Keys.Sign(AccessClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Subject:   strconv.FormatInt(user.ID, 10),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(mfaTokenTTL)),
+		},
+		Purpose: mfaPurpose,
+	})
+}
+
+func (s *TokenService) ParseMFAToken(tokenString string) (int64, error) {
+	claims := &AccessClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, s.Keys.Keyfunc)
+	if err != nil || !token.Valid || claims.ExpiresAt == nil || claims.Purpose != mfaPurpose {
+		return 0, ErrInvalidToken
+	}
+
+	userID, err := claims.UserID()
+	if err != nil {
+		return 0, ErrInvalidToken
+	}
+	return userID, nil
+}
+
+func (s *TokenService) SignOut(claims *AccessClaims) error {
+	if err := s.Repo.RevokeToken(claims.ID, claims.ExpiresAt.Time); err != nil {
+		return err
+	}
+
+	if claims.SessionID == "" {
+		return nil
+	}
+
+	userID, err := claims.UserID()
+	if err != nil {
+		return err
+	}
+
+	_, err = s.Repo.RevokeSession(userID, claims.SessionID)
+	return err
+}
+
+func (s *TokenService) Sessions(userID int64, currentSessionID string) ([]entities.Session, error) {
+	sessions, err := s.Repo.GetSessionsByUser(userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentSessionID
+	}
+
+	return sessions, nil
+}
+
+func (s *TokenService) newPair(userID int64, sessionID, refreshSecret string, now time.Time) (*TokenPair, error) {
+	jti, err := randomToken(16)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := AccessClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Subject:   strconv.FormatInt(userID, 10),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.AccessTTL)),
+		},
+		SessionID: sessionID,
+	}
+
+	accessToken, err := s.Keys.Sign(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: sessionID + "." + refreshSecret,
+		ExpiresIn:    s.AccessTTL,
+	}, nil
+}
+
+func randomToken(size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func truncate(value string, limit int) string {
+	if len(value) > limit {
+		return value[:limit]
+	}
+	return value
+}
@@ -0,0 +1,228 @@
+package service
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/antonkazachenko/go-todo-list-api/internal/entities"
+	"github.com/antonkazachenko/go-todo-list-api/utils"
+)
+
+const (
+	TOTPIssuer = "go-todo-list-api"
+
+	totpDigits        = 6
+	totpPeriod        = 30
+	totpSkew          = 1
+	recoveryCodeCount = 10
+)
+
+var (
+	ErrInvalidCode        = utils.NewError("invalid_code")
+	ErrTOTPNotEnrolled    = utils.NewError("totp_not_enrolled")
+	ErrTOTPAlreadyEnabled = utils.NewError("totp_already_enabled")
+
+	base32NoPadding = base32.StdEncoding.WithPadding(base32.NoPadding)
+)
+
+func TOTPURI(login, secret string) string {
+	label := url.PathEscape(TOTPIssuer + ":" + login)
+	query := url.Values{
+		"secret":    {secret},
+		"issuer":    {TOTPIssuer},
+		"algorithm": {"SHA1"},
+		"digits":    {fmt.Sprint(totpDigits)},
+		"period":    {fmt.Sprint(totpPeriod)},
+	}
+	return "otpauth://totp/" + label + "?" + query.Encode()
+}
+
+func (s *UserService) EnrollTOTP(user *entities.User) (string, string, error) {
+	if user.TOTPEnabled {
+		return "", "", ErrTOTPAlreadyEnabled
+	}
+
+	key := make([]byte, 20)
+	if _, err := rand.Read(key); err != nil {
+		return "", "", err
+	}
+	secret := base32NoPadding.EncodeToString(key)
+
+	if err := s.Repo.SetTOTP(user.ID, secret, false); err != nil {
+		return "", "", err
+	}
+
+	return secret, TOTPURI(user.Login, secret), nil
+}
+
+func (s *UserService) ConfirmTOTP(user *entities.User, code string) ([]string, error) {
+	if user.TOTPEnabled {
+		return nil, ErrTOTPAlreadyEnabled
+	}
+	if user.TOTPSecret == "" {
+		return nil, ErrTOTPNotEnrolled
+	}
+
+	if err := s.verifyTOTP(user, code); err != nil {
+		return nil, err
+	}
+
+	if err := s.Repo.SetTOTP(user.ID, user.TOTPSecret, true); err != nil {
+		return nil, err
+	}
+
+	return s.newRecoveryCodes(user.ID)
+}
+
+func (s *UserService) DisableTOTP(user *entities.User, code, ip string) error {
+	if !user.TOTPEnabled {
+		return ErrTOTPNotEnrolled
+	}
+
+	if err := s.VerifySecondFactor(user, code, ip); err != nil {
+		return err
+	}
+
+	if err := s.Repo.SetTOTP(user.ID, "", false); err != nil {
+		return err
+	}
+
+	return s.Repo.ReplaceRecoveryCodes(user.ID, nil)
+}
+
+func (s *UserService) RegenerateRecoveryCodes(user *entities.User, code, ip string) ([]string, error) {
+	if !user.TOTPEnabled {
+		return nil, ErrTOTPNotEnrolled
+	}
+
+	if err := s.VerifySecondFactor(user, code, ip); err != nil {
+		return nil, err
+	}
+
+	return s.newRecoveryCodes(user.ID)
+}
+
+// VerifySecondFactor accepts either a current TOTP code or an unused recovery
+// code. Failures count towards the sign-in limiter.
+func (s *UserService) VerifySecondFactor(user *entities.User, code, ip string) error {
+	if wait := s.Limiter.Check(user.Login, ip); wait > 0 {
+		return &RetryAfterError{RetryAfter: wait}
+	}
+
+	err := s.verifyTOTP(user, code)
+	if errors.Is(err, ErrInvalidCode) {
+		err = s.useRecoveryCode(user, code)
+	}
+	if errors.Is(err, ErrInvalidCode) {
+		s.Limiter.Fail(user.Login, ip)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	s.Limiter.Succeed(user.Login)
+	return nil
+}
+
+func (s *UserService) verifyTOTP(user *entities.User, code string) error {
+	code = strings.ReplaceAll(code, " ", "")
+	if len(code) != totpDigits {
+		return ErrInvalidCode
+	}
+
+	current := time.Now().Unix() / totpPeriod
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		expected, err := totpCodeAt(user.TOTPSecret, step)
+		if err != nil {
+			return err
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) != 1 {
+			continue
+		}
+
+		used, err := s.Repo.UseTOTPStep(user.ID, step)
+		if err != nil {
+			return err
+		}
+		if used == 0 {
+			return ErrInvalidCode
+		}
+		return nil
+	}
+
+	return ErrInvalidCode
+}
+
+func (s *UserService) useRecoveryCode(user *entities.User, code string) error {
+	normalized := normalizeRecoveryCode(code)
+	if normalized == "" {
+		return ErrInvalidCode
+	}
+
+	used, err := s.Repo.UseRecoveryCode(user.ID, hashToken(normalized))
+	if err != nil {
+		return err
+	}
+	if used == 0 {
+		return ErrInvalidCode
+	}
+	return nil
+}
+
+func (s *UserService) newRecoveryCodes(userID int64) ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		raw := make([]byte, 7)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, err
+		}
+		code := strings.ToLower(base32NoPadding.EncodeToString(raw))[:10]
+		codes[i] = code[:5] + "-" + code[5:]
+		hashes[i] = hashToken(code)
+	}
+
+	if err := s.Repo.ReplaceRecoveryCodes(userID, hashes); err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	if len(code) != 10 {
+		return ""
+	}
+	return code
+}
+
+// totpCodeAt computes the RFC 6238 code (HMAC-SHA1, 6 digits) for a 30 s
+// time step.
+func totpCodeAt(secret string, step int64) (string, error) {
+	key, err := base32NoPadding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil {
+		return "", err
+	}
+
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000), nil
+}
@@ -0,0 +1,322 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/antonkazachenko/go-todo-list-api/internal/entities"
+	storage "github.com/antonkazachenko/go-todo-list-api/internal/storage/sqlite"
+	"github.com/antonkazachenko/go-todo-list-api/utils"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	DefaultAdminLogin = "admin"
+
+	// externalPasswordHash is never a valid bcrypt hash, so accounts created
+	// through single sign-on cannot sign in with a password.
+	externalPasswordHash = "!"
+)
+
+var (
+	ErrInvalidCredentials = utils.NewError("invalid_credentials")
+	ErrLoginTaken         = utils.NewError("login_taken")
+	ErrLastAdmin          = utils.NewError("last_admin")
+
+	loginPattern = regexp.MustCompile(`^[a-zA-Z0-9._@-]{3,64}$`)
+
+	dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("dummy-password"), bcrypt.DefaultCost)
+)
+
+type UserService struct {
+	Repo              *storage.SQLiteUserRepository
+	AdminPasswordHash string
+	Limiter           *LoginLimiter
+	ExternalAuth      bool
+}
+
+func NewUserService(repo *storage.SQLiteUserRepository, adminPasswordHash string, limiter *LoginLimiter) *UserService {
+	return &UserService{Repo: repo, AdminPasswordHash: adminPasswordHash, Limiter: limiter}
+}
+
+func AdminPasswordHash(hash, password string) (string, error) {
+	if hash != "" {
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			return "", fmt.Errorf("invalid password hash: %w", err)
+		}
+		return hash, nil
+	}
+
+	if password == "" {
+		return "", nil
+	}
+
+	return HashPassword(password)
+}
+
+func (s *UserService) AuthEnabled() bool {
+	return s.AdminPasswordHash != "" || s.ExternalAuth
+}
+
+func (s *UserService) EnsureAdmin() (*entities.User, error) {
+	admin, err := s.Repo.GetFirstAdmin()
+	if errors.Is(err, storage.ErrUserNotFound) {
+		admin = &entities.User{
+			Login:   DefaultAdminLogin,
+			Role:    entities.RoleAdmin,
+			Created: time.Now().Format(Format),
+		}
+		admin.ID, err = s.Repo.AddUser(*admin)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.Repo.AssignOrphanTasks(admin.ID); err != nil {
+		return nil, err
+	}
+
+	return admin, nil
+}
+
+func (s *UserService) Register(login, password, role string) (*entities.User, error) {
+	login = strings.TrimSpace(login)
+	if err := validateLogin(login); err != nil {
+		return nil, err
+	}
+	if err := validatePassword(password); err != nil {
+		return nil, err
+	}
+	if role == "" {
+		role = entities.RoleUser
+	}
+	if !isValidRole(role) {
+		return nil, utils.NewError("invalid_value", "role")
+	}
+
+	if _, err := s.Repo.GetUserByLogin(login); err == nil {
+		return nil, ErrLoginTaken
+	} else if !errors.Is(err, storage.ErrUserNotFound) {
+		return nil, err
+	}
+
+	hash, err := HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &entities.User{
+		Login:        login,
+		PasswordHash: hash,
+		Role:         role,
+		Created:      time.Now().Format(Format),
+	}
+	user.ID, err = s.Repo.AddUser(*user)
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (s *UserService) Authenticate(login, password string) (*entities.User, error) {
+	var user *entities.User
+	var err error
+	if login == "" {
+		user, err = s.Repo.GetFirstAdmin()
+	} else {
+		user, err = s.Repo.GetUserByLogin(login)
+	}
+	if errors.Is(err, storage.ErrUserNotFound) {
+		_ = bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !s.checkPassword(user, password) {
+		return nil, ErrInvalidCredentials
+	}
+
+	return user, nil
+}
+
+// SignIn is Authenticate behind the login limiter. An empty login is counted
+// against the account it resolves to. For accounts with TOTP the counter is
+// only reset once the second factor passes.
+func (s *UserService) SignIn(login, password, ip string) (*entities.User, error) {
+	if login == "" {
+		if admin, err := s.Repo.GetFirstAdmin(); err == nil {
+			login = admin.Login
+		}
+	}
+
+	if wait := s.Limiter.Check(login, ip); wait > 0 {
+		return nil, &RetryAfterError{RetryAfter: wait}
+	}
+
+	user, err := s.Authenticate(login, password)
+	if errors.Is(err, ErrInvalidCredentials) {
+		s.Limiter.Fail(login, ip)
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !user.TOTPEnabled {
+		s.Limiter.Succeed(login)
+	}
+	return user, nil
+}
+
+// ExternalUser returns the account linked to an external identity, creating
+// it on first use. Such accounts have no local password.
+func (s *UserService) ExternalUser(issuer, subject, login string, admin bool) (*entities.User, error) {
+	user, err := s.Repo.GetUserByIdentity(issuer, subject)
+	if err == nil || !errors.Is(err, storage.ErrUserNotFound) {
+		return user, err
+	}
+
+	role := entities.RoleUser
+	if admin {
+		role = entities.RoleAdmin
+	}
+
+	candidate := login
+	for i := 2; ; i++ {
+		if _, err := s.Repo.GetUserByLogin(candidate); errors.Is(err, storage.ErrUserNotFound) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if i > 100 {
+			return nil, ErrLoginTaken
+		}
+		candidate = fmt.Sprintf("%s-%d", login, i)
+	}
+
+	user = &entities.User{
+		Login:        candidate,
+		PasswordHash: externalPasswordHash,
+		Role:         role,
+		Created:      time.Now().Format(Format),
+	}
+	user.ID, err = s.Repo.AddUserWithIdentity(*user, issuer, subject)
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (s *UserService) UpdateUser(id int64, role, password string) (*entities.User, error) {
+	user, err := s.Repo.GetUserByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if role != "" && role != user.Role {
+		if !isValidRole(role) {
+			return nil, utils.NewError("invalid_value", "role")
+		}
+		if user.Role == entities.RoleAdmin {
+			if err := s.ensureAnotherAdmin(); err != nil {
+				return nil, err
+			}
+		}
+		user.Role = role
+	}
+
+	if password != "" {
+		if err := validatePassword(password); err != nil {
+			return nil, err
+		}
+		user.PasswordHash, err = HashPassword(password)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := s.Repo.UpdateUser(*user); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+func (s *UserService) DeleteUser(id int64) error {
+	user, err := s.Repo.GetUserByID(id)
+	if err != nil {
+		return err
+	}
+
+	if user.Role == entities.RoleAdmin {
+		if err := s.ensureAnotherAdmin(); err != nil {
+			return err
+		}
+	}
+
+	_, err = s.Repo.DeleteUser(id)
+	return err
+}
+
+func (s *UserService) ensureAnotherAdmin() error {
+	count, err := s.Repo.CountAdmins()
+	if err != nil {
+		return err
+	}
+	if count <= 1 {
+		return ErrLastAdmin
+	}
+	return nil
+}
+
+func (s *UserService) checkPassword(user *entities.User, password string) bool {
+	hash := user.PasswordHash
+	if hash == "" {
+		hash = s.AdminPasswordHash
+	}
+	if hash == "" {
+		return false
+	}
+
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
+func RandomSecret() (string, error) {
+	return randomToken(32)
+}
+
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+func validateLogin(login string) error {
+	if !loginPattern.MatchString(login) {
+		return utils.NewError("invalid_login")
+	}
+	return nil
+}
+
+func validatePassword(password string) error {
+	if len(password) < 8 {
+		return utils.NewError("password_too_short")
+	}
+	if len(password) > 72 {
+		return utils.NewError("password_too_long")
+	}
+	return nil
+}
+
+func isValidRole(role string) bool {
+	return role == entities.RoleUser || role == entities.RoleAdmin
+}
@@ -0,0 +1,594 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/antonkazachenko/go-todo-list-api/internal/entities"
+	storage "github.com/antonkazachenko/go-todo-list-api/internal/storage/sqlite"
+	"github.com/antonkazachenko/go-todo-list-api/utils"
+)
+
+const (
+	MaxWebhooks = 20
+
+	webhookDeliveryLimit = 100
+	webhookTimeout       = 10 * time.Second
+	webhookMaxDelay      = time.Hour
+	webhookPollInterval  = time.Second
+	webhookOverdueScan   = time.Minute
+	webhookWorkers       = 8
+	webhookBatch         = 10
+	webhookRetention     = 30 * 24 * time.Hour
+)
+
+var (
+	ErrWebhookNotFound  = utils.NewError("webhook_not_found")
+	ErrDeliveryNotFound = utils.NewError("delivery_not_found")
+	ErrWebhookURL       = utils.NewError("invalid_value", "url")
+	ErrWebhookEvents    = utils.NewError("invalid_value", "events")
+	ErrTooManyWebhooks  = utils.NewError("too_many_webhooks", MaxWebhooks)
+
+	errWebhookAddress = errors.New("webhook address is not allowed")
+)
+
+// WebhookService keeps the user's webhooks and delivers task events to them
+// from background workers, one per webhook at a time. Every request is signed
+// with the webhook secret: X-Webhook-Signature is sha256= and the hex
+// HMAC-SHA256 of the timestamp from X-Webhook-Timestamp, a dot and the body.
+// Unless AllowPrivate is set, webhooks cannot reach loopback, private,
+// link-local or unspecified addresses.
+type WebhookService struct {
+	Repo         *storage.SQLiteWebhookRepository
+	Tasks        *TaskService
+	Client       *http.Client
+	MaxAttempts  int
+	RetryDelay   time.Duration
+	AllowPrivate bool
+
+	wake chan struct{}
+	mu   sync.Mutex
+	busy map[int64]bool
+}
+
+func NewWebhookService(repo *storage.SQLiteWebhookRepository, tasks *TaskService, maxAttempts int, retryDelay time.Duration) *WebhookService {
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	s := &WebhookService{Repo: repo, Tasks: tasks, MaxAttempts: maxAttempts, RetryDelay: retryDelay, wake: make(chan struct{}, 1), busy: map[int64]bool{}}
+
+	// The address is checked after it is resolved, when the connection is
+	// made, so that a name cannot point somewhere else later. Proxies are
+	// not used, since the check would only see the proxy.
+	dialer := &net.Dialer{Timeout: webhookTimeout, Control: s.checkAddress}
+	s.Client = &http.Client{
+		Timeout:   webhookTimeout,
+		Transport: &http.Transport{DialContext: dialer.DialContext, TLSHandshakeTimeout: webhookTimeout, MaxIdleConnsPerHost: 1},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	return s
+}
+
+// Create registers a webhook and returns it with its signing secret, which
+// is only shown here.
+func (s *WebhookService) Create(userID int64, rawURL string, events []string) (*entities.Webhook, error) {
+	rawURL, events, err := s.validateWebhook(rawURL, events)
+	if err != nil {
+		return nil, err
+	}
+
+	hooks, err := s.Repo.GetWebhooksByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(hooks) >= MaxWebhooks {
+		return nil, ErrTooManyWebhooks
+	}
+
+	secret, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	hook := &entities.Webhook{
+		UserID:  userID,
+		URL:     rawURL,
+		Events:  events,
+		Secret:  secret,
+		Active:  true,
+		Created: time.Now().UTC().Truncate(time.Second),
+	}
+	hook.ID, err = s.Repo.AddWebhook(*hook)
+	if err != nil {
+		return nil, err
+	}
+
+	s.checkOverdue(*hook)
+	return hook, nil
+}
+
+// Update changes the webhook's URL, events and state. A nil field is left
+// unchanged; rotate replaces the secret.
+func (s *WebhookService) Update(userID, id int64, rawURL *string, events []string, active *bool, rotate bool) (*entities.Webhook, error) {
+	hook, err := s.Get(userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if rawURL != nil {
+		hook.URL = *rawURL
+	}
+	if events != nil {
+		hook.Events = events
+	}
+	hook.URL, hook.Events, err = s.validateWebhook(hook.URL, hook.Events)
+	if err != nil {
+		return nil, err
+	}
+	if active != nil {
+		hook.Active = *active
+	}
+	if rotate {
+		if hook.Secret, err = randomToken(32); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := s.Repo.UpdateWebhook(*hook); err != nil {
+		return nil, err
+	}
+
+	if hook.Active {
+		s.checkOverdue(*hook)
+	}
+	return hook, nil
+}
+
+func (s *WebhookService) Get(userID, id int64) (*entities.Webhook, error) {
+	hook, err := s.Repo.GetWebhook(userID, id)
+	if errors.Is(err, storage.ErrWebhookNotFound) {
+		return nil, ErrWebhookNotFound
+	}
+	return hook, err
+}
+
+func (s *WebhookService) Delete(userID, id int64) error {
+	deleted, err := s.Repo.DeleteWebhook(userID, id)
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return ErrWebhookNotFound
+	}
+	return nil
+}
+
+// Deliveries returns the latest deliveries of the user's webhook.
+func (s *WebhookService) Deliveries(userID, id int64) ([]entities.WebhookDelivery, error) {
+	if _, err := s.Get(userID, id); err != nil {
+		return nil, err
+	}
+	return s.Repo.GetDeliveries(id, webhookDeliveryLimit)
+}
+
+// Redeliver queues the payload of a past delivery again as a new delivery.
+func (s *WebhookService) Redeliver(userID, deliveryID int64) (*entities.WebhookDelivery, error) {
+	past, err := s.Repo.GetDelivery(userID, deliveryID)
+	if errors.Is(err, storage.ErrDeliveryNotFound) {
+		return nil, ErrDeliveryNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	delivery := &entities.WebhookDelivery{
+		WebhookID:   past.WebhookID,
+		Event:       past.Event,
+		TaskID:      past.TaskID,
+		Payload:     past.Payload,
+		Status:      entities.DeliveryPending,
+		Created:     now,
+		NextAttempt: &now,
+	}
+	if delivery.ID, err = s.Repo.AddDelivery(*delivery); err != nil {
+		return nil, err
+	}
+
+	s.notify()
+	return delivery, nil
+}
+
+// Run queues task events for the webhooks subscribed to them, looks for
+// overdue tasks and delivers the queue until ctx is done.
+func (s *WebhookService) Run(ctx context.Context) {
+	go s.deliverLoop(ctx)
+
+	overdue := time.NewTicker(webhookOverdueScan)
+	defer overdue.Stop()
+
+	var lastID int64
+	for {
+		var events <-chan entities.TaskEvent
+		cancel := func() {}
+		if s.Tasks.Events != nil {
+			var replay []entities.TaskEvent
+			replay, events, _, cancel = s.Tasks.Events.Subscribe(lastID)
+			for _, event := range replay {
+				s.queue(event)
+				lastID = event.ID
+			}
+		}
+
+	listen:
+		for {
+			select {
+			case <-ctx.Done():
+				cancel()
+				return
+			case <-overdue.C:
+				s.scanOverdue()
+				s.prune()
+			case event, ok := <-events:
+				if !ok {
+					break listen
+				}
+				s.queue(event)
+				lastID = event.ID
+			}
+		}
+		cancel()
+	}
+}
+
+// queue adds a delivery of the event to every active webhook that wants it
+// and whose owner can see the task.
+func (s *WebhookService) queue(event entities.TaskEvent) {
+	hooks, err := s.Repo.GetActiveWebhooks()
+	if err != nil {
+		log.Printf("Failed to load webhooks: %v", err)
+		return
+	}
+
+	for _, hook := range hooks {
+		if hook.Subscribed(event.Type) && s.Tasks.CanSee(hook.UserID, event) {
+			s.enqueue(hook, event, "")
+		}
+	}
+}
+
+// scanOverdue reports the tasks that became overdue to the webhooks
+// subscribed to it.
+func (s *WebhookService) scanOverdue() {
+	hooks, err := s.Repo.GetActiveWebhooks()
+	if err != nil {
+		log.Printf("Failed to load webhooks: %v", err)
+		return
+	}
+	for _, hook := range hooks {
+		s.checkOverdue(hook)
+	}
+}
+
+// checkOverdue queues an overdue event for each task in the lists the
+// webhook owner can see whose date is in the past. A task is reported once
+// per date, so a repeating task that is moved and missed again is reported
+// again.
+func (s *WebhookService) checkOverdue(hook entities.Webhook) {
+	if !hook.Active || !hook.Subscribed(entities.EventOverdue) {
+		return
+	}
+
+	lists := []entities.Share{{OwnerID: hook.UserID}}
+	shares, err := s.Tasks.Shares.GetSharesByUser(hook.UserID)
+	if err != nil {
+		log.Printf("Failed to load shares of user %d: %v", hook.UserID, err)
+		return
+	}
+	for _, share := range shares {
+		if share.UserID == hook.UserID {
+			lists = append(lists, share)
+		}
+	}
+
+	yesterday := time.Now().AddDate(0, 0, -1).Format(Format)
+	for _, list := range lists {
+		tasks, err := s.Tasks.Repo.FindTasks(list.OwnerID, list.Filter, entities.TaskFilter{To: yesterday})
+		if err != nil {
+			log.Printf("Failed to look for overdue tasks: %v", err)
+			return
+		}
+		for i := range tasks {
+			event := entities.TaskEvent{
+				Type:    entities.EventOverdue,
+				OwnerID: list.OwnerID,
+				TaskID:  tasks[i].ID,
+				Task:    &tasks[i],
+				Time:    time.Now().UTC(),
+			}
+			s.enqueue(hook, event, "overdue:"+tasks[i].ID+":"+tasks[i].Date)
+		}
+	}
+}
+
+func (s *WebhookService) enqueue(hook entities.Webhook, event entities.TaskEvent, key string) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Failed to encode webhook payload: %v", err)
+		return
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	delivery := entities.WebhookDelivery{
+		WebhookID:   hook.ID,
+		Event:       event.Type,
+		TaskID:      event.TaskID,
+		Key:         key,
+		Payload:     payload,
+		Status:      entities.DeliveryPending,
+		Created:     now,
+		NextAttempt: &now,
+	}
+	if _, err := s.Repo.AddDelivery(delivery); err != nil {
+		log.Printf("Failed to queue webhook delivery: %v", err)
+		return
+	}
+	s.notify()
+}
+
+func (s *WebhookService) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// deliverLoop hands the webhooks with due deliveries to workers. Each webhook
+// is served by one worker at a time, in order, so a slow receiver only delays
+// its own deliveries.
+func (s *WebhookService) deliverLoop(ctx context.Context) {
+	ticker := time.NewTicker(webhookPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		}
+
+		hooks, err := s.Repo.DueWebhooks(time.Now())
+		if err != nil {
+			log.Printf("Failed to load webhook deliveries: %v", err)
+			continue
+		}
+		for _, hookID := range hooks {
+			if s.claim(hookID) {
+				go s.work(ctx, hookID)
+			}
+		}
+	}
+}
+
+// claim marks the webhook as served by a worker, unless it already is or
+// all workers are busy.
+func (s *WebhookService) claim(hookID int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.busy[hookID] || len(s.busy) >= webhookWorkers {
+		return false
+	}
+	s.busy[hookID] = true
+	return true
+}
+
+func (s *WebhookService) work(ctx context.Context, hookID int64) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.busy, hookID)
+		s.mu.Unlock()
+		s.notify()
+	}()
+
+	for ctx.Err() == nil {
+		hook, err := s.Repo.GetWebhookByID(hookID)
+		if errors.Is(err, storage.ErrWebhookNotFound) {
+			// A delivery queued while the webhook was being deleted.
+			if err := s.Repo.DeleteDeliveries(hookID); err != nil {
+				log.Printf("Failed to delete webhook deliveries: %v", err)
+			}
+			return
+		}
+		if err != nil {
+			log.Printf("Failed to load webhook %d: %v", hookID, err)
+			return
+		}
+
+		deliveries, err := s.Repo.DueDeliveries(hookID, time.Now(), webhookBatch)
+		if err != nil {
+			log.Printf("Failed to load webhook deliveries: %v", err)
+			return
+		}
+		if len(deliveries) == 0 {
+			return
+		}
+		for _, delivery := range deliveries {
+			if ctx.Err() != nil {
+				return
+			}
+			if err := s.deliver(ctx, *hook, delivery); err != nil {
+				log.Printf("Failed to update webhook delivery %d: %v", delivery.ID, err)
+				return
+			}
+		}
+	}
+}
+
+func (s *WebhookService) prune() {
+	if _, err := s.Repo.PruneDeliveries(time.Now().Add(-webhookRetention)); err != nil {
+		log.Printf("Failed to prune webhook deliveries: %v", err)
+	}
+}
+
+// deliver makes one attempt to send the delivery. A failed attempt is
+// retried with exponential backoff until MaxAttempts is reached.
+func (s *WebhookService) deliver(ctx context.Context, hook entities.Webhook, delivery entities.WebhookDelivery) error {
+	var err error
+	delivery.Attempts++
+	delivery.ResponseCode, err = s.send(ctx, hook, delivery)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	switch {
+	case err == nil:
+		delivery.Status = entities.DeliveryDelivered
+		delivery.Error = ""
+		delivery.NextAttempt = nil
+		delivery.Delivered = &now
+	case !hook.Active || delivery.Attempts >= s.MaxAttempts:
+		delivery.Status = entities.DeliveryFailed
+		delivery.Error = err.Error()
+		delivery.NextAttempt = nil
+	default:
+		next := now.Add(s.retryDelay(delivery.Attempts))
+		delivery.Error = err.Error()
+		delivery.NextAttempt = &next
+	}
+
+	return s.Repo.UpdateDelivery(delivery)
+}
+
+func (s *WebhookService) send(ctx context.Context, hook entities.Webhook, delivery entities.WebhookDelivery) (int, error) {
+	if !hook.Active {
+		return 0, errors.New("webhook is disabled")
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "go-todo-list-api-webhook")
+	req.Header.Set("X-Webhook-Event", delivery.Event)
+	req.Header.Set("X-Webhook-Delivery", strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", "sha256="+SignWebhook(hook.Secret, timestamp, delivery.Payload))
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected response status %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+func (s *WebhookService) retryDelay(attempts int) time.Duration {
+	delay := s.RetryDelay
+	for i := 1; i < attempts && delay < webhookMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > webhookMaxDelay {
+		delay = webhookMaxDelay
+	}
+	return delay
+}
+
+// SignWebhook returns the hex HMAC-SHA256 of the timestamp and body.
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *WebhookService) validateWebhook(rawURL string, events []string) (string, []string, error) {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" || len(rawURL) > 2048 {
+		return "", nil, ErrWebhookURL
+	}
+	if !s.AllowPrivate && privateHost(parsed.Hostname()) {
+		return "", nil, ErrWebhookURL
+	}
+
+	if len(events) == 0 {
+		return "", nil, ErrWebhookEvents
+	}
+	seen := map[string]bool{}
+	var valid []string
+	for _, event := range events {
+		if seen[event] {
+			continue
+		}
+		if !isWebhookEvent(event) {
+			return "", nil, ErrWebhookEvents
+		}
+		seen[event] = true
+		valid = append(valid, event)
+	}
+	return rawURL, valid, nil
+}
+
+// checkAddress is the dialer's Control hook; address is already resolved.
+func (s *WebhookService) checkAddress(network, address string, _ syscall.RawConn) error {
+	if s.AllowPrivate {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || privateIP(ip) {
+		return errWebhookAddress
+	}
+	return nil
+}
+
+// privateHost rejects names and literal addresses that are known to be
+// local before anything is resolved.
+func privateHost(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && privateIP(ip)
+}
+
+func privateIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified()
+}
+
+func isWebhookEvent(event string) bool {
+	for _, known := range entities.WebhookEvents {
+		if event == known {
+			return true
+		}
+	}
+	return false
+}
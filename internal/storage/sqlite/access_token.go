@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/antonkazachenko/go-todo-list-api/internal/entities"
+)
+
+var ErrAccessTokenNotFound = errors.New("access token not found")
+
+const accessTokenColumns = "id, user_id, name, token_hash, scopes, created, last_used, expires"
+
+type SQLiteAccessTokenRepository struct {
+	DB *sql.DB
+}
+
+func NewSQLiteAccessTokenRepository(db *sql.DB) *SQLiteAccessTokenRepository {
+	return &SQLiteAccessTokenRepository{DB: db}
+}
+
+func (r *SQLiteAccessTokenRepository) AddAccessToken(token entities.AccessToken) (int64, error) {
+	result, err := r.DB.Exec("INSERT INTO access_tokens (user_id, name, token_hash, scopes, created, last_used, expires) VALUES (?, ?, ?, ?, ?, 0, ?)",
+		token.UserID, token.Name, token.TokenHash, strings.Join(token.Scopes, " "), token.Created.Unix(), unixOrZero(token.Expires))
+	if err != nil {
+		return 0, err
+	}
+
+	return result.LastInsertId()
+}
+
+func (r *SQLiteAccessTokenRepository) GetAccessTokenByHash(hash string) (*entities.AccessToken, error) {
+	return scanAccessToken(r.DB.QueryRow("SELECT "+accessTokenColumns+" FROM access_tokens WHERE token_hash = ?", hash))
+}
+
+func (r *SQLiteAccessTokenRepository) GetAccessTokensByUser(userID int64) ([]entities.AccessToken, error) {
+	rows, err := r.DB.Query("SELECT "+accessTokenColumns+" FROM access_tokens WHERE user_id = ? ORDER BY id", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tokens []entities.AccessToken
+	for rows.Next() {
+		token, err := scanAccessToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, *token)
+	}
+
+	return tokens, rows.Err()
+}
+
+func (r *SQLiteAccessTokenRepository) DeleteAccessToken(userID, id int64) (int64, error) {
+	result, err := r.DB.Exec("DELETE FROM access_tokens WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// TouchAccessToken records a use at most once a minute to keep writes off the
+// hot path.
+func (r *SQLiteAccessTokenRepository) TouchAccessToken(id int64, now time.Time) error {
+	_, err := r.DB.Exec("UPDATE access_tokens SET last_used = ? WHERE id = ? AND last_used < ?",
+		now.Unix(), id, now.Add(-time.Minute).Unix())
+	return err
+}
+
+// DeleteStaleAccessTokens removes expired tokens and tokens that have not been
+// used (or, if never used, created) since the given time.
+func (r *SQLiteAccessTokenRepository) DeleteStaleAccessTokens(now, unusedSince time.Time) (int64, error) {
+	result, err := r.DB.Exec("DELETE FROM access_tokens WHERE (expires > 0 AND expires <= ?) OR MAX(last_used, created) < ?",
+		now.Unix(), unusedSince.Unix())
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+func scanAccessToken(row rowScanner) (*entities.AccessToken, error) {
+	var token entities.AccessToken
+	var scopes string
+	var created, lastUsed, expires int64
+	err := row.Scan(&token.ID, &token.UserID, &token.Name, &token.TokenHash, &scopes, &created, &lastUsed, &expires)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrAccessTokenNotFound
+		}
+		return nil, err
+	}
+
+	token.Scopes = strings.Fields(scopes)
+	token.Created = time.Unix(created, 0).UTC()
+	token.LastUsed = timeOrNil(lastUsed)
+	token.Expires = timeOrNil(expires)
+	return &token, nil
+}
+
+func unixOrZero(t *time.Time) int64 {
+	if t == nil {
+		return 0
+	}
+	return t.Unix()
+}
+
+func timeOrNil(unix int64) *time.Time {
+	if unix == 0 {
+		return nil
+	}
+	t := time.Unix(unix, 0).UTC()
+	return &t
+}
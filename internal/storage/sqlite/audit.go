@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/antonkazachenko/go-todo-list-api/internal/entities"
+)
+
+const auditQuery = `SELECT a.id, a.created, a.user_id, COALESCE(u.login, ''), a.owner_id, a.task_id, a.action, a.changes, a.ip, a.token_id
+	FROM audit_log a LEFT JOIN users u ON u.id = a.user_id WHERE 1 = 1`
+
+type SQLiteAuditRepository struct {
+	DB *sql.DB
+}
+
+func NewSQLiteAuditRepository(db *sql.DB) *SQLiteAuditRepository {
+	return &SQLiteAuditRepository{DB: db}
+}
+
+func (r *SQLiteAuditRepository) GetAuditEntries(filter entities.AuditFilter) ([]entities.AuditEntry, error) {
+	query := auditQuery
+	var args []interface{}
+
+	if filter.VisibleTo != 0 {
+		query += " AND (a.owner_id = ? OR a.user_id = ?)"
+		args = append(args, filter.VisibleTo, filter.VisibleTo)
+	}
+	if filter.UserID != 0 {
+		query += " AND a.user_id = ?"
+		args = append(args, filter.UserID)
+	}
+	if filter.OwnerID != 0 {
+		query += " AND a.owner_id = ?"
+		args = append(args, filter.OwnerID)
+	}
+	if filter.TaskID != "" {
+		query += " AND a.task_id = ?"
+		args = append(args, filter.TaskID)
+	}
+	if filter.Action != "" {
+		query += " AND a.action = ?"
+		args = append(args, filter.Action)
+	}
+	if !filter.From.IsZero() {
+		query += " AND a.created >= ?"
+		args = append(args, filter.From.Unix())
+	}
+	if !filter.To.IsZero() {
+		query += " AND a.created < ?"
+		args = append(args, filter.To.Unix())
+	}
+	if filter.BeforeID != 0 {
+		query += " AND a.id < ?"
+		args = append(args, filter.BeforeID)
+	}
+
+	query += " ORDER BY a.id DESC LIMIT ?"
+	args = append(args, filter.Limit)
+
+	rows, err := r.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []entities.AuditEntry
+	for rows.Next() {
+		var entry entities.AuditEntry
+		var created int64
+		var changes string
+		err := rows.Scan(&entry.ID, &created, &entry.UserID, &entry.UserLogin, &entry.OwnerID, &entry.TaskID,
+			&entry.Action, &changes, &entry.IP, &entry.TokenID)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(changes), &entry.Changes); err != nil {
+			return nil, err
+		}
+		entry.Time = time.Unix(created, 0).UTC()
+		entries = append(entries, entry)
+	}
+
+	return entries, rows.Err()
+}
+
+// addAuditEntry records a task change inside the transaction that makes it,
+// so the log cannot miss a committed change or contain a rolled back one.
+func addAuditEntry(tx *sql.Tx, actor entities.Actor, ownerID int64, taskID interface{}, action string, before, after *entities.Task) error {
+	changes, err := json.Marshal(taskChanges(before, after))
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("INSERT INTO audit_log (created, user_id, owner_id, task_id, action, changes, ip, token_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		time.Now().Unix(), actor.UserID, ownerID, taskID, action, string(changes), actor.IP, actor.TokenID)
+	return err
+}
+
+func taskChanges(before, after *entities.Task) map[string]entities.FieldChange {
+	fields := func(task *entities.Task) map[string]string {
+		if task == nil {
+			return map[string]string{}
+		}
+		return map[string]string{
+			"date":     task.Date,
+			"title":    task.Title,
+			"comment":  task.Comment,
+			"repeat":   task.Repeat,
+			"priority": task.Priority,
+		}
+	}
+
+	changes := map[string]entities.FieldChange{}
+	old, updated := fields(before), fields(after)
+	for _, name := range []string{"date", "title", "comment", "repeat", "priority"} {
+		oldValue, hadOld := old[name]
+		newValue, hasNew := updated[name]
+		if hadOld && hasNew && oldValue == newValue {
+			continue
+		}
+
+		var change entities.FieldChange
+		if hadOld && (oldValue != "" || hasNew) {
+			change.Before = &oldValue
+		}
+		if hasNew && (newValue != "" || hadOld) {
+			change.After = &newValue
+		}
+		if change.Before != nil || change.After != nil {
+			changes[name] = change
+		}
+	}
+	return changes
+}
@@ -0,0 +1,280 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/antonkazachenko/go-todo-list-api/internal/entities"
+)
+
+type SQLiteBackupRepository struct {
+	DB *sql.DB
+}
+
+func NewSQLiteBackupRepository(db *sql.DB) *SQLiteBackupRepository {
+	return &SQLiteBackupRepository{DB: db}
+}
+
+// backupOrder lists the tables in the order Restore fills them: the ones
+// other tables refer to first. scheduler comes before sync_changes, so that
+// the rows its triggers write are replaced by the ones in the backup.
+var backupOrder = []string{
+	"users",
+	"recovery_codes",
+	"identities",
+	"sessions",
+	"revoked_tokens",
+	"access_tokens",
+	"scheduler",
+	"sync_changes",
+	"sync_refs",
+	"audit_log",
+	"shares",
+	"share_links",
+	"webhooks",
+	"webhook_deliveries",
+}
+
+// Dump reads every table in one transaction, so the backup is a consistent
+// snapshot even while tasks are being written.
+func (r *SQLiteBackupRepository) Dump() (map[string]entities.BackupTable, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	tables, err := listTables(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	dump := make(map[string]entities.BackupTable, len(tables))
+	for _, table := range tables {
+		data, err := dumpTable(tx, table)
+		if err != nil {
+			return nil, err
+		}
+		dump[table] = data
+	}
+
+	return dump, tx.Commit()
+}
+
+// Restore replaces the contents of every table by the backup's. A table the
+// backup lacks is emptied, unless legacy is set: a backup of an older
+// version lacks the tables added since, which are then kept, and its tasks
+// without an owner go to the first admin.
+func (r *SQLiteBackupRepository) Restore(tables map[string]entities.BackupTable, legacy bool) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("PRAGMA defer_foreign_keys = ON"); err != nil {
+		return err
+	}
+
+	existing, err := listTables(tx)
+	if err != nil {
+		return err
+	}
+
+	for _, table := range restoreOrder(existing) {
+		data, ok := tables[table]
+		if !ok && legacy {
+			continue
+		}
+
+		if _, err := tx.Exec(fmt.Sprintf("DELETE FROM %s", quoteIdent(table))); err != nil {
+			return err
+		}
+
+		if len(data.Rows) == 0 {
+			continue
+		}
+
+		columns := make([]string, len(data.Columns))
+		placeholders := make([]string, len(data.Columns))
+		for i, column := range data.Columns {
+			columns[i] = quoteIdent(column)
+			placeholders[i] = "?"
+		}
+
+		stmt, err := tx.Prepare(fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+			quoteIdent(table), strings.Join(columns, ", "), strings.Join(placeholders, ", ")))
+		if err != nil {
+			return err
+		}
+
+		for _, row := range data.Rows {
+			if len(row) != len(data.Columns) {
+				stmt.Close()
+				return fmt.Errorf("table %s: row has %d values, expected %d", table, len(row), len(data.Columns))
+			}
+			if _, err := stmt.Exec(restoreValues(row)...); err != nil {
+				stmt.Close()
+				return err
+			}
+		}
+		stmt.Close()
+	}
+
+	if legacy {
+		_, err := tx.Exec("UPDATE scheduler SET owner_id = (SELECT id FROM users WHERE role = ? ORDER BY id LIMIT 1) WHERE owner_id = 0 AND EXISTS (SELECT 1 FROM users WHERE role = ?)",
+			entities.RoleAdmin, entities.RoleAdmin)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// restoreOrder sorts the tables by backupOrder. Tables it does not list go
+// last, by name.
+func restoreOrder(tables []string) []string {
+	rank := make(map[string]int, len(backupOrder))
+	for i, table := range backupOrder {
+		rank[table] = i
+	}
+
+	ordered := append([]string(nil), tables...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		ri, iok := rank[ordered[i]]
+		rj, jok := rank[ordered[j]]
+		if iok != jok {
+			return iok
+		}
+		if !iok {
+			return ordered[i] < ordered[j]
+		}
+		return ri < rj
+	})
+	return ordered
+}
+
+func (r *SQLiteBackupRepository) TableColumns() (map[string][]string, error) {
+	tables, err := listTables(r.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string][]string, len(tables))
+	for _, table := range tables {
+		columns, err := tableColumns(r.DB, table)
+		if err != nil {
+			return nil, err
+		}
+		result[table] = columns
+	}
+
+	return result, nil
+}
+
+func listTables(db queryer) ([]string, error) {
+	rows, err := db.Query("SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		tables = append(tables, name)
+	}
+
+	return tables, rows.Err()
+}
+
+func tableColumns(db queryer, table string) ([]string, error) {
+	rows, err := db.Query(fmt.Sprintf("SELECT name FROM pragma_table_info(%s) ORDER BY cid", quoteLiteral(table)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		columns = append(columns, name)
+	}
+
+	return columns, rows.Err()
+}
+
+func dumpTable(db queryer, table string) (entities.BackupTable, error) {
+	columns, err := tableColumns(db, table)
+	if err != nil {
+		return entities.BackupTable{}, err
+	}
+
+	quoted := make([]string, len(columns))
+	for i, column := range columns {
+		quoted[i] = quoteIdent(column)
+	}
+
+	rows, err := db.Query(fmt.Sprintf("SELECT %s FROM %s ORDER BY rowid", strings.Join(quoted, ", "), quoteIdent(table)))
+	if err != nil {
+		return entities.BackupTable{}, err
+	}
+	defer rows.Close()
+
+	data := entities.BackupTable{Columns: columns, Rows: [][]interface{}{}}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return entities.BackupTable{}, err
+		}
+		for i, value := range values {
+			if b, ok := value.([]byte); ok {
+				values[i] = string(b)
+			}
+		}
+		data.Rows = append(data.Rows, values)
+	}
+
+	return data, rows.Err()
+}
+
+func restoreValues(row []interface{}) []interface{} {
+	values := make([]interface{}, len(row))
+	for i, value := range row {
+		number, ok := value.(json.Number)
+		if !ok {
+			values[i] = value
+			continue
+		}
+		if n, err := number.Int64(); err == nil {
+			values[i] = n
+		} else if f, err := number.Float64(); err == nil {
+			values[i] = f
+		} else {
+			values[i] = number.String()
+		}
+	}
+	return values
+}
+
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func quoteLiteral(value string) string {
+	return `'` + strings.ReplaceAll(value, `'`, `''`) + `'`
+}
@@ -2,14 +2,60 @@ package storage
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
 	"log"
+	"regexp"
+	"strings"
 
 	"github.com/antonkazachenko/go-todo-list-api/config"
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/mattn/go-sqlite3"
 )
 
+const (
+	ConstraintTooLong   = "too_long"
+	ConstraintNotUnique = "not_unique"
+	ConstraintOther     = "constraint"
+)
+
+var (
+	lengthCheckColumn = regexp.MustCompile(`CHECK constraint failed: .*LENGTH\((\w+)\)`)
+	constraintColumn  = regexp.MustCompile(`constraint failed: \w+\.(\w+)`)
+)
+
+// Constraint reports which column constraint rejected a write and how.
+// It returns ok=false for any other error, or when the column is unknown.
+func Constraint(err error) (kind, column string, ok bool) {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) || sqliteErr.Code != sqlite3.ErrConstraint {
+		return "", "", false
+	}
+
+	message := sqliteErr.Error()
+	if match := lengthCheckColumn.FindStringSubmatch(message); match != nil {
+		return ConstraintTooLong, match[1], true
+	}
+	if match := constraintColumn.FindStringSubmatch(message); match != nil {
+		if sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+			return ConstraintNotUnique, match[1], true
+		}
+		return ConstraintOther, match[1], true
+	}
+	return "", "", false
+}
+
 func InitDB() *sql.DB {
-	db, err := sql.Open("sqlite3", config.TODO_DBFILE)
+	// Transactions take the write lock when they begin. A deferred one that
+	// reads first gets SQLITE_BUSY instead of waiting if another connection,
+	// such as the webhook worker, writes at the same time.
+	dsn := config.TODO_DBFILE
+	if strings.Contains(dsn, "?") {
+		dsn += "&_txlock=immediate"
+	} else {
+		dsn += "?_txlock=immediate"
+	}
+
+	db, err := sql.Open("sqlite3", dsn)
 	if err != nil {
 		log.Fatalf("Failed to open database: %v", err)
 	}
@@ -25,10 +71,268 @@ func InitDB() *sql.DB {
 		log.Fatalf("Failed to create table: %v", err)
 	}
 
+	addColumnIfMissing(db, "scheduler", "priority", "TEXT NOT NULL DEFAULT '' CHECK(LENGTH(priority) <= 1)")
+	addColumnIfMissing(db, "scheduler", "created", "TEXT NOT NULL DEFAULT ''")
+	addColumnIfMissing(db, "scheduler", "owner_id", "INTEGER NOT NULL DEFAULT 0")
+	addColumnIfMissing(db, "scheduler", "version", "INTEGER NOT NULL DEFAULT 1")
+	addColumnIfMissing(db, "scheduler", "field_times", "TEXT NOT NULL DEFAULT '{}'")
+
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS sync_changes (
+		seq INTEGER PRIMARY KEY AUTOINCREMENT,
+		task_id INTEGER NOT NULL UNIQUE,
+		owner_id INTEGER NOT NULL,
+		deleted INTEGER NOT NULL DEFAULT 0
+	)`)
+	if err != nil {
+		log.Fatalf("Failed to create table: %v", err)
+	}
+
+	// sync_refs remembers the task each offline create made, so that a client
+	// retrying a sync whose answer it never got does not create it twice.
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS sync_refs (
+		owner_id INTEGER NOT NULL,
+		user_id INTEGER NOT NULL,
+		ref TEXT NOT NULL CHECK(LENGTH(ref) <= 255),
+		task_id INTEGER NOT NULL,
+		UNIQUE (owner_id, user_id, ref)
+	)`)
+	if err != nil {
+		log.Fatalf("Failed to create table: %v", err)
+	}
+
+	// The triggers give every task write, restores and imports included, a new
+	// sequence number in sync_changes; a deleted task keeps its row as a
+	// tombstone.
+	for _, trigger := range []string{
+		`CREATE TRIGGER IF NOT EXISTS scheduler_sync_insert AFTER INSERT ON scheduler BEGIN
+			INSERT OR REPLACE INTO sync_changes (task_id, owner_id, deleted) VALUES (NEW.id, NEW.owner_id, 0);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS scheduler_sync_update AFTER UPDATE ON scheduler BEGIN
+			INSERT OR REPLACE INTO sync_changes (task_id, owner_id, deleted) VALUES (NEW.id, NEW.owner_id, 0);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS scheduler_sync_delete AFTER DELETE ON scheduler BEGIN
+			INSERT OR REPLACE INTO sync_changes (task_id, owner_id, deleted) VALUES (OLD.id, OLD.owner_id, 1);
+		END`,
+	} {
+		if _, err := db.Exec(trigger); err != nil {
+			log.Fatalf("Failed to create trigger: %v", err)
+		}
+	}
+
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS users (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		login TEXT NOT NULL UNIQUE CHECK(LENGTH(login) <= 64),
+		password_hash TEXT NOT NULL,
+		role TEXT NOT NULL DEFAULT 'user',
+		created TEXT NOT NULL
+	)`)
+	if err != nil {
+		log.Fatalf("Failed to create table: %v", err)
+	}
+
+	addColumnIfMissing(db, "users", "totp_secret", "TEXT NOT NULL DEFAULT ''")
+	addColumnIfMissing(db, "users", "totp_enabled", "INTEGER NOT NULL DEFAULT 0")
+	addColumnIfMissing(db, "users", "totp_last_step", "INTEGER NOT NULL DEFAULT 0")
+
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS recovery_codes (
+		user_id INTEGER NOT NULL,
+		code_hash TEXT NOT NULL,
+		PRIMARY KEY (user_id, code_hash)
+	)`)
+	if err != nil {
+		log.Fatalf("Failed to create table: %v", err)
+	}
+
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS identities (
+		issuer TEXT NOT NULL,
+		subject TEXT NOT NULL,
+		user_id INTEGER NOT NULL,
+		created INTEGER NOT NULL,
+		PRIMARY KEY (issuer, subject)
+	)`)
+	if err != nil {
+		log.Fatalf("Failed to create table: %v", err)
+	}
+
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS shares (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		owner_id INTEGER NOT NULL,
+		user_id INTEGER NOT NULL,
+		role TEXT NOT NULL,
+		filter TEXT NOT NULL DEFAULT '' CHECK(LENGTH(filter) <= 255),
+		created INTEGER NOT NULL,
+		UNIQUE (owner_id, user_id)
+	)`)
+	if err != nil {
+		log.Fatalf("Failed to create table: %v", err)
+	}
+
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS share_links (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		owner_id INTEGER NOT NULL,
+		name VARCHAR(64) NOT NULL DEFAULT '',
+		token_hash TEXT NOT NULL UNIQUE,
+		filter TEXT NOT NULL DEFAULT '',
+		task_ids TEXT NOT NULL DEFAULT '',
+		password_hash TEXT NOT NULL DEFAULT '',
+		created INTEGER NOT NULL,
+		expires INTEGER NOT NULL DEFAULT 0
+	)`)
+	if err != nil {
+		log.Fatalf("Failed to create table: %v", err)
+	}
+
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS audit_log (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		created INTEGER NOT NULL,
+		user_id INTEGER NOT NULL,
+		owner_id INTEGER NOT NULL,
+		task_id INTEGER NOT NULL,
+		action TEXT NOT NULL,
+		changes TEXT NOT NULL DEFAULT '{}',
+		ip TEXT NOT NULL DEFAULT '',
+		token_id TEXT NOT NULL DEFAULT ''
+	)`)
+	if err != nil {
+		log.Fatalf("Failed to create table: %v", err)
+	}
+
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS sessions (
+		id TEXT PRIMARY KEY,
+		user_id INTEGER NOT NULL,
+		refresh_hash TEXT NOT NULL,
+		prev_hash TEXT NOT NULL DEFAULT '',
+		user_agent TEXT NOT NULL DEFAULT '',
+		ip TEXT NOT NULL DEFAULT '',
+		created INTEGER NOT NULL,
+		last_used INTEGER NOT NULL,
+		expires INTEGER NOT NULL,
+		revoked INTEGER NOT NULL DEFAULT 0
+	)`)
+	if err != nil {
+		log.Fatalf("Failed to create table: %v", err)
+	}
+
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS revoked_tokens (
+		jti TEXT PRIMARY KEY,
+		expires INTEGER NOT NULL
+	)`)
+	if err != nil {
+		log.Fatalf("Failed to create table: %v", err)
+	}
+
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS access_tokens (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		name VARCHAR(64) NOT NULL DEFAULT '',
+		token_hash TEXT NOT NULL UNIQUE,
+		scopes TEXT NOT NULL DEFAULT '',
+		created INTEGER NOT NULL,
+		last_used INTEGER NOT NULL DEFAULT 0,
+		expires INTEGER NOT NULL DEFAULT 0
+	)`)
+	if err != nil {
+		log.Fatalf("Failed to create table: %v", err)
+	}
+
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS webhooks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		url TEXT NOT NULL CHECK(LENGTH(url) <= 2048),
+		events TEXT NOT NULL,
+		secret TEXT NOT NULL,
+		active INTEGER NOT NULL DEFAULT 1,
+		created INTEGER NOT NULL
+	)`)
+	if err != nil {
+		log.Fatalf("Failed to create table: %v", err)
+	}
+
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS webhook_deliveries (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		webhook_id INTEGER NOT NULL,
+		event TEXT NOT NULL,
+		task_id INTEGER NOT NULL,
+		dedupe_key TEXT,
+		payload TEXT NOT NULL,
+		status TEXT NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		response_code INTEGER NOT NULL DEFAULT 0,
+		error TEXT NOT NULL DEFAULT '',
+		created INTEGER NOT NULL,
+		next_attempt INTEGER NOT NULL DEFAULT 0,
+		delivered INTEGER NOT NULL DEFAULT 0,
+		UNIQUE (webhook_id, dedupe_key)
+	)`)
+	if err != nil {
+		log.Fatalf("Failed to create table: %v", err)
+	}
+
 	_, err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_scheduler_date ON scheduler (date)`)
 	if err != nil {
 		log.Fatalf("Failed to create index: %v", err)
 	}
 
+	_, err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_scheduler_owner ON scheduler (owner_id, date)`)
+	if err != nil {
+		log.Fatalf("Failed to create index: %v", err)
+	}
+
+	_, err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_sync_changes_owner ON sync_changes (owner_id, seq)`)
+	if err != nil {
+		log.Fatalf("Failed to create index: %v", err)
+	}
+
+	_, err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_webhooks_user ON webhooks (user_id)`)
+	if err != nil {
+		log.Fatalf("Failed to create index: %v", err)
+	}
+
+	_, err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries (status, next_attempt)`)
+	if err != nil {
+		log.Fatalf("Failed to create index: %v", err)
+	}
+
+	_, err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_sessions_user ON sessions (user_id)`)
+	if err != nil {
+		log.Fatalf("Failed to create index: %v", err)
+	}
+
+	_, err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_access_tokens_user ON access_tokens (user_id)`)
+	if err != nil {
+		log.Fatalf("Failed to create index: %v", err)
+	}
+
+	_, err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_shares_user ON shares (user_id)`)
+	if err != nil {
+		log.Fatalf("Failed to create index: %v", err)
+	}
+
+	_, err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_audit_log_owner ON audit_log (owner_id, id)`)
+	if err != nil {
+		log.Fatalf("Failed to create index: %v", err)
+	}
+
+	_, err = db.Exec(`CREATE INDEX IF NOT EXISTS idx_audit_log_task ON audit_log (task_id)`)
+	if err != nil {
+		log.Fatalf("Failed to create index: %v", err)
+	}
+
 	return db
 }
+
+func addColumnIfMissing(db *sql.DB, table, column, definition string) {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?", table, column).Scan(&count)
+	if err != nil {
+		log.Fatalf("Failed to inspect table %s: %v", table, err)
+	}
+	if count > 0 {
+		return
+	}
+
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	if err != nil {
+		log.Fatalf("Failed to add column %s.%s: %v", table, column, err)
+	}
+}
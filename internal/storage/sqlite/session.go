@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/antonkazachenko/go-todo-list-api/internal/entities"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+const sessionColumns = "id, user_id, refresh_hash, prev_hash, user_agent, ip, created, last_used, expires, revoked"
+
+type SQLiteSessionRepository struct {
+	DB *sql.DB
+}
+
+func NewSQLiteSessionRepository(db *sql.DB) *SQLiteSessionRepository {
+	return &SQLiteSessionRepository{DB: db}
+}
+
+func (r *SQLiteSessionRepository) AddSession(session entities.Session) error {
+	_, err := r.DB.Exec("INSERT INTO sessions ("+sessionColumns+") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		session.ID, session.UserID, session.RefreshHash, session.PrevHash, session.UserAgent, session.IP,
+		session.Created.Unix(), session.LastUsed.Unix(), session.Expires.Unix(), session.Revoked)
+	return err
+}
+
+func (r *SQLiteSessionRepository) GetSession(id string) (*entities.Session, error) {
+	return scanSession(r.DB.QueryRow("SELECT "+sessionColumns+" FROM sessions WHERE id = ?", id))
+}
+
+func (r *SQLiteSessionRepository) GetSessionsByUser(userID int64, now time.Time) ([]entities.Session, error) {
+	rows, err := r.DB.Query("SELECT "+sessionColumns+" FROM sessions WHERE user_id = ? AND revoked = 0 AND expires > ? ORDER BY last_used DESC",
+		userID, now.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []entities.Session
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, *session)
+	}
+
+	return sessions, rows.Err()
+}
+
+func (r *SQLiteSessionRepository) RotateSession(session entities.Session) (int64, error) {
+	result, err := r.DB.Exec("UPDATE sessions SET refresh_hash = ?, prev_hash = ?, user_agent = ?, ip = ?, last_used = ?, expires = ? WHERE id = ? AND refresh_hash = ? AND revoked = 0",
+		session.RefreshHash, session.PrevHash, session.UserAgent, session.IP, session.LastUsed.Unix(), session.Expires.Unix(),
+		session.ID, session.PrevHash)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+func (r *SQLiteSessionRepository) RevokeSession(userID int64, id string) (int64, error) {
+	result, err := r.DB.Exec("UPDATE sessions SET revoked = 1 WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+func (r *SQLiteSessionRepository) RevokeUserSessions(userID int64) error {
+	_, err := r.DB.Exec("UPDATE sessions SET revoked = 1 WHERE user_id = ?", userID)
+	return err
+}
+
+func (r *SQLiteSessionRepository) RevokeToken(jti string, expires time.Time) error {
+	_, err := r.DB.Exec("INSERT OR IGNORE INTO revoked_tokens (jti, expires) VALUES (?, ?)", jti, expires.Unix())
+	return err
+}
+
+func (r *SQLiteSessionRepository) IsTokenRevoked(jti string) (bool, error) {
+	var count int
+	err := r.DB.QueryRow("SELECT COUNT(*) FROM revoked_tokens WHERE jti = ?", jti).Scan(&count)
+	return count > 0, err
+}
+
+func (r *SQLiteSessionRepository) DeleteExpired(now time.Time) error {
+	if _, err := r.DB.Exec("DELETE FROM revoked_tokens WHERE expires <= ?", now.Unix()); err != nil {
+		return err
+	}
+	_, err := r.DB.Exec("DELETE FROM sessions WHERE expires <= ?", now.Unix())
+	return err
+}
+
+func scanSession(row rowScanner) (*entities.Session, error) {
+	var session entities.Session
+	var created, lastUsed, expires int64
+	err := row.Scan(&session.ID, &session.UserID, &session.RefreshHash, &session.PrevHash, &session.UserAgent, &session.IP,
+		&created, &lastUsed, &expires, &session.Revoked)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrSessionNotFound
+		}
+		return nil, err
+	}
+
+	session.Created = time.Unix(created, 0).UTC()
+	session.LastUsed = time.Unix(lastUsed, 0).UTC()
+	session.Expires = time.Unix(expires, 0).UTC()
+	return &session, nil
+}
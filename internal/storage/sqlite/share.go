@@ -0,0 +1,102 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/antonkazachenko/go-todo-list-api/internal/entities"
+)
+
+var ErrShareNotFound = errors.New("share not found")
+
+const shareQuery = `SELECT s.id, s.owner_id, o.login, s.user_id, u.login, s.role, s.filter, s.created
+	FROM shares s JOIN users o ON o.id = s.owner_id JOIN users u ON u.id = s.user_id`
+
+type SQLiteShareRepository struct {
+	DB *sql.DB
+}
+
+func NewSQLiteShareRepository(db *sql.DB) *SQLiteShareRepository {
+	return &SQLiteShareRepository{DB: db}
+}
+
+func (r *SQLiteShareRepository) AddShare(share entities.Share) (int64, error) {
+	result, err := r.DB.Exec("INSERT INTO shares (owner_id, user_id, role, filter, created) VALUES (?, ?, ?, ?, ?)",
+		share.OwnerID, share.UserID, share.Role, share.Filter, share.Created.Unix())
+	if err != nil {
+		return 0, err
+	}
+
+	return result.LastInsertId()
+}
+
+func (r *SQLiteShareRepository) GetShare(ownerID, userID int64) (*entities.Share, error) {
+	return scanShare(r.DB.QueryRow(shareQuery+" WHERE s.owner_id = ? AND s.user_id = ?", ownerID, userID))
+}
+
+func (r *SQLiteShareRepository) GetShareByID(id int64) (*entities.Share, error) {
+	return scanShare(r.DB.QueryRow(shareQuery+" WHERE s.id = ?", id))
+}
+
+// GetSharesByUser returns the shares of the user's own list and the shares
+// other owners granted to the user.
+func (r *SQLiteShareRepository) GetSharesByUser(userID int64) ([]entities.Share, error) {
+	return r.queryShares(shareQuery+" WHERE s.owner_id = ? OR s.user_id = ? ORDER BY s.id", userID, userID)
+}
+
+func (r *SQLiteShareRepository) GetSharesByOwner(ownerID int64) ([]entities.Share, error) {
+	return r.queryShares(shareQuery+" WHERE s.owner_id = ? ORDER BY s.id", ownerID)
+}
+
+func (r *SQLiteShareRepository) UpdateShare(id int64, role, filter string) (int64, error) {
+	result, err := r.DB.Exec("UPDATE shares SET role = ?, filter = ? WHERE id = ?", role, filter, id)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+func (r *SQLiteShareRepository) DeleteShare(id int64) (int64, error) {
+	result, err := r.DB.Exec("DELETE FROM shares WHERE id = ?", id)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+func (r *SQLiteShareRepository) queryShares(query string, args ...interface{}) ([]entities.Share, error) {
+	rows, err := r.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var shares []entities.Share
+	for rows.Next() {
+		share, err := scanShare(rows)
+		if err != nil {
+			return nil, err
+		}
+		shares = append(shares, *share)
+	}
+
+	return shares, rows.Err()
+}
+
+func scanShare(row rowScanner) (*entities.Share, error) {
+	var share entities.Share
+	var created int64
+	err := row.Scan(&share.ID, &share.OwnerID, &share.OwnerLogin, &share.UserID, &share.UserLogin, &share.Role, &share.Filter, &created)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrShareNotFound
+		}
+		return nil, err
+	}
+
+	share.Created = time.Unix(created, 0).UTC()
+	return &share, nil
+}
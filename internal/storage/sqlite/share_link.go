@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/antonkazachenko/go-todo-list-api/internal/entities"
+)
+
+var ErrShareLinkNotFound = errors.New("share link not found")
+
+const shareLinkColumns = "id, owner_id, name, token_hash, filter, task_ids, password_hash, created, expires"
+
+type SQLiteShareLinkRepository struct {
+	DB *sql.DB
+}
+
+func NewSQLiteShareLinkRepository(db *sql.DB) *SQLiteShareLinkRepository {
+	return &SQLiteShareLinkRepository{DB: db}
+}
+
+func (r *SQLiteShareLinkRepository) AddShareLink(link entities.ShareLink) (int64, error) {
+	result, err := r.DB.Exec("INSERT INTO share_links (owner_id, name, token_hash, filter, task_ids, password_hash, created, expires) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		link.OwnerID, link.Name, link.TokenHash, link.Filter, strings.Join(link.TaskIDs, " "), link.PasswordHash, link.Created.Unix(), unixOrZero(link.Expires))
+	if err != nil {
+		return 0, err
+	}
+
+	return result.LastInsertId()
+}
+
+func (r *SQLiteShareLinkRepository) GetShareLinkByHash(hash string) (*entities.ShareLink, error) {
+	return scanShareLink(r.DB.QueryRow("SELECT "+shareLinkColumns+" FROM share_links WHERE token_hash = ?", hash))
+}
+
+func (r *SQLiteShareLinkRepository) GetShareLinksByOwner(ownerID int64) ([]entities.ShareLink, error) {
+	rows, err := r.DB.Query("SELECT "+shareLinkColumns+" FROM share_links WHERE owner_id = ? ORDER BY id", ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var links []entities.ShareLink
+	for rows.Next() {
+		link, err := scanShareLink(rows)
+		if err != nil {
+			return nil, err
+		}
+		links = append(links, *link)
+	}
+
+	return links, rows.Err()
+}
+
+func (r *SQLiteShareLinkRepository) DeleteShareLink(ownerID, id int64) (int64, error) {
+	result, err := r.DB.Exec("DELETE FROM share_links WHERE id = ? AND owner_id = ?", id, ownerID)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+func scanShareLink(row rowScanner) (*entities.ShareLink, error) {
+	var link entities.ShareLink
+	var taskIDs string
+	var created, expires int64
+	err := row.Scan(&link.ID, &link.OwnerID, &link.Name, &link.TokenHash, &link.Filter, &taskIDs, &link.PasswordHash, &created, &expires)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrShareLinkNotFound
+		}
+		return nil, err
+	}
+
+	link.TaskIDs = strings.Fields(taskIDs)
+	link.HasPassword = link.PasswordHash != ""
+	link.Created = time.Unix(created, 0).UTC()
+	link.Expires = timeOrNil(expires)
+	return &link, nil
+}
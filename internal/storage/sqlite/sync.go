@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+
+	"github.com/antonkazachenko/go-todo-list-api/internal/entities"
+)
+
+var ErrSyncRefNotFound = errors.New("sync ref not found")
+
+// LastChange is the sequence number of the latest task write.
+func (r *SQLiteTaskRepository) LastChange() (int64, error) {
+	var seq int64
+	err := r.conn().QueryRow("SELECT COALESCE(MAX(seq), 0) FROM sync_changes").Scan(&seq)
+	return seq, err
+}
+
+// ChangesSince returns the owner's tasks created or changed after the
+// sequence number seq and the ids of the tasks deleted after it.
+func (r *SQLiteTaskRepository) ChangesSince(ownerID, seq int64) ([]entities.Task, []string, error) {
+	rows, err := r.conn().Query("SELECT "+taskColumns+" FROM scheduler WHERE id IN (SELECT task_id FROM sync_changes WHERE owner_id = ? AND seq > ? AND deleted = 0) ORDER BY date, id", ownerID, seq)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	tasks, err := scanTasks(rows)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	deletedRows, err := r.conn().Query("SELECT task_id FROM sync_changes WHERE owner_id = ? AND seq > ? AND deleted = 1 ORDER BY seq", ownerID, seq)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer deletedRows.Close()
+
+	var deleted []string
+	for deletedRows.Next() {
+		var id string
+		if err := deletedRows.Scan(&id); err != nil {
+			return nil, nil, err
+		}
+		deleted = append(deleted, id)
+	}
+
+	return tasks, deleted, deletedRows.Err()
+}
+
+// FieldTimes returns when each field of the task was last written, in Unix
+// milliseconds. Fields not written since the task was created are missing.
+func (r *SQLiteTaskRepository) FieldTimes(ownerID int64, id string) (map[string]int64, error) {
+	var data string
+	err := r.conn().QueryRow("SELECT field_times FROM scheduler WHERE id = ? AND owner_id = ?", id, ownerID).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, ErrTaskNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	times := map[string]int64{}
+	if err := json.Unmarshal([]byte(data), &times); err != nil {
+		return nil, err
+	}
+	return times, nil
+}
+
+// SyncRef returns the id of the task an earlier create with the same ref
+// made in the owner's list, or ErrSyncRefNotFound.
+func (r *SQLiteTaskRepository) SyncRef(ownerID, userID int64, ref string) (string, error) {
+	var id string
+	err := r.conn().QueryRow("SELECT task_id FROM sync_refs WHERE owner_id = ? AND user_id = ? AND ref = ?", ownerID, userID, ref).Scan(&id)
+	if err == sql.ErrNoRows {
+		return "", ErrSyncRefNotFound
+	}
+	return id, err
+}
+
+// AddSyncRef records that the create with ref made the task id.
+func (r *SQLiteTaskRepository) AddSyncRef(ownerID, userID int64, ref string, id int64) error {
+	tx, err := r.begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("INSERT INTO sync_refs (owner_id, user_id, ref, task_id) VALUES (?, ?, ?, ?)", ownerID, userID, ref, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
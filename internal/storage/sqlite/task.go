@@ -3,118 +3,426 @@ package storage
 import (
 	"database/sql"
 	"errors"
-	"fmt"
+	"strings"
 	"time"
 
 	"github.com/antonkazachenko/go-todo-list-api/internal/entities"
 )
 
+const taskColumns = "id, date, title, comment, repeat, priority, created, version"
+
+var (
+	ErrTaskNotFound    = errors.New("task not found")
+	ErrVersionConflict = errors.New("task version conflict")
+)
+
 type SQLiteTaskRepository struct {
 	DB *sql.DB
+	tx *sql.Tx
 }
 
 func NewSQLiteTaskRepository(db *sql.DB) *SQLiteTaskRepository {
 	return &SQLiteTaskRepository{DB: db}
 }
 
-func (r *SQLiteTaskRepository) AddTask(task entities.Task) (int64, error) {
-	result, err := r.DB.Exec("INSERT INTO scheduler (date, title, comment, repeat) VALUES (?, ?, ?, ?)",
-		task.Date, task.Title, task.Comment, task.Repeat)
+// InTx runs fn with a copy of the repository whose reads and writes all go
+// through one transaction, committed when fn returns nil.
+func (r *SQLiteTaskRepository) InTx(fn func(repo *SQLiteTaskRepository) error) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(&SQLiteTaskRepository{DB: r.DB, tx: tx}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Savepoint runs fn inside InTx so that, if fn fails, only its own changes are
+// undone and the transaction can go on.
+func (r *SQLiteTaskRepository) Savepoint(fn func() error) error {
+	if r.tx == nil {
+		return fn()
+	}
+
+	if _, err := r.tx.Exec("SAVEPOINT task_change"); err != nil {
+		return err
+	}
+	if err := fn(); err != nil {
+		if _, rollbackErr := r.tx.Exec("ROLLBACK TO task_change"); rollbackErr != nil {
+			return rollbackErr
+		}
+		r.tx.Exec("RELEASE task_change")
+		return err
+	}
+	_, err := r.tx.Exec("RELEASE task_change")
+	return err
+}
+
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+func (r *SQLiteTaskRepository) conn() queryer {
+	if r.tx != nil {
+		return r.tx
+	}
+	return r.DB
+}
+
+// taskTx is the transaction a write runs in: its own, or the one shared by
+// InTx, which the write must leave to InTx to commit or roll back.
+type taskTx struct {
+	*sql.Tx
+	shared bool
+}
+
+func (t taskTx) Commit() error {
+	if t.shared {
+		return nil
+	}
+	return t.Tx.Commit()
+}
+
+func (t taskTx) Rollback() error {
+	if t.shared {
+		return nil
+	}
+	return t.Tx.Rollback()
+}
+
+func (r *SQLiteTaskRepository) begin() (taskTx, error) {
+	if r.tx != nil {
+		return taskTx{Tx: r.tx, shared: true}, nil
+	}
+	tx, err := r.DB.Begin()
+	return taskTx{Tx: tx}, err
+}
+
+func (r *SQLiteTaskRepository) AddTask(ownerID int64, task entities.Task, actor entities.Actor) (int64, error) {
+	ids, err := r.AddTasks(ownerID, []entities.Task{task}, actor)
 	if err != nil {
 		return 0, err
 	}
 
-	return result.LastInsertId()
+	return ids[0], nil
 }
 
-func (r *SQLiteTaskRepository) GetTasks(searchTerm string, limit int) ([]entities.Task, error) {
-	query := "SELECT id, date, title, comment, repeat FROM scheduler"
-	args := []interface{}{}
+func (r *SQLiteTaskRepository) AddTasks(ownerID int64, tasks []entities.Task, actor entities.Actor) ([]int64, error) {
+	tx, err := r.begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
 
-	parsedDate, dateErr := time.Parse("02.01.2006", searchTerm)
-	switch {
-	case dateErr == nil:
-		formattedDate := parsedDate.Format("20060102")
-		query += " WHERE date = ? ORDER BY date LIMIT ?"
-		args = append(args, formattedDate, limit)
-	case searchTerm != "":
-		query += " WHERE title LIKE ? OR comment LIKE ? ORDER BY date LIMIT ?"
-		searchTerm = "%" + searchTerm + "%"
-		args = append(args, searchTerm, searchTerm, limit)
-	default:
-		query += " ORDER BY date LIMIT ?"
-		args = append(args, limit)
-	}
-
-	rows, err := r.DB.Query(query, args...)
+	stmt, err := tx.Prepare("INSERT INTO scheduler (date, title, comment, repeat, priority, created, owner_id) VALUES (?, ?, ?, ?, ?, ?, ?)")
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	defer stmt.Close()
 
-	var tasks []entities.Task
-	for rows.Next() {
-		var task entities.Task
-		err = rows.Scan(&task.ID, &task.Date, &task.Title, &task.Comment, &task.Repeat)
+	ids := make([]int64, 0, len(tasks))
+	for _, task := range tasks {
+		result, err := stmt.Exec(task.Date, task.Title, task.Comment, task.Repeat, task.Priority, createdDate(task), ownerID)
 		if err != nil {
 			return nil, err
 		}
-		tasks = append(tasks, task)
+		id, err := result.LastInsertId()
+		if err != nil {
+			return nil, err
+		}
+		if err := addAuditEntry(tx.Tx, actor, ownerID, id, entities.AuditCreate, nil, &task); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
 	}
 
-	return tasks, nil
+	return ids, tx.Commit()
 }
 
-func (r *SQLiteTaskRepository) GetTaskByID(id string) (*entities.Task, error) {
-	row := r.DB.QueryRow("SELECT id, date, title, comment, repeat FROM scheduler WHERE id = ?", id)
-	var task entities.Task
-	err := row.Scan(&task.ID, &task.Date, &task.Title, &task.Comment, &task.Repeat)
+func (r *SQLiteTaskRepository) GetAllTasks(ownerID int64) ([]entities.Task, error) {
+	rows, err := r.conn().Query("SELECT "+taskColumns+" FROM scheduler WHERE owner_id = ? ORDER BY date, id", ownerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanTasks(rows)
+}
+
+// GetTasks lists the owner's tasks matching both the share filter and the
+// search term; either may be empty.
+func (r *SQLiteTaskRepository) GetTasks(ownerID int64, filter, searchTerm string, limit int) ([]entities.Task, error) {
+	query := "SELECT " + taskColumns + " FROM scheduler WHERE owner_id = ?"
+	args := []interface{}{ownerID}
+
+	for _, term := range []string{filter, searchTerm} {
+		condition, termArgs := searchCondition(term)
+		query += condition
+		args = append(args, termArgs...)
+	}
+
+	query += " ORDER BY date LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := r.conn().Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanTasks(rows)
+}
+
+// FindTasks lists every task of the owner matching the share filter and
+// the task filter, without a limit.
+func (r *SQLiteTaskRepository) FindTasks(ownerID int64, shareFilter string, filter entities.TaskFilter) ([]entities.Task, error) {
+	query := "SELECT " + taskColumns + " FROM scheduler WHERE owner_id = ?"
+	args := []interface{}{ownerID}
+
+	for _, term := range []string{shareFilter, filter.Search} {
+		condition, termArgs := searchCondition(term)
+		query += condition
+		args = append(args, termArgs...)
+	}
+	if filter.From != "" {
+		query += " AND date >= ?"
+		args = append(args, filter.From)
+	}
+	if filter.To != "" {
+		query += " AND date <= ?"
+		args = append(args, filter.To)
+	}
+
+	rows, err := r.conn().Query(query+" ORDER BY date, id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanTasks(rows)
+}
+
+func (r *SQLiteTaskRepository) GetTasksByIDs(ownerID int64, ids []string, filter string, limit int) ([]entities.Task, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	query := "SELECT " + taskColumns + " FROM scheduler WHERE owner_id = ? AND id IN (?" + strings.Repeat(", ?", len(ids)-1) + ")"
+	args := []interface{}{ownerID}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+
+	condition, filterArgs := searchCondition(filter)
+	query += condition + " ORDER BY date LIMIT ?"
+	args = append(append(args, filterArgs...), limit)
+
+	rows, err := r.conn().Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanTasks(rows)
+}
+
+func (r *SQLiteTaskRepository) GetTaskByID(ownerID int64, id string) (*entities.Task, error) {
+	return r.GetFilteredTaskByID(ownerID, id, "")
+}
+
+func (r *SQLiteTaskRepository) GetFilteredTaskByID(ownerID int64, id, filter string) (*entities.Task, error) {
+	condition, args := searchCondition(filter)
+	args = append([]interface{}{id, ownerID}, args...)
+
+	row := r.conn().QueryRow("SELECT "+taskColumns+" FROM scheduler WHERE id = ? AND owner_id = ?"+condition, args...)
+	task, err := scanTask(row)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, errors.New("task not found")
+			return nil, ErrTaskNotFound
 		}
 		return nil, err
 	}
-	return &task, nil
+	return task, nil
+}
+
+func (r *SQLiteTaskRepository) GetTaskOwner(id string) (int64, error) {
+	var ownerID int64
+	err := r.conn().QueryRow("SELECT owner_id FROM scheduler WHERE id = ?", id).Scan(&ownerID)
+	if err == sql.ErrNoRows {
+		return 0, ErrTaskNotFound
+	}
+	return ownerID, err
 }
 
-func (r *SQLiteTaskRepository) UpdateTask(taskUpdates map[string]interface{}) (int64, error) {
+// UpdateTask writes the fields set in patch and bumps the task version. A
+// non-empty version makes the update conditional on the task still having it.
+func (r *SQLiteTaskRepository) UpdateTask(ownerID int64, id string, patch entities.TaskPatch, version string, actor entities.Actor) (int64, error) {
+	return r.UpdateTaskAt(ownerID, id, patch, version, actor, time.Now())
+}
+
+// UpdateTaskAt is UpdateTask for a change made at the given time, which is
+// recorded as the time each field in patch was last written.
+func (r *SQLiteTaskRepository) UpdateTaskAt(ownerID int64, id string, patch entities.TaskPatch, version string, actor entities.Actor, changed time.Time) (int64, error) {
+	columns := []struct {
+		name  string
+		value *string
+	}{
+		{"date", patch.Date},
+		{"title", patch.Title},
+		{"comment", patch.Comment},
+		{"repeat", patch.Repeat},
+		{"priority", patch.Priority},
+	}
+
 	query := "UPDATE scheduler SET "
 	args := []interface{}{}
-	i := 0
-
-	for key, value := range taskUpdates {
-		if key != "id" {
-			if i > 0 {
-				query += ", "
-			}
-			query += fmt.Sprintf("%s = ?", key)
-			args = append(args, value)
-			i++
+	times := ""
+	timeArgs := []interface{}{}
+	for _, column := range columns {
+		if column.value != nil {
+			query += column.name + " = ?, "
+			args = append(args, *column.value)
+			times += ", '$." + column.name + "', ?"
+			timeArgs = append(timeArgs, changed.UnixMilli())
 		}
 	}
+	if times != "" {
+		query += "field_times = json_set(field_times" + times + "), "
+		args = append(args, timeArgs...)
+	}
+	query += "version = version + 1 WHERE id = ? AND owner_id = ?"
+	args = append(args, id, ownerID)
+
+	return r.changeTask(ownerID, id, version, actor, entities.AuditUpdate, query, args...)
+}
+
+func (r *SQLiteTaskRepository) DeleteTask(ownerID int64, id, version string, actor entities.Actor) (int64, error) {
+	return r.changeTask(ownerID, id, version, actor, entities.AuditDelete, "DELETE FROM scheduler WHERE id = ? AND owner_id = ?", id, ownerID)
+}
+
+// CompleteTask deletes a one-off task that was marked as done.
+func (r *SQLiteTaskRepository) CompleteTask(ownerID int64, id, version string, actor entities.Actor) (int64, error) {
+	return r.changeTask(ownerID, id, version, actor, entities.AuditDone, "DELETE FROM scheduler WHERE id = ? AND owner_id = ?", id, ownerID)
+}
 
-	query += " WHERE id = ?"
-	args = append(args, taskUpdates["id"])
+func (r *SQLiteTaskRepository) MarkTaskAsDone(ownerID int64, id, date, version string, actor entities.Actor) error {
+	_, err := r.changeTask(ownerID, id, version, actor, entities.AuditDone, "UPDATE scheduler SET date = ?, field_times = json_set(field_times, '$.date', ?), version = version + 1 WHERE id = ? AND owner_id = ?", date, time.Now().UnixMilli(), id, ownerID)
+	return err
+}
 
-	result, err := r.DB.Exec(query, args...)
+// changeTask runs a statement against one task and records the change with
+// the task state before and after it in the same transaction. If version is
+// set and the task has moved on, nothing is changed and ErrVersionConflict
+// is returned. A change that finds no row fails as described in missingTask.
+func (r *SQLiteTaskRepository) changeTask(ownerID int64, id, version string, actor entities.Actor, action, query string, args ...interface{}) (int64, error) {
+	tx, err := r.begin()
 	if err != nil {
 		return 0, err
 	}
+	defer tx.Rollback()
 
-	return result.RowsAffected()
-}
+	before, err := taskInTx(tx.Tx, ownerID, id)
+	if err == ErrTaskNotFound {
+		return 0, missingTask(version)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if version != "" && version != before.Version {
+		return 0, ErrVersionConflict
+	}
 
-func (r *SQLiteTaskRepository) DeleteTask(id string) (int64, error) {
-	result, err := r.DB.Exec("DELETE FROM scheduler WHERE id = ?", id)
+	result, err := tx.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	affected, err := result.RowsAffected()
 	if err != nil {
 		return 0, err
 	}
+	if affected == 0 {
+		return 0, missingTask(version)
+	}
+
+	after, err := taskInTx(tx.Tx, ownerID, id)
+	if err == ErrTaskNotFound {
+		after = nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	if err := addAuditEntry(tx.Tx, actor, ownerID, id, action, before, after); err != nil {
+		return 0, err
+	}
 
-	return result.RowsAffected()
+	return affected, tx.Commit()
 }
 
-func (r *SQLiteTaskRepository) MarkTaskAsDone(id, date string) error {
-	_, err := r.DB.Exec("UPDATE scheduler SET date = ? WHERE id = ?", date, id)
-	return err
+// missingTask is the error for a change that found no row: ErrVersionConflict
+// when the client named the version it expected, since the task is no longer
+// at it, and ErrTaskNotFound otherwise.
+func missingTask(version string) error {
+	if version != "" {
+		return ErrVersionConflict
+	}
+	return ErrTaskNotFound
+}
+
+func taskInTx(tx *sql.Tx, ownerID int64, id string) (*entities.Task, error) {
+	task, err := scanTask(tx.QueryRow("SELECT "+taskColumns+" FROM scheduler WHERE id = ? AND owner_id = ?", id, ownerID))
+	if err == sql.ErrNoRows {
+		return nil, ErrTaskNotFound
+	}
+	return task, err
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanTask(row rowScanner) (*entities.Task, error) {
+	var task entities.Task
+	err := row.Scan(&task.ID, &task.Date, &task.Title, &task.Comment, &task.Repeat, &task.Priority, &task.Created, &task.Version)
+	if err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+func scanTasks(rows *sql.Rows) ([]entities.Task, error) {
+	var tasks []entities.Task
+	for rows.Next() {
+		task, err := scanTask(rows)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, *task)
+	}
+
+	return tasks, rows.Err()
+}
+
+// searchCondition matches a dd.mm.yyyy date exactly and any other term as a
+// substring of the title or comment.
+func searchCondition(term string) (string, []interface{}) {
+	if parsedDate, err := time.Parse("02.01.2006", term); err == nil {
+		return " AND date = ?", []interface{}{parsedDate.Format("20060102")}
+	}
+	if term == "" {
+		return "", nil
+	}
+
+	term = "%" + term + "%"
+	return " AND (title LIKE ? OR comment LIKE ?)", []interface{}{term, term}
+}
+
+func createdDate(task entities.Task) string {
+	if task.Created != "" {
+		return task.Created
+	}
+	return time.Now().Format("20060102")
 }
@@ -0,0 +1,241 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/antonkazachenko/go-todo-list-api/internal/entities"
+)
+
+var ErrUserNotFound = errors.New("user not found")
+
+const userColumns = "id, login, password_hash, role, created, totp_secret, totp_enabled, totp_last_step"
+
+type SQLiteUserRepository struct {
+	DB *sql.DB
+}
+
+func NewSQLiteUserRepository(db *sql.DB) *SQLiteUserRepository {
+	return &SQLiteUserRepository{DB: db}
+}
+
+func (r *SQLiteUserRepository) AddUser(user entities.User) (int64, error) {
+	result, err := r.DB.Exec("INSERT INTO users (login, password_hash, role, created) VALUES (?, ?, ?, ?)",
+		user.Login, user.PasswordHash, user.Role, user.Created)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.LastInsertId()
+}
+
+func (r *SQLiteUserRepository) GetUsers() ([]entities.User, error) {
+	rows, err := r.DB.Query("SELECT " + userColumns + " FROM users ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []entities.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, *user)
+	}
+
+	return users, rows.Err()
+}
+
+func (r *SQLiteUserRepository) GetUserByID(id int64) (*entities.User, error) {
+	return scanUser(r.DB.QueryRow("SELECT "+userColumns+" FROM users WHERE id = ?", id))
+}
+
+func (r *SQLiteUserRepository) GetUserByLogin(login string) (*entities.User, error) {
+	return scanUser(r.DB.QueryRow("SELECT "+userColumns+" FROM users WHERE login = ?", login))
+}
+
+func (r *SQLiteUserRepository) GetUserByIdentity(issuer, subject string) (*entities.User, error) {
+	return scanUser(r.DB.QueryRow("SELECT "+prefixColumns("u.", userColumns)+" FROM users u JOIN identities i ON i.user_id = u.id WHERE i.issuer = ? AND i.subject = ?",
+		issuer, subject))
+}
+
+// AddUserWithIdentity creates an account linked to an external identity.
+func (r *SQLiteUserRepository) AddUserWithIdentity(user entities.User, issuer, subject string) (int64, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("INSERT INTO users (login, password_hash, role, created) VALUES (?, ?, ?, ?)",
+		user.Login, user.PasswordHash, user.Role, user.Created)
+	if err != nil {
+		return 0, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec("INSERT INTO identities (issuer, subject, user_id, created) VALUES (?, ?, ?, ?)",
+		issuer, subject, id, time.Now().Unix()); err != nil {
+		return 0, err
+	}
+
+	return id, tx.Commit()
+}
+
+func (r *SQLiteUserRepository) GetFirstAdmin() (*entities.User, error) {
+	return scanUser(r.DB.QueryRow("SELECT "+userColumns+" FROM users WHERE role = ? ORDER BY id LIMIT 1", entities.RoleAdmin))
+}
+
+func (r *SQLiteUserRepository) CountAdmins() (int, error) {
+	var count int
+	err := r.DB.QueryRow("SELECT COUNT(*) FROM users WHERE role = ?", entities.RoleAdmin).Scan(&count)
+	return count, err
+}
+
+func (r *SQLiteUserRepository) UpdateUser(user entities.User) (int64, error) {
+	result, err := r.DB.Exec("UPDATE users SET login = ?, password_hash = ?, role = ? WHERE id = ?",
+		user.Login, user.PasswordHash, user.Role, user.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+func (r *SQLiteUserRepository) DeleteUser(id int64) (int64, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM scheduler WHERE owner_id = ?", id); err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec("DELETE FROM sync_refs WHERE owner_id = ? OR user_id = ?", id, id); err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec("DELETE FROM sessions WHERE user_id = ?", id); err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec("DELETE FROM access_tokens WHERE user_id = ?", id); err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec("DELETE FROM recovery_codes WHERE user_id = ?", id); err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec("DELETE FROM identities WHERE user_id = ?", id); err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec("DELETE FROM shares WHERE owner_id = ? OR user_id = ?", id, id); err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec("DELETE FROM share_links WHERE owner_id = ?", id); err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec("DELETE FROM webhook_deliveries WHERE webhook_id IN (SELECT id FROM webhooks WHERE user_id = ?)", id); err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec("DELETE FROM webhooks WHERE user_id = ?", id); err != nil {
+		return 0, err
+	}
+
+	result, err := tx.Exec("DELETE FROM users WHERE id = ?", id)
+	if err != nil {
+		return 0, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return affected, tx.Commit()
+}
+
+func (r *SQLiteUserRepository) SetTOTP(userID int64, secret string, enabled bool) error {
+	_, err := r.DB.Exec("UPDATE users SET totp_secret = ?, totp_enabled = ?, totp_last_step = 0 WHERE id = ?", secret, enabled, userID)
+	return err
+}
+
+// UseTOTPStep records the time step of an accepted code; it affects no rows if
+// that step or a later one was already used, which rejects replays.
+func (r *SQLiteUserRepository) UseTOTPStep(userID, step int64) (int64, error) {
+	result, err := r.DB.Exec("UPDATE users SET totp_last_step = ? WHERE id = ? AND totp_last_step < ?", step, userID, step)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+func (r *SQLiteUserRepository) ReplaceRecoveryCodes(userID int64, hashes []string) error {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM recovery_codes WHERE user_id = ?", userID); err != nil {
+		return err
+	}
+
+	for _, hash := range hashes {
+		if _, err := tx.Exec("INSERT INTO recovery_codes (user_id, code_hash) VALUES (?, ?)", userID, hash); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *SQLiteUserRepository) UseRecoveryCode(userID int64, hash string) (int64, error) {
+	result, err := r.DB.Exec("DELETE FROM recovery_codes WHERE user_id = ? AND code_hash = ?", userID, hash)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+func (r *SQLiteUserRepository) AssignOrphanTasks(ownerID int64) error {
+	_, err := r.DB.Exec("UPDATE scheduler SET owner_id = ? WHERE owner_id = 0", ownerID)
+	return err
+}
+
+func prefixColumns(prefix, columns string) string {
+	fields := strings.Split(columns, ", ")
+	for i := range fields {
+		fields[i] = prefix + fields[i]
+	}
+	return strings.Join(fields, ", ")
+}
+
+func scanUser(row rowScanner) (*entities.User, error) {
+	var user entities.User
+	err := row.Scan(&user.ID, &user.Login, &user.PasswordHash, &user.Role, &user.Created,
+		&user.TOTPSecret, &user.TOTPEnabled, &user.TOTPLastStep)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return &user, nil
+}
@@ -0,0 +1,243 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/antonkazachenko/go-todo-list-api/internal/entities"
+)
+
+var (
+	ErrWebhookNotFound  = errors.New("webhook not found")
+	ErrDeliveryNotFound = errors.New("webhook delivery not found")
+)
+
+const (
+	webhookColumns  = "id, user_id, url, events, secret, active, created"
+	deliveryColumns = "id, webhook_id, event, task_id, COALESCE(dedupe_key, ''), payload, status, attempts, response_code, error, created, next_attempt, delivered"
+)
+
+type SQLiteWebhookRepository struct {
+	DB *sql.DB
+}
+
+func NewSQLiteWebhookRepository(db *sql.DB) *SQLiteWebhookRepository {
+	return &SQLiteWebhookRepository{DB: db}
+}
+
+func (r *SQLiteWebhookRepository) AddWebhook(hook entities.Webhook) (int64, error) {
+	result, err := r.DB.Exec("INSERT INTO webhooks (user_id, url, events, secret, active, created) VALUES (?, ?, ?, ?, ?, ?)",
+		hook.UserID, hook.URL, strings.Join(hook.Events, " "), hook.Secret, hook.Active, hook.Created.Unix())
+	if err != nil {
+		return 0, err
+	}
+
+	return result.LastInsertId()
+}
+
+func (r *SQLiteWebhookRepository) GetWebhook(userID, id int64) (*entities.Webhook, error) {
+	return scanWebhook(r.DB.QueryRow("SELECT "+webhookColumns+" FROM webhooks WHERE id = ? AND user_id = ?", id, userID))
+}
+
+func (r *SQLiteWebhookRepository) GetWebhookByID(id int64) (*entities.Webhook, error) {
+	return scanWebhook(r.DB.QueryRow("SELECT "+webhookColumns+" FROM webhooks WHERE id = ?", id))
+}
+
+func (r *SQLiteWebhookRepository) GetWebhooksByUser(userID int64) ([]entities.Webhook, error) {
+	return r.queryWebhooks("SELECT "+webhookColumns+" FROM webhooks WHERE user_id = ? ORDER BY id", userID)
+}
+
+func (r *SQLiteWebhookRepository) GetActiveWebhooks() ([]entities.Webhook, error) {
+	return r.queryWebhooks("SELECT " + webhookColumns + " FROM webhooks WHERE active = 1 ORDER BY id")
+}
+
+func (r *SQLiteWebhookRepository) UpdateWebhook(hook entities.Webhook) (int64, error) {
+	result, err := r.DB.Exec("UPDATE webhooks SET url = ?, events = ?, secret = ?, active = ? WHERE id = ? AND user_id = ?",
+		hook.URL, strings.Join(hook.Events, " "), hook.Secret, hook.Active, hook.ID, hook.UserID)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// DeleteWebhook deletes the webhook together with its delivery log.
+func (r *SQLiteWebhookRepository) DeleteWebhook(userID, id int64) (int64, error) {
+	tx, err := r.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("DELETE FROM webhooks WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return 0, err
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil || deleted == 0 {
+		return 0, err
+	}
+
+	if _, err := tx.Exec("DELETE FROM webhook_deliveries WHERE webhook_id = ?", id); err != nil {
+		return 0, err
+	}
+	return deleted, tx.Commit()
+}
+
+func (r *SQLiteWebhookRepository) DeleteDeliveries(webhookID int64) error {
+	_, err := r.DB.Exec("DELETE FROM webhook_deliveries WHERE webhook_id = ?", webhookID)
+	return err
+}
+
+// AddDelivery queues a delivery. A delivery with a key the webhook already
+// has is skipped and reported with id 0.
+func (r *SQLiteWebhookRepository) AddDelivery(delivery entities.WebhookDelivery) (int64, error) {
+	var key interface{}
+	if delivery.Key != "" {
+		key = delivery.Key
+	}
+
+	result, err := r.DB.Exec("INSERT OR IGNORE INTO webhook_deliveries (webhook_id, event, task_id, dedupe_key, payload, status, created, next_attempt) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		delivery.WebhookID, delivery.Event, delivery.TaskID, key, string(delivery.Payload), delivery.Status, delivery.Created.Unix(), unixOrZero(delivery.NextAttempt))
+	if err != nil {
+		return 0, err
+	}
+	if added, err := result.RowsAffected(); err != nil || added == 0 {
+		return 0, err
+	}
+
+	return result.LastInsertId()
+}
+
+// GetDelivery returns a delivery of one of the user's webhooks.
+func (r *SQLiteWebhookRepository) GetDelivery(userID, id int64) (*entities.WebhookDelivery, error) {
+	return scanDelivery(r.DB.QueryRow("SELECT "+deliveryColumns+" FROM webhook_deliveries WHERE id = ? AND webhook_id IN (SELECT id FROM webhooks WHERE user_id = ?)", id, userID))
+}
+
+// GetDeliveries returns the latest deliveries of the webhook, newest first.
+func (r *SQLiteWebhookRepository) GetDeliveries(webhookID int64, limit int) ([]entities.WebhookDelivery, error) {
+	return r.queryDeliveries("SELECT "+deliveryColumns+" FROM webhook_deliveries WHERE webhook_id = ? ORDER BY id DESC LIMIT ?", webhookID, limit)
+}
+
+// DueWebhooks returns the webhooks that have pending deliveries due.
+func (r *SQLiteWebhookRepository) DueWebhooks(now time.Time) ([]int64, error) {
+	rows, err := r.DB.Query("SELECT DISTINCT webhook_id FROM webhook_deliveries WHERE status = ? AND next_attempt <= ?", entities.DeliveryPending, now.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// DueDeliveries returns the webhook's pending deliveries whose next attempt
+// is due, oldest first.
+func (r *SQLiteWebhookRepository) DueDeliveries(webhookID int64, now time.Time, limit int) ([]entities.WebhookDelivery, error) {
+	return r.queryDeliveries("SELECT "+deliveryColumns+" FROM webhook_deliveries WHERE webhook_id = ? AND status = ? AND next_attempt <= ? ORDER BY next_attempt, id LIMIT ?", webhookID, entities.DeliveryPending, now.Unix(), limit)
+}
+
+// PruneDeliveries deletes finished deliveries created before the time. An
+// overdue delivery is kept while its task still has the date it was sent for,
+// so that it is not reported again.
+func (r *SQLiteWebhookRepository) PruneDeliveries(before time.Time) (int64, error) {
+	result, err := r.DB.Exec(`DELETE FROM webhook_deliveries WHERE status != ? AND created < ?
+		AND (dedupe_key IS NULL OR NOT EXISTS (SELECT 1 FROM scheduler WHERE id = task_id AND dedupe_key = 'overdue:' || id || ':' || date))`,
+		entities.DeliveryPending, before.Unix())
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+func (r *SQLiteWebhookRepository) UpdateDelivery(delivery entities.WebhookDelivery) error {
+	_, err := r.DB.Exec("UPDATE webhook_deliveries SET status = ?, attempts = ?, response_code = ?, error = ?, next_attempt = ?, delivered = ? WHERE id = ?",
+		delivery.Status, delivery.Attempts, delivery.ResponseCode, delivery.Error, unixOrZero(delivery.NextAttempt), unixOrZero(delivery.Delivered), delivery.ID)
+	return err
+}
+
+func (r *SQLiteWebhookRepository) queryWebhooks(query string, args ...interface{}) ([]entities.Webhook, error) {
+	rows, err := r.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hooks []entities.Webhook
+	for rows.Next() {
+		hook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, *hook)
+	}
+
+	return hooks, rows.Err()
+}
+
+func (r *SQLiteWebhookRepository) queryDeliveries(query string, args ...interface{}) ([]entities.WebhookDelivery, error) {
+	rows, err := r.DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []entities.WebhookDelivery
+	for rows.Next() {
+		delivery, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, *delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+func scanWebhook(row rowScanner) (*entities.Webhook, error) {
+	var hook entities.Webhook
+	var events string
+	var created int64
+	err := row.Scan(&hook.ID, &hook.UserID, &hook.URL, &events, &hook.Secret, &hook.Active, &created)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrWebhookNotFound
+		}
+		return nil, err
+	}
+
+	hook.Events = strings.Fields(events)
+	hook.Created = time.Unix(created, 0).UTC()
+	return &hook, nil
+}
+
+func scanDelivery(row rowScanner) (*entities.WebhookDelivery, error) {
+	var delivery entities.WebhookDelivery
+	var payload string
+	var created, nextAttempt, delivered int64
+	err := row.Scan(&delivery.ID, &delivery.WebhookID, &delivery.Event, &delivery.TaskID, &delivery.Key, &payload, &delivery.Status,
+		&delivery.Attempts, &delivery.ResponseCode, &delivery.Error, &created, &nextAttempt, &delivered)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrDeliveryNotFound
+		}
+		return nil, err
+	}
+
+	delivery.Payload = []byte(payload)
+	delivery.Created = time.Unix(created, 0).UTC()
+	delivery.NextAttempt = timeOrNil(nextAttempt)
+	delivery.Delivered = timeOrNil(delivered)
+	return &delivery, nil
+}
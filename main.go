@@ -1,10 +1,15 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/antonkazachenko/go-todo-list-api/config"
 	"github.com/antonkazachenko/go-todo-list-api/internal/service"
@@ -13,14 +18,133 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "hash-password" {
+		if err := runHashPassword(os.Args[2:]); err != nil {
+			log.Fatalf("Command %s failed: %v", os.Args[1], err)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "generate-key" {
+		if err := runGenerateKey(os.Args[2:]); err != nil {
+			log.Fatalf("Command %s failed: %v", os.Args[1], err)
+		}
+		return
+	}
+
+	adminPasswordHash, err := service.AdminPasswordHash(config.TODO_PASS_HASH, config.TODO_PASS)
+	if err != nil {
+		log.Fatalf("Failed to configure admin password: %v", err)
+	}
+
+	if config.TODO_JWT_SECRET != "" && config.TODO_JWT_SECRET == config.TODO_PASS {
+		log.Fatalf("TODO_JWT_SECRET must differ from TODO_PASSWORD")
+	}
+
+	oidcConfig := service.OIDCConfig{
+		Issuer:       config.TODO_OIDC_ISSUER,
+		ClientID:     config.TODO_OIDC_CLIENT_ID,
+		ClientSecret: config.TODO_OIDC_CLIENT_SECRET,
+		RedirectURL:  config.TODO_OIDC_REDIRECT_URL,
+		Scopes:       strings.Fields(config.TODO_OIDC_SCOPES),
+		Admins:       strings.Split(config.TODO_OIDC_ADMINS, ","),
+	}
+	authEnabled := adminPasswordHash != "" || oidcConfig.Enabled()
+
+	if authEnabled && config.TODO_JWT_SECRET == "" && config.TODO_JWT_KEYS == "" {
+		config.TODO_JWT_SECRET, err = service.RandomSecret()
+		if err != nil {
+			log.Fatalf("Failed to generate JWT secret: %v", err)
+		}
+		log.Printf("TODO_JWT_SECRET is not set, using a random secret: tokens will not survive a restart")
+	}
+
+	accessTTL, err := time.ParseDuration(config.TODO_ACCESS_TOKEN_TTL)
+	if err != nil {
+		log.Fatalf("Invalid TODO_ACCESS_TOKEN_TTL: %v", err)
+	}
+	refreshTTL, err := time.ParseDuration(config.TODO_REFRESH_TOKEN_TTL)
+	if err != nil {
+		log.Fatalf("Invalid TODO_REFRESH_TOKEN_TTL: %v", err)
+	}
+
+	accountAttempts, err := strconv.Atoi(config.TODO_SIGNIN_ACCOUNT_ATTEMPTS)
+	if err != nil {
+		log.Fatalf("Invalid TODO_SIGNIN_ACCOUNT_ATTEMPTS: %v", err)
+	}
+	ipAttempts, err := strconv.Atoi(config.TODO_SIGNIN_IP_ATTEMPTS)
+	if err != nil {
+		log.Fatalf("Invalid TODO_SIGNIN_IP_ATTEMPTS: %v", err)
+	}
+	signInBackoff, err := time.ParseDuration(config.TODO_SIGNIN_BACKOFF)
+	if err != nil {
+		log.Fatalf("Invalid TODO_SIGNIN_BACKOFF: %v", err)
+	}
+	signInLockout, err := time.ParseDuration(config.TODO_SIGNIN_LOCKOUT)
+	if err != nil {
+		log.Fatalf("Invalid TODO_SIGNIN_LOCKOUT: %v", err)
+	}
+	eventReplay, err := strconv.Atoi(config.TODO_EVENT_REPLAY)
+	if err != nil {
+		log.Fatalf("Invalid TODO_EVENT_REPLAY: %v", err)
+	}
+	webhookAttempts, err := strconv.Atoi(config.TODO_WEBHOOK_MAX_ATTEMPTS)
+	if err != nil {
+		log.Fatalf("Invalid TODO_WEBHOOK_MAX_ATTEMPTS: %v", err)
+	}
+	webhookRetryDelay, err := time.ParseDuration(config.TODO_WEBHOOK_RETRY_DELAY)
+	if err != nil {
+		log.Fatalf("Invalid TODO_WEBHOOK_RETRY_DELAY: %v", err)
+	}
+
+	keyring, err := service.LoadKeyring(strings.Split(config.TODO_JWT_KEYS, ","), config.TODO_JWT_SECRET)
+	if err != nil {
+		log.Fatalf("Failed to load TODO_JWT_KEYS: %v", err)
+	}
+	if _, err := keyring.Signer(); authEnabled && err != nil {
+		log.Fatalf("TODO_JWT_KEYS must contain at least one private key: %v", err)
+	}
+
 	db := storage.InitDB()
 	defer db.Close()
 
 	taskRepo := storage.NewSQLiteTaskRepository(db)
+	backupRepo := storage.NewSQLiteBackupRepository(db)
+	userRepo := storage.NewSQLiteUserRepository(db)
+	sessionRepo := storage.NewSQLiteSessionRepository(db)
+	accessTokenRepo := storage.NewSQLiteAccessTokenRepository(db)
+	shareRepo := storage.NewSQLiteShareRepository(db)
+	shareLinkRepo := storage.NewSQLiteShareLinkRepository(db)
+	auditRepo := storage.NewSQLiteAuditRepository(db)
+	webhookRepo := storage.NewSQLiteWebhookRepository(db)
+
+	taskService := service.NewTaskService(taskRepo, shareRepo)
+	taskService.Events = service.NewEventBus(eventReplay)
+	backupService := service.NewBackupService(backupRepo)
+	userService := service.NewUserService(userRepo, adminPasswordHash, service.NewLoginLimiter(accountAttempts, ipAttempts, signInBackoff, signInLockout))
+	userService.ExternalAuth = oidcConfig.Enabled()
+	tokenService := service.NewTokenService(sessionRepo, keyring, accessTTL, refreshTTL)
+	accessTokenService := service.NewAccessTokenService(accessTokenRepo)
+	oidcService := service.NewOIDCService(oidcConfig, userService)
+	shareLinkService := service.NewShareLinkService(shareLinkRepo, taskRepo, userService.Limiter)
+	auditService := service.NewAuditService(auditRepo)
+	webhookService := service.NewWebhookService(webhookRepo, taskService, webhookAttempts, webhookRetryDelay)
+	webhookService.AllowPrivate = config.TODO_WEBHOOK_ALLOW_PRIVATE == "true"
+
+	if _, err := userService.EnsureAdmin(); err != nil {
+		log.Fatalf("Failed to initialize admin account: %v", err)
+	}
+
+	if len(os.Args) > 1 {
+		if err := runCommand(os.Args[1], os.Args[2:], taskService, backupService, userService, accessTokenService); err != nil {
+			log.Fatalf("Command %s failed: %v", os.Args[1], err)
+		}
+		return
+	}
 
-	taskService := service.NewTaskService(taskRepo)
+	go webhookService.Run(context.Background())
 
-	router := routes.RegisterRoutes(taskService)
+	router := routes.RegisterRoutes(taskService, backupService, userService, tokenService, accessTokenService, oidcService, shareLinkService, auditService, webhookService)
 
 	fileServer := http.FileServer(http.Dir("./web"))
 	router.Get("/*", func(w http.ResponseWriter, r *http.Request) {
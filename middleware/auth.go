@@ -1,41 +1,134 @@
 package middleware
 
 import (
+	"context"
 	"net/http"
+	"strings"
 
-	"github.com/antonkazachenko/go-todo-list-api/config"
-	"github.com/golang-jwt/jwt/v4"
+	"github.com/antonkazachenko/go-todo-list-api/internal/entities"
+	"github.com/antonkazachenko/go-todo-list-api/internal/service"
+	"github.com/antonkazachenko/go-todo-list-api/utils"
 )
 
-func Auth(next http.HandlerFunc) http.HandlerFunc {
+type contextKey string
+
+const (
+	userContextKey        contextKey = "user"
+	claimsContextKey      contextKey = "claims"
+	accessTokenContextKey contextKey = "access_token"
+)
+
+type Authenticator struct {
+	UserService        *service.UserService
+	TokenService       *service.TokenService
+	AccessTokenService *service.AccessTokenService
+}
+
+func NewAuthenticator(userService *service.UserService, tokenService *service.TokenService, accessTokenService *service.AccessTokenService) *Authenticator {
+	return &Authenticator{UserService: userService, TokenService: tokenService, AccessTokenService: accessTokenService}
+}
+
+func UserFromContext(ctx context.Context) (*entities.User, bool) {
+	user, ok := ctx.Value(userContextKey).(*entities.User)
+	return user, ok
+}
+
+func ClaimsFromContext(ctx context.Context) (*service.AccessClaims, bool) {
+	claims, ok := ctx.Value(claimsContextKey).(*service.AccessClaims)
+	return claims, ok
+}
+
+func AccessTokenFromContext(ctx context.Context) (*entities.AccessToken, bool) {
+	token, ok := ctx.Value(accessTokenContextKey).(*entities.AccessToken)
+	return token, ok
+}
+
+func (a *Authenticator) Auth(next http.HandlerFunc) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		pass := config.TODO_PASS
-		if len(pass) > 0 {
-			var jwtToken string
-			cookie, err := r.Cookie("token")
-			if err == nil {
-				jwtToken = cookie.Value
-			}
-
-			var valid bool
-			if jwtToken != "" {
-				token, err := jwt.Parse(jwtToken, func(token *jwt.Token) (interface{}, error) {
-					if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-						return nil, http.ErrAbortHandler
-					}
-					return []byte(pass), nil
-				})
-
-				if err == nil && token.Valid {
-					valid = true
-				}
-			}
-
-			if !valid {
-				http.Error(w, "Authentication required", http.StatusUnauthorized)
-				return
-			}
+		ctx, ok := a.authenticate(r)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			utils.SendErrorResponse(w, r, utils.NewError("authentication_required"), http.StatusUnauthorized)
+			return
+		}
+
+		next(w, r.WithContext(ctx))
+	})
+}
+
+// Scope lets the request through only if a personal access token carries the
+// scope. Session tokens are not scoped.
+func (a *Authenticator) Scope(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return a.Auth(func(w http.ResponseWriter, r *http.Request) {
+		if token, ok := AccessTokenFromContext(r.Context()); ok && !service.HasScope(token.Scopes, scope) {
+			w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope", scope="`+scope+`"`)
+			utils.SendErrorResponse(w, r, utils.NewError("insufficient_scope", scope), http.StatusForbidden)
+			return
 		}
 		next(w, r)
 	})
 }
+
+func (a *Authenticator) Admin(next http.HandlerFunc) http.HandlerFunc {
+	return a.Scope(entities.ScopeAdmin, func(w http.ResponseWriter, r *http.Request) {
+		user, _ := UserFromContext(r.Context())
+		if user.Role != entities.RoleAdmin {
+			utils.SendErrorResponse(w, r, utils.NewError("admin_required"), http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	})
+}
+
+func (a *Authenticator) authenticate(r *http.Request) (context.Context, bool) {
+	ctx := r.Context()
+
+	if !a.UserService.AuthEnabled() {
+		user, err := a.UserService.Repo.GetFirstAdmin()
+		return context.WithValue(ctx, userContextKey, user), err == nil
+	}
+
+	credential := bearerToken(r)
+	if credential == "" {
+		if cookie, err := r.Cookie("token"); err == nil {
+			credential = cookie.Value
+		}
+	}
+	if credential == "" {
+		return nil, false
+	}
+
+	var userID int64
+	if service.IsAccessToken(credential) {
+		token, err := a.AccessTokenService.Authenticate(credential)
+		if err != nil {
+			return nil, false
+		}
+		userID = token.UserID
+		ctx = context.WithValue(ctx, accessTokenContextKey, token)
+	} else {
+		claims, err := a.TokenService.ParseAccessToken(credential)
+		if err != nil {
+			return nil, false
+		}
+		userID, err = claims.UserID()
+		if err != nil {
+			return nil, false
+		}
+		ctx = context.WithValue(ctx, claimsContextKey, claims)
+	}
+
+	user, err := a.UserService.Repo.GetUserByID(userID)
+	if err != nil {
+		return nil, false
+	}
+	return context.WithValue(ctx, userContextKey, user), true
+}
+
+func bearerToken(r *http.Request) string {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
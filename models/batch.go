@@ -0,0 +1,17 @@
+package models
+
+import "github.com/antonkazachenko/go-todo-list-api/internal/entities"
+
+// BatchResult is the outcome of one batch operation, with the status the
+// matching single request would have answered.
+type BatchResult struct {
+	Status int            `json:"status"`
+	ID     string         `json:"id,omitempty"`
+	Task   *entities.Task `json:"task,omitempty"`
+	Error  *ErrorResponse `json:"error,omitempty"`
+}
+
+type BatchResponse struct {
+	Committed bool          `json:"committed"`
+	Results   []BatchResult `json:"results"`
+}
@@ -0,0 +1,7 @@
+package models
+
+type BulkResponse struct {
+	DryRun bool     `json:"dry_run"`
+	Action string   `json:"action"`
+	IDs    []string `json:"ids"`
+}
@@ -0,0 +1,22 @@
+package models
+
+// ImportRowError reports a rejected row. Err is the original error; Error,
+// Code and Field are filled from it in the language of the request.
+type ImportRowError struct {
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+	Code  string `json:"code,omitempty"`
+	Field string `json:"field,omitempty"`
+	Err   error  `json:"-"`
+}
+
+type ImportResponse struct {
+	DryRun   bool             `json:"dry_run"`
+	Total    int              `json:"total"`
+	Imported int              `json:"imported"`
+	Skipped  int              `json:"skipped"`
+	IDs      []int64          `json:"ids"`
+	Errors   []ImportRowError `json:"errors"`
+	Error    string           `json:"error,omitempty"`
+	Code     string           `json:"code,omitempty"`
+}
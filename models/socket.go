@@ -0,0 +1,22 @@
+package models
+
+import "github.com/antonkazachenko/go-todo-list-api/internal/entities"
+
+// SocketMessage is sent by the server over /api/ws. Ref is the ref of the
+// client message it answers or of the subscription a change belongs to.
+type SocketMessage struct {
+	Type   string         `json:"type"`
+	Ref    string         `json:"ref,omitempty"`
+	ID     string         `json:"id,omitempty"`
+	Task   *entities.Task `json:"task,omitempty"`
+	Result *BatchResult   `json:"result,omitempty"`
+	Error  *ErrorResponse `json:"error,omitempty"`
+}
+
+// SocketSnapshot is the first answer to a subscription: the tasks the query
+// matches now.
+type SocketSnapshot struct {
+	Type  string          `json:"type"`
+	Ref   string          `json:"ref"`
+	Tasks []entities.Task `json:"tasks"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import "github.com/antonkazachenko/go-todo-list-api/internal/entities"
+
+// SyncConflict is a field whose server value was kept because it was written
+// after the client's change. Client is null when the client deleted the task.
+type SyncConflict struct {
+	Field  string  `json:"field"`
+	Server string  `json:"server"`
+	Client *string `json:"client"`
+}
+
+// SyncResult is the outcome of one offline change.
+type SyncResult struct {
+	Ref       string         `json:"ref,omitempty"`
+	Status    int            `json:"status"`
+	ID        string         `json:"id,omitempty"`
+	Task      *entities.Task `json:"task,omitempty"`
+	Conflicts []SyncConflict `json:"conflicts,omitempty"`
+	Error     *ErrorResponse `json:"error,omitempty"`
+}
+
+type SyncResponse struct {
+	Token   string          `json:"token"`
+	Reset   bool            `json:"reset"`
+	Tasks   []entities.Task `json:"tasks"`
+	Deleted []string        `json:"deleted"`
+	Results []SyncResult    `json:"results,omitempty"`
+}
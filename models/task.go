@@ -1,7 +1,11 @@
 package models
 
 type AuthResponse struct {
-	Token string `json:"token"`
+	Token        string `json:"token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	ExpiresIn    int64  `json:"expires_in,omitempty"`
+	MFARequired  bool   `json:"mfa_required,omitempty"`
+	MFAToken     string `json:"mfa_token,omitempty"`
 }
 
 type IDResponse struct {
@@ -10,4 +14,25 @@ type IDResponse struct {
 
 type ErrorResponse struct {
 	Error string `json:"error"`
+	Code  string `json:"code"`
+	Field string `json:"field,omitempty"`
+
+	Current interface{} `json:"current,omitempty"`
+}
+
+// TaskV2 is a task as the v2 API returns it, with numeric id and version.
+type TaskV2 struct {
+	ID         int64  `json:"id"`
+	Date       string `json:"date"`
+	Title      string `json:"title"`
+	Comment    string `json:"comment"`
+	Repeat     string `json:"repeat"`
+	Priority   string `json:"priority"`
+	Created    string `json:"created"`
+	Version    int64  `json:"version"`
+	Permission string `json:"permission,omitempty"`
+}
+
+type TasksV2Response struct {
+	Tasks []TaskV2 `json:"tasks"`
 }
@@ -1,25 +1,82 @@
 package routes
 
 import (
+	"github.com/antonkazachenko/go-todo-list-api/internal/entities"
 	handlers "github.com/antonkazachenko/go-todo-list-api/internal/server"
 	"github.com/antonkazachenko/go-todo-list-api/internal/service"
 	"github.com/antonkazachenko/go-todo-list-api/middleware"
 	"github.com/go-chi/chi/v5"
 )
 
-func RegisterRoutes(taskService *service.TaskService) *chi.Mux {
+func RegisterRoutes(taskService *service.TaskService, backupService *service.BackupService, userService *service.UserService, tokenService *service.TokenService, accessTokenService *service.AccessTokenService, oidcService *service.OIDCService, shareLinkService *service.ShareLinkService, auditService *service.AuditService, webhookService *service.WebhookService) *chi.Mux {
 	r := chi.NewRouter()
 
-	h := handlers.NewHandlers(taskService)
+	h := handlers.NewHandlers(taskService, backupService, userService, tokenService, accessTokenService, oidcService, shareLinkService, auditService, webhookService)
+	auth := middleware.NewAuthenticator(userService, tokenService, accessTokenService)
 
 	r.Get("/api/nextdate", h.HandleNextDate)
-	r.Post("/api/task", middleware.Auth(h.HandleAddTask))
-	r.Get("/api/tasks", middleware.Auth(h.HandleGetTasks))
-	r.Get("/api/task", middleware.Auth(h.HandleGetTask))
-	r.Put("/api/task", middleware.Auth(h.HandlePutTask))
-	r.Delete("/api/task", middleware.Auth(h.HandleDeleteTask))
-	r.Post("/api/task/done", middleware.Auth(h.HandleDoneTask))
+	r.Post("/api/task", auth.Scope(entities.ScopeTasksWrite, h.HandleAddTask))
+	r.Get("/api/tasks", auth.Scope(entities.ScopeTasksRead, h.HandleGetTasks))
+	r.Get("/api/task", auth.Scope(entities.ScopeTasksRead, h.HandleGetTask))
+	r.Put("/api/task", auth.Scope(entities.ScopeTasksWrite, h.HandlePutTask))
+	r.Patch("/api/task", auth.Scope(entities.ScopeTasksWrite, h.HandlePatchTask))
+	r.Delete("/api/task", auth.Scope(entities.ScopeTasksWrite, h.HandleDeleteTask))
+	r.Post("/api/task/done", auth.Scope(entities.ScopeTasksWrite, h.HandleDoneTask))
+	r.Post("/api/tasks/batch", auth.Scope(entities.ScopeTasksWrite, h.HandleBatchTasks))
+	r.Post("/api/tasks/bulk", auth.Scope(entities.ScopeTasksWrite, h.HandleBulkTasks))
+	r.Get("/api/events", auth.Scope(entities.ScopeTasksRead, h.HandleEvents))
+	r.Get("/api/ws", auth.Scope(entities.ScopeTasksRead, h.HandleSocket))
+	r.Get("/api/sync", auth.Scope(entities.ScopeTasksRead, h.HandleGetSync))
+	r.Post("/api/sync", auth.Scope(entities.ScopeTasksWrite, h.HandlePostSync))
+	r.Get("/api/shares", auth.Scope(entities.ScopeTasksRead, h.HandleGetShares))
+	r.Post("/api/shares", auth.Scope(entities.ScopeTasksWrite, h.HandleAddShare))
+	r.Put("/api/shares", auth.Scope(entities.ScopeTasksWrite, h.HandlePutShare))
+	r.Delete("/api/shares", auth.Scope(entities.ScopeTasksWrite, h.HandleDeleteShare))
+	r.Get("/api/links", auth.Scope(entities.ScopeTasksRead, h.HandleGetShareLinks))
+	r.Post("/api/links", auth.Scope(entities.ScopeTasksWrite, h.HandleAddShareLink))
+	r.Delete("/api/links", auth.Scope(entities.ScopeTasksWrite, h.HandleDeleteShareLink))
+	r.Get("/api/public/{token}", h.HandlePublicTasks)
+	r.Post("/api/public/{token}", h.HandlePublicTasks)
+	r.Get("/s/{token}", h.HandleSharePage)
+	r.Post("/s/{token}", h.HandleSharePage)
+	r.Get("/api/webhooks", auth.Scope(entities.ScopeTasksRead, h.HandleGetWebhooks))
+	r.Post("/api/webhooks", auth.Scope(entities.ScopeTasksWrite, h.HandleAddWebhook))
+	r.Put("/api/webhooks", auth.Scope(entities.ScopeTasksWrite, h.HandlePutWebhook))
+	r.Delete("/api/webhooks", auth.Scope(entities.ScopeTasksWrite, h.HandleDeleteWebhook))
+	r.Get("/api/webhooks/deliveries", auth.Scope(entities.ScopeTasksRead, h.HandleGetWebhookDeliveries))
+	r.Post("/api/webhooks/redeliver", auth.Scope(entities.ScopeTasksWrite, h.HandleRedeliverWebhook))
+	r.Get("/api/audit", auth.Scope(entities.ScopeTasksRead, h.HandleGetAudit))
+	r.Get("/api/audit.csv", auth.Scope(entities.ScopeTasksRead, h.HandleExportAudit))
+	r.Get("/api/export.csv", auth.Scope(entities.ScopeTasksRead, h.HandleExportCSV))
+	r.Post("/api/import/csv", auth.Scope(entities.ScopeTasksWrite, h.HandleImportCSV))
+	r.Get("/api/export.txt", auth.Scope(entities.ScopeTasksRead, h.HandleExportTodoTxt))
+	r.Post("/api/import/todotxt", auth.Scope(entities.ScopeTasksWrite, h.HandleImportTodoTxt))
+	r.Get("/api/backup", auth.Admin(h.HandleBackup))
+	r.Post("/api/restore", auth.Admin(h.HandleRestore))
 	r.Post("/api/signin", h.HandleSignIn)
+	r.Post("/api/signin/2fa", h.HandleSignInSecondFactor)
+	r.Get("/api/signin/oidc", h.HandleOIDCLogin)
+	r.Get("/api/signin/oidc/callback", h.HandleOIDCCallback)
+	r.Post("/api/signup", h.HandleSignUp)
+	r.Post("/api/refresh", h.HandleRefresh)
+	r.Post("/api/signout", auth.Auth(h.HandleSignOut))
+	r.Get("/api/sessions", auth.Auth(h.HandleGetSessions))
+	r.Delete("/api/sessions", auth.Auth(h.HandleDeleteSession))
+	r.Get("/.well-known/jwks.json", h.HandleJWKS)
+	r.Get("/api/me", auth.Auth(h.HandleGetMe))
+	r.Post("/api/2fa/enroll", auth.Auth(h.HandleEnrollTOTP))
+	r.Post("/api/2fa/confirm", auth.Auth(h.HandleConfirmTOTP))
+	r.Post("/api/2fa/disable", auth.Auth(h.HandleDisableTOTP))
+	r.Post("/api/2fa/recovery-codes", auth.Auth(h.HandleRegenerateRecoveryCodes))
+	r.Get("/api/tokens", auth.Auth(h.HandleGetAccessTokens))
+	r.Post("/api/tokens", auth.Auth(h.HandleAddAccessToken))
+	r.Delete("/api/tokens", auth.Auth(h.HandleDeleteAccessToken))
+	r.Get("/api/users", auth.Admin(h.HandleGetUsers))
+	r.Post("/api/users", auth.Admin(h.HandleAddUser))
+	r.Put("/api/users", auth.Admin(h.HandlePutUser))
+	r.Delete("/api/users", auth.Admin(h.HandleDeleteUser))
+	r.Get("/api/openapi.json", h.HandleOpenAPI)
+	r.Mount("/api/v2", v2Routes(h, auth))
 
 	return r
 }
@@ -0,0 +1,26 @@
+package routes
+
+import (
+	"github.com/antonkazachenko/go-todo-list-api/internal/entities"
+	handlers "github.com/antonkazachenko/go-todo-list-api/internal/server"
+	"github.com/antonkazachenko/go-todo-list-api/middleware"
+	"github.com/go-chi/chi/v5"
+)
+
+// v2Routes is the resource-oriented API: ids in the path, numeric ids in
+// JSON and status codes that follow the method.
+func v2Routes(h *handlers.Handlers, auth *middleware.Authenticator) *chi.Mux {
+	r := chi.NewRouter()
+	r.NotFound(h.HandleNotFoundV2)
+	r.MethodNotAllowed(h.HandleMethodNotAllowedV2)
+
+	r.Get("/tasks", auth.Scope(entities.ScopeTasksRead, h.HandleGetTasksV2))
+	r.Post("/tasks", auth.Scope(entities.ScopeTasksWrite, h.HandleAddTaskV2))
+	r.Get("/tasks/{id:[0-9]+}", auth.Scope(entities.ScopeTasksRead, h.HandleGetTaskV2))
+	r.Put("/tasks/{id:[0-9]+}", auth.Scope(entities.ScopeTasksWrite, h.HandlePutTaskV2))
+	r.Patch("/tasks/{id:[0-9]+}", auth.Scope(entities.ScopeTasksWrite, h.HandlePatchTaskV2))
+	r.Delete("/tasks/{id:[0-9]+}", auth.Scope(entities.ScopeTasksWrite, h.HandleDeleteTaskV2))
+	r.Post("/tasks/{id:[0-9]+}/done", auth.Scope(entities.ScopeTasksWrite, h.HandleDoneTaskV2))
+
+	return r
+}
@@ -0,0 +1,113 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getAudit(t *testing.T, token, query string) []map[string]any {
+	code, m, err := requestAs(token, "api/audit?"+query, nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+
+	entries := []map[string]any{}
+	list, _ := m["entries"].([]any)
+	for _, entry := range list {
+		entries = append(entries, entry.(map[string]any))
+	}
+	return entries
+}
+
+func TestAudit(t *testing.T) {
+	suffix := time.Now().Format("150405.000000")
+	alice, aliceID := signUp(t, "alice-audit-"+suffix)
+	bob, bobID := signUp(t, "bob-audit-"+suffix)
+	defer func() {
+		for _, id := range []string{aliceID, bobID} {
+			requestAs(Token, "api/users?id="+id, nil, http.MethodDelete)
+		}
+	}()
+
+	code, _, err := requestAs(alice, "api/shares", map[string]any{"login": "bob-audit-" + suffix, "role": "editor"}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+
+	code, m, err := requestAs(alice, "api/task", map[string]any{"title": "Полить цветы"}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	taskID := fmt.Sprint(m["id"])
+
+	code, _, err = requestAs(bob, "api/task", map[string]any{
+		"id":      taskID,
+		"title":   "Полить кактус",
+		"date":    time.Now().Format("20060102"),
+		"version": "1",
+	}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+
+	code, _, err = requestAs(bob, "api/task?id="+taskID+"&version=2", nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+
+	entries := getAudit(t, alice, "task_id="+taskID)
+	if assert.Len(t, entries, 3) {
+		deleted, updated, created := entries[0], entries[1], entries[2]
+
+		assert.Equal(t, "delete", deleted["action"])
+		assert.Equal(t, bobID, fmt.Sprint(deleted["user_id"]))
+		assert.Equal(t, "bob-audit-"+suffix, deleted["user_login"])
+		assert.Equal(t, aliceID, fmt.Sprint(deleted["owner_id"]))
+		assert.Equal(t, map[string]any{"before": "Полить кактус"}, deleted["changes"].(map[string]any)["title"])
+
+		assert.Equal(t, "update", updated["action"])
+		assert.Equal(t, map[string]any{"before": "Полить цветы", "after": "Полить кактус"}, updated["changes"].(map[string]any)["title"])
+		assert.NotContains(t, updated["changes"], "comment")
+		assert.NotEmpty(t, updated["ip"])
+		assert.True(t, strings.HasPrefix(fmt.Sprint(updated["token_id"]), "jwt:"))
+
+		assert.Equal(t, "create", created["action"])
+		assert.Equal(t, aliceID, fmt.Sprint(created["user_id"]))
+		assert.Equal(t, map[string]any{"after": "Полить цветы"}, created["changes"].(map[string]any)["title"])
+	}
+
+	assert.Len(t, getAudit(t, alice, "task_id="+taskID+"&action=update"), 1)
+	assert.Len(t, getAudit(t, alice, "task_id="+taskID+"&user_id="+bobID), 2)
+	assert.Len(t, getAudit(t, alice, "task_id="+taskID+"&limit=1"), 1)
+	assert.Len(t, getAudit(t, alice, "task_id="+taskID+"&from="+time.Now().Add(time.Hour).Format(time.RFC3339)), 0)
+	assert.Len(t, getAudit(t, bob, "task_id="+taskID), 2)
+	assert.Len(t, getAudit(t, Token, "task_id="+taskID), 3)
+
+	pat, patID := createAccessToken(t, alice, "tasks:write")
+	code, m, err = requestBearer(pat, "api/task", map[string]any{"title": "Зарядка", "repeat": "d 1"}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	repeatID := fmt.Sprint(m["id"])
+
+	code, _, err = requestBearer(pat, "api/task/done?id="+repeatID+"&version=1", nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+
+	entries = getAudit(t, alice, "task_id="+repeatID)
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, "done", entries[0]["action"])
+		assert.Equal(t, "pat:"+patID, entries[0]["token_id"])
+		assert.Contains(t, entries[0]["changes"], "date")
+	}
+
+	code, body, err := requestRaw("api/audit.csv?task_id="+taskID, nil, "", http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	lines := strings.Split(strings.TrimSpace(string(body)), "\n")
+	assert.Len(t, lines, 4)
+	assert.Equal(t, "id,time,user_id,user_login,owner_id,task_id,action,changes,ip,token_id", lines[0])
+
+	code, _, err = requestAs(alice, "api/audit?action=rename", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, code)
+}
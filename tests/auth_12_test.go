@@ -0,0 +1,52 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignIn(t *testing.T) {
+	code, m, err := requestAs("", "api/signin", map[string]any{"password": "wrong-password"}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	assert.NotEmpty(t, m["error"])
+
+	code, m, err = requestAs("", "api/signin", map[string]any{"password": Password}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	token := fmt.Sprint(m["token"])
+
+	code, _, err = requestAs(token, "api/tasks", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+
+	signedWithPassword, err := jwt.New(jwt.SigningMethodHS256).SignedString([]byte(Password))
+	assert.NoError(t, err)
+	code, _, err = requestAs(signedWithPassword, "api/tasks", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	neverExpires, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": "1",
+		"jti": "never-expires",
+	}).SignedString([]byte(jwtSecret()))
+	assert.NoError(t, err)
+	code, _, err = requestAs(neverExpires, "api/tasks", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	withoutSession, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": "1",
+		"jti": fmt.Sprintf("no-session-%d", time.Now().UnixNano()),
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}).SignedString([]byte(jwtSecret()))
+	assert.NoError(t, err)
+	code, _, err = requestAs(withoutSession, "api/tasks", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, code)
+}
@@ -0,0 +1,144 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackupRestore(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	kept := addTask(t, task{
+		title:   "Сохранить в резервной копии",
+		comment: "Резервная копия",
+		repeat:  "d 2",
+	})
+
+	code, body, err := requestRaw("api/backup", nil, "", http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+
+	var backup struct {
+		Format  string `json:"format"`
+		Version int    `json:"version"`
+		Tables  map[string]struct {
+			Columns []string `json:"columns"`
+			Rows    [][]any  `json:"rows"`
+		} `json:"tables"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &backup))
+	assert.NotEmpty(t, backup.Format)
+	assert.Equal(t, 2, backup.Version)
+	scheduler, ok := backup.Tables["scheduler"]
+	if !assert.True(t, ok) {
+		return
+	}
+	assert.Contains(t, scheduler.Columns, "title")
+
+	before, err := count(db)
+	assert.NoError(t, err)
+	assert.Len(t, scheduler.Rows, before)
+
+	removed := addTask(t, task{title: "Исчезнет после восстановления"})
+
+	var m map[string]any
+	assert.NoError(t, json.Unmarshal(body, &m))
+	m["version"] = 999
+	invalid, err := json.Marshal(m)
+	assert.NoError(t, err)
+
+	code, _, err = requestRaw("api/restore", invalid, "application/json", http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	after, err := count(db)
+	assert.NoError(t, err)
+	assert.Equal(t, before+1, after)
+
+	code, _, err = requestRaw("api/restore", body, "application/json", http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+
+	after, err = count(db)
+	assert.NoError(t, err)
+	assert.Equal(t, before, after)
+
+	var task Task
+	err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, kept)
+	assert.NoError(t, err)
+	assert.Equal(t, "Резервная копия", task.Comment)
+	notFoundTask(t, removed)
+}
+
+// backupWithout downloads a backup and drops the table from it.
+func backupWithout(t *testing.T, table string) map[string]any {
+	code, body, err := requestRaw("api/backup", nil, "", http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+
+	var backup map[string]any
+	assert.NoError(t, json.Unmarshal(body, &backup))
+	tables, _ := backup["tables"].(map[string]any)
+	delete(tables, table)
+	return backup
+}
+
+func restoreBackup(t *testing.T, backup map[string]any) {
+	data, err := json.Marshal(backup)
+	assert.NoError(t, err)
+	code, _, err := requestRaw("api/restore", data, "application/json", http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+}
+
+func TestRestoreMissingTables(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	countLinks := func() int {
+		var n int
+		assert.NoError(t, db.Get(&n, "SELECT COUNT(*) FROM share_links WHERE name = ?", "backup-test"))
+		return n
+	}
+
+	backup := backupWithout(t, "share_links")
+	_, err := db.Exec("INSERT INTO share_links (owner_id, name, token_hash, created) VALUES (1, ?, ?, ?)",
+		"backup-test", "backup-test-hash", time.Now().Unix())
+	assert.NoError(t, err)
+	defer db.Exec("DELETE FROM share_links WHERE name = ?", "backup-test")
+
+	legacy := backupWithout(t, "share_links")
+	legacy["version"] = 1
+	tables, _ := legacy["tables"].(map[string]any)
+	scheduler, _ := tables["scheduler"].(map[string]any)
+	columns, _ := scheduler["columns"].([]any)
+	owner := slices.Index(columns, any("owner_id"))
+	if !assert.GreaterOrEqual(t, owner, 0) {
+		return
+	}
+	scheduler["columns"] = slices.Delete(columns, owner, owner+1)
+	rows, _ := scheduler["rows"].([]any)
+	for i, row := range rows {
+		values, _ := row.([]any)
+		rows[i] = slices.Delete(values, owner, owner+1)
+	}
+
+	restoreBackup(t, legacy)
+	assert.Equal(t, 1, countLinks())
+
+	var orphans int
+	assert.NoError(t, db.Get(&orphans, "SELECT COUNT(*) FROM scheduler WHERE owner_id = 0"))
+	assert.Equal(t, 0, orphans)
+	var tasks int
+	assert.NoError(t, db.Get(&tasks, "SELECT COUNT(*) FROM scheduler"))
+	assert.Equal(t, len(rows), tasks)
+
+	restoreBackup(t, backup)
+	assert.Equal(t, 0, countLinks())
+}
@@ -0,0 +1,123 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func batchResults(m map[string]any) []map[string]any {
+	var results []map[string]any
+	list, _ := m["results"].([]any)
+	for _, item := range list {
+		result, _ := item.(map[string]any)
+		results = append(results, result)
+	}
+	return results
+}
+
+func batchStatuses(results []map[string]any) []float64 {
+	var statuses []float64
+	for _, result := range results {
+		status, _ := result["status"].(float64)
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+func TestBatch(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	today := time.Now().Format(`20060102`)
+	edited := addTask(t, task{date: today, title: "Пакет: изменить"})
+	done := addTask(t, task{date: today, title: "Пакет: выполнить"})
+	deleted := addTask(t, task{date: today, title: "Пакет: удалить"})
+
+	code, m, err := requestAs(Token, "api/tasks/batch", map[string]any{
+		"operations": []map[string]any{
+			{"op": "create", "task": map[string]any{"date": today, "title": "Пакет: новая"}},
+			{"op": "update", "id": edited, "version": "1", "task": map[string]any{"comment": "изменено"}},
+			{"op": "done", "id": done, "version": "1"},
+			{"op": "delete", "id": deleted, "version": "1"},
+		},
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, true, m["committed"])
+	results := batchResults(m)
+	assert.Equal(t, []float64{201, 200, 204, 204}, batchStatuses(results))
+	if assert.Len(t, results, 4) {
+		assert.NotEmpty(t, results[0]["id"])
+		updated, _ := results[1]["task"].(map[string]any)
+		assert.Equal(t, "изменено", updated["comment"])
+		assert.Equal(t, "2", updated["version"])
+	}
+	notFoundTask(t, done)
+	notFoundTask(t, deleted)
+
+	code, m, err = requestAs(Token, "api/tasks/batch", map[string]any{
+		"operations": []map[string]any{
+			{"op": "create", "task": map[string]any{"date": today, "title": "Пакет: откат"}},
+			{"op": "update", "id": edited, "version": "2", "task": map[string]any{"title": "Пакет: откат"}},
+			{"op": "delete", "id": "999999999", "version": "1"},
+			{"op": "delete", "id": edited, "version": "3"},
+		},
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, code)
+	assert.Equal(t, false, m["committed"])
+	results = batchResults(m)
+	assert.Equal(t, []float64{201, 200, 404, 424}, batchStatuses(results))
+	if assert.Len(t, results, 4) {
+		assert.Nil(t, results[0]["id"])
+		failed, _ := results[2]["error"].(map[string]any)
+		assert.Equal(t, "task_not_found", failed["code"])
+		aborted, _ := results[3]["error"].(map[string]any)
+		assert.Equal(t, "batch_aborted", aborted["code"])
+	}
+
+	var count int
+	assert.NoError(t, db.Get(&count, "SELECT COUNT(*) FROM scheduler WHERE title = ?", "Пакет: откат"))
+	assert.Equal(t, 0, count)
+	assert.NoError(t, db.Get(&count, "SELECT COUNT(*) FROM scheduler WHERE id = ? AND version = 2", edited))
+	assert.Equal(t, 1, count)
+
+	code, m, err = requestAs(Token, "api/tasks/batch", map[string]any{
+		"continue_on_error": true,
+		"operations": []map[string]any{
+			{"op": "create", "task": map[string]any{"date": today, "title": "Пакет: первая"}},
+			{"op": "create", "task": map[string]any{"date": today}},
+			{"op": "update", "id": edited, "version": "1", "task": map[string]any{"title": "Пакет: старая версия"}},
+			{"op": "archive", "id": edited},
+			{"op": "create", "task": map[string]any{"date": today, "title": "Пакет: вторая"}},
+			{"op": "delete", "id": edited},
+		},
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, true, m["committed"])
+	results = batchResults(m)
+	assert.Equal(t, []float64{201, 400, 412, 400, 201, 428}, batchStatuses(results))
+	if assert.Len(t, results, 6) {
+		invalid, _ := results[1]["error"].(map[string]any)
+		assert.Equal(t, "required", invalid["code"])
+		conflict, _ := results[2]["error"].(map[string]any)
+		assert.Equal(t, "version_conflict", conflict["code"])
+		current, _ := conflict["current"].(map[string]any)
+		assert.Equal(t, "Пакет: изменить", current["title"])
+		unknown, _ := results[3]["error"].(map[string]any)
+		assert.Equal(t, "op", unknown["field"])
+		unconditional, _ := results[5]["error"].(map[string]any)
+		assert.Equal(t, "precondition_required", unconditional["code"])
+	}
+	assert.NoError(t, db.Get(&count, "SELECT COUNT(*) FROM scheduler WHERE title IN (?, ?)", "Пакет: первая", "Пакет: вторая"))
+	assert.Equal(t, 2, count)
+
+	code, m, err = requestAs(Token, "api/tasks/batch", map[string]any{"operations": []map[string]any{}}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "operations", m["field"])
+}
@@ -0,0 +1,85 @@
+package tests
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func bulk(t *testing.T, query url.Values, values map[string]any) (int, map[string]any) {
+	code, m, err := requestAs(Token, "api/tasks/bulk?"+query.Encode(), values, http.MethodPost)
+	assert.NoError(t, err)
+	return code, m
+}
+
+func TestBulk(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	now := time.Now()
+	today := now.Format(`20060102`)
+	tomorrow := now.AddDate(0, 0, 1).Format(`20060102`)
+
+	report := addTask(t, task{date: today, title: "Больничный: отчёт"})
+	call := addTask(t, task{date: tomorrow, title: "Больничный: звонок"})
+	pills := addTask(t, task{date: today, title: "Больничный: таблетки", repeat: "d 2"})
+
+	dates := func() map[string]string {
+		rows := []struct {
+			ID   string `db:"id"`
+			Date string `db:"date"`
+		}{}
+		assert.NoError(t, db.Select(&rows, "SELECT id, date FROM scheduler WHERE title LIKE 'Больничный%'"))
+		result := map[string]string{}
+		for _, row := range rows {
+			result[row.ID] = row.Date
+		}
+		return result
+	}
+
+	overdue := url.Values{"search": {"Больничный"}, "to": {today}, "dry_run": {"true"}}
+	code, m := bulk(t, overdue, map[string]any{"action": "reschedule", "days": 1})
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, true, m["dry_run"])
+	assert.ElementsMatch(t, []any{report, pills}, m["ids"])
+	assert.Equal(t, map[string]string{report: today, call: tomorrow, pills: today}, dates())
+
+	overdue.Del("dry_run")
+	code, m = bulk(t, overdue, map[string]any{"action": "reschedule", "days": 1})
+	assert.Equal(t, http.StatusOK, code)
+	assert.ElementsMatch(t, []any{report, pills}, m["ids"])
+	assert.Equal(t, map[string]string{report: tomorrow, call: tomorrow, pills: tomorrow}, dates())
+
+	sick := url.Values{"search": {"Больничный"}}
+	code, m = bulk(t, sick, map[string]any{"action": "set", "task": map[string]any{"priority": "A"}})
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, m["ids"], 3)
+	var count int
+	assert.NoError(t, db.Get(&count, "SELECT COUNT(*) FROM scheduler WHERE title LIKE 'Больничный%' AND priority = 'A'"))
+	assert.Equal(t, 3, count)
+
+	code, m = bulk(t, url.Values{"search": {"Больничный"}, "from": {tomorrow}, "to": {tomorrow}}, map[string]any{"action": "done"})
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, m["ids"], 3)
+	assert.Equal(t, map[string]string{pills: now.AddDate(0, 0, 3).Format(`20060102`)}, dates())
+
+	code, m = bulk(t, sick, map[string]any{"action": "delete"})
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []any{pills}, m["ids"])
+	assert.Empty(t, dates())
+
+	code, m = bulk(t, url.Values{}, map[string]any{"action": "delete"})
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "filter_required", m["code"])
+
+	code, m = bulk(t, sick, map[string]any{"action": "archive"})
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "action", m["field"])
+
+	code, m = bulk(t, url.Values{"from": {"01.02.2024"}}, map[string]any{"action": "done"})
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "from", m["field"])
+}
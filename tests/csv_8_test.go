@@ -0,0 +1,219 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func requestRaw(apipath string, data []byte, contentType, method string) (int, []byte, error) {
+	req, err := http.NewRequest(method, getURL(apipath), bytes.NewReader(data))
+	if err != nil {
+		return 0, nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if len(Token) > 0 {
+		req.AddCookie(&http.Cookie{Name: "token", Value: Token})
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	return resp.StatusCode, body, err
+}
+
+func TestImportCSV(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	before, err := count(db)
+	assert.NoError(t, err)
+
+	future := time.Now().AddDate(0, 0, 5).Format(`20060102`)
+	data := "Задача;Срок;Заметки\n" +
+		"Купить молоко;" + future + ";2 литра\n" +
+		";" + future + ";без заголовка\n" +
+		"Оплатить счета;20240230;\n"
+	columns := url.Values{
+		"columns":   {"title:Задача,date:Срок,comment:Заметки"},
+		"delimiter": {";"},
+	}.Encode()
+
+	code, body, err := requestRaw("api/import/csv?"+columns, []byte(data), "text/csv", http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	var report struct {
+		Imported int `json:"imported"`
+		Errors   []struct {
+			Row   int    `json:"row"`
+			Error string `json:"error"`
+		} `json:"errors"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &report))
+	assert.Equal(t, 0, report.Imported)
+	if assert.Len(t, report.Errors, 2) {
+		assert.Equal(t, 3, report.Errors[0].Row)
+		assert.Equal(t, 4, report.Errors[1].Row)
+	}
+
+	data = "Задача;Срок;Заметки\n" +
+		"Купить молоко;" + future + ";2 литра\n" +
+		"Полить цветы;;\n"
+
+	code, body, err = requestRaw("api/import/csv?dry_run=true&"+columns, []byte(data), "text/csv", http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.NoError(t, json.Unmarshal(body, &report))
+	assert.Equal(t, 0, report.Imported)
+	assert.Empty(t, report.Errors)
+
+	after, err := count(db)
+	assert.NoError(t, err)
+	assert.Equal(t, before, after)
+
+	code, body, err = requestRaw("api/import/csv?"+columns, []byte(data), "text/csv", http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.NoError(t, json.Unmarshal(body, &report))
+	assert.Equal(t, 2, report.Imported)
+
+	var task Task
+	err = db.Get(&task, `SELECT * FROM scheduler WHERE title = 'Купить молоко' ORDER BY id DESC LIMIT 1`)
+	assert.NoError(t, err)
+	assert.Equal(t, future, task.Date)
+	assert.Equal(t, "2 литра", task.Comment)
+
+	err = db.Get(&task, `SELECT * FROM scheduler WHERE title = 'Полить цветы' ORDER BY id DESC LIMIT 1`)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Now().Format(`20060102`), task.Date)
+
+	data = "title,priority\nПозвонить врачу,B\nПочистить фильтр,bb\n"
+	code, body, err = requestRaw("api/import/csv", []byte(data), "text/csv", http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.NoError(t, json.Unmarshal(body, &report))
+	if assert.Len(t, report.Errors, 1) {
+		assert.Equal(t, 3, report.Errors[0].Row)
+	}
+
+	data = "title,priority\nПозвонить врачу,B\n"
+	code, body, err = requestRaw("api/import/csv", []byte(data), "text/csv", http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+
+	var ids struct {
+		IDs []int64 `json:"ids"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &ids))
+	if !assert.Len(t, ids.IDs, 1) {
+		return
+	}
+	code, body, err = requestRaw("api/export.csv?columns=id,priority", nil, "", http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, string(body), fmt.Sprintf("%d,B\n", ids.IDs[0]))
+}
+
+func TestExportCSV(t *testing.T) {
+	id := addTask(t, task{
+		title:   "Экспорт, с запятой",
+		comment: `и "кавычками"`,
+		repeat:  "d 7",
+	})
+
+	code, body, err := requestRaw("api/export.csv?delimiter=tab&columns=id,title:Name,repeat", nil, "", http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+
+	reader := csv.NewReader(strings.NewReader(string(body)))
+	reader.Comma = '\t'
+	records, err := reader.ReadAll()
+	assert.NoError(t, err)
+	if !assert.NotEmpty(t, records) {
+		return
+	}
+	assert.Equal(t, []string{"id", "Name", "repeat"}, records[0])
+
+	var found bool
+	for _, record := range records[1:] {
+		if record[0] == id {
+			found = true
+			assert.Equal(t, []string{id, "Экспорт, с запятой", "d 7"}, record)
+		}
+	}
+	assert.True(t, found)
+
+	code, _, err = requestRaw("api/export.csv?columns=id,unknown", nil, "", http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestExportCSVFormulas(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	id := addTask(t, task{
+		title:   `=HYPERLINK("http://example.com","x")`,
+		comment: "@SUM(A1)",
+	})
+
+	code, body, err := requestRaw("api/export.csv?columns=id,title,comment", nil, "", http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+
+	records, err := csv.NewReader(strings.NewReader(string(body))).ReadAll()
+	assert.NoError(t, err)
+	var found []string
+	for _, record := range records {
+		if record[0] == id {
+			found = record
+		}
+	}
+	assert.Equal(t, []string{id, `'=HYPERLINK("http://example.com","x")`, "'@SUM(A1)"}, found)
+
+	data := "title,comment\n" + `"'=HYPERLINK(""http://example.com"",""x"")",'-1` + "\n"
+	code, body, err = requestRaw("api/import/csv", []byte(data), "text/csv", http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+
+	var report struct {
+		IDs []int64 `json:"ids"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &report))
+	if assert.Len(t, report.IDs, 1) {
+		var task Task
+		assert.NoError(t, db.Get(&task, `SELECT * FROM scheduler WHERE id = ?`, report.IDs[0]))
+		assert.Equal(t, `=HYPERLINK("http://example.com","x")`, task.Title)
+		assert.Equal(t, "-1", task.Comment)
+	}
+}
+
+func TestImportCSVLimits(t *testing.T) {
+	data := "title\n" + strings.Repeat("x\n", 10001)
+	code, body, err := requestRaw("api/import/csv?dry_run=true", []byte(data), "text/csv", http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Contains(t, string(body), `"code":"too_many_rows"`)
+
+	data = "title\n" + strings.Repeat("x", 11<<20) + "\n"
+	code, body, err = requestRaw("api/import/csv?dry_run=true", []byte(data), "text/csv", http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, code)
+	assert.Contains(t, string(body), `"code":"payload_too_large"`)
+}
@@ -16,6 +16,12 @@ type Task struct {
 	Title   string `db:"title"`
 	Comment string `db:"comment"`
 	Repeat  string `db:"repeat"`
+
+	Priority   string `db:"priority"`
+	Created    string `db:"created"`
+	OwnerID    int64  `db:"owner_id"`
+	Version    int64  `db:"version"`
+	FieldTimes string `db:"field_times"`
 }
 
 func count(db *sqlx.DB) (int, error) {
@@ -0,0 +1,106 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func requestInLanguage(language, apipath string, values map[string]any, method string) (int, map[string]any, error) {
+	return requestWith(func(req *http.Request) {
+		if len(Token) > 0 {
+			req.AddCookie(&http.Cookie{Name: "token", Value: Token})
+		}
+		req.Header.Set("Accept-Language", language)
+	}, apipath, values, method)
+}
+
+func TestErrorResponses(t *testing.T) {
+	resp, err := http.Get(getURL("api/nextdate?now=bad&date=20240101&repeat=d+1"))
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.True(t, strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json"))
+
+	today := time.Now().Format(`20060102`)
+	code, m, err := requestInLanguage("en-US,en;q=0.9", "api/task", map[string]any{"date": today}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "required", m["code"])
+	assert.Equal(t, "title", m["field"])
+	assert.Equal(t, "the required field title is missing", m["error"])
+
+	code, m, err = requestInLanguage("fr;q=0.8, ru;q=0.5", "api/task", map[string]any{"title": "x", "date": today, "repeat": "q 1"}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "invalid_repeat", m["code"])
+	assert.Equal(t, "repeat", m["field"])
+	assert.Equal(t, "type de règle de répétition invalide", m["error"])
+
+	code, m, err = requestInLanguage("", "api/task?id=abc", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "invalid_number", m["code"])
+	assert.Equal(t, "id", m["field"])
+	assert.Equal(t, "id должен быть числом", m["error"])
+
+	id := addTask(t, task{date: today, title: "Длина заголовка"})
+	code, m, err = requestInLanguage("en", "api/task", map[string]any{
+		"id":      id,
+		"date":    today,
+		"title":   strings.Repeat("x", 256),
+		"comment": "",
+		"repeat":  "",
+		"version": "1",
+	}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "too_long", m["code"])
+	assert.Equal(t, "title", m["field"])
+
+	req, err := http.NewRequest(http.MethodPost, getURL("api/import/csv?dry_run=true"), strings.NewReader("title,date\n,\nx,bad\n"))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "text/csv")
+	req.Header.Set("Accept-Language", "en")
+	if len(Token) > 0 {
+		req.AddCookie(&http.Cookie{Name: "token", Value: Token})
+	}
+	resp, err = http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	var report struct {
+		Error  string `json:"error"`
+		Code   string `json:"code"`
+		Errors []struct {
+			Row   int    `json:"row"`
+			Error string `json:"error"`
+			Code  string `json:"code"`
+			Field string `json:"field"`
+		} `json:"errors"`
+	}
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&report))
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, "import_failed", report.Code)
+	assert.Equal(t, "errors found in rows: 2", report.Error)
+	if assert.Len(t, report.Errors, 2) {
+		assert.Equal(t, "required", report.Errors[0].Code)
+		assert.Equal(t, "title", report.Errors[0].Field)
+		assert.Equal(t, "the required field title is missing", report.Errors[0].Error)
+		assert.Equal(t, "invalid_format", report.Errors[1].Code)
+		assert.Equal(t, "date", report.Errors[1].Field)
+	}
+
+	if len(Token) > 0 {
+		code, m, err = requestWith(func(req *http.Request) {
+			req.Header.Set("Accept-Language", "fr")
+		}, "api/tasks", nil, http.MethodGet)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, code)
+		assert.Equal(t, "authentication_required", m["code"])
+		assert.Equal(t, "authentification requise", m["error"])
+	}
+}
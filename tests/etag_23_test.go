@@ -0,0 +1,142 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/antonkazachenko/go-todo-list-api/config"
+	"github.com/antonkazachenko/go-todo-list-api/internal/entities"
+	storage "github.com/antonkazachenko/go-todo-list-api/internal/storage/sqlite"
+	"github.com/stretchr/testify/assert"
+)
+
+func requestConditional(apipath, method, header, etag string, values map[string]any) (int, map[string]any, string) {
+	var data []byte
+	if len(values) > 0 {
+		data, _ = json.Marshal(values)
+	}
+	req, err := http.NewRequest(method, getURL(apipath), bytes.NewReader(data))
+	if err != nil {
+		return 0, nil, ""
+	}
+	if len(Token) > 0 {
+		req.AddCookie(&http.Cookie{Name: "token", Value: Token})
+	}
+	if etag != "" {
+		req.Header.Set(header, etag)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, nil, ""
+	}
+	defer resp.Body.Close()
+
+	var m map[string]any
+	json.NewDecoder(resp.Body).Decode(&m)
+	return resp.StatusCode, m, resp.Header.Get("ETag")
+}
+
+// currentVersion returns the version a write to the task has to name.
+func currentVersion(t *testing.T, id string) string {
+	db := openDB(t)
+	defer db.Close()
+
+	var version int64
+	assert.NoError(t, db.Get(&version, `SELECT version FROM scheduler WHERE id = ?`, id))
+	return strconv.FormatInt(version, 10)
+}
+
+// deleteTask removes a task whatever its version, to clean up after a test.
+func deleteTask(token, id string) {
+	requestWith(func(req *http.Request) {
+		req.AddCookie(&http.Cookie{Name: "token", Value: token})
+		req.Header.Set("If-Match", "*")
+	}, "api/task?id="+id, nil, http.MethodDelete)
+}
+
+func TestTaskVersions(t *testing.T) {
+	today := time.Now().Format(`20060102`)
+	id := addTask(t, task{date: today, title: "Версии"})
+
+	code, m, etag := requestConditional("api/task?id="+id, http.MethodGet, "", "", nil)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "1", m["version"])
+	assert.Equal(t, `"1"`, etag)
+
+	code, _, _ = requestConditional("api/task?id="+id, http.MethodGet, "If-None-Match", etag, nil)
+	assert.Equal(t, http.StatusNotModified, code)
+
+	update := map[string]any{"id": id, "date": today, "title": "Первая вкладка", "comment": "", "repeat": ""}
+	code, _, _ = requestConditional("api/task", http.MethodPut, "If-Match", etag, update)
+	assert.Equal(t, http.StatusOK, code)
+
+	update["title"] = "Вторая вкладка"
+	code, m, _ = requestConditional("api/task", http.MethodPut, "If-Match", etag, update)
+	assert.Equal(t, http.StatusPreconditionFailed, code)
+	assert.Equal(t, "version_conflict", m["code"])
+	current, _ := m["current"].(map[string]any)
+	assert.Equal(t, "Первая вкладка", current["title"])
+	assert.Equal(t, "2", current["version"])
+
+	update["version"] = "2"
+	code, _, _ = requestConditional("api/task", http.MethodPut, "", "", update)
+	assert.Equal(t, http.StatusOK, code)
+
+	code, _, _ = requestConditional("api/task/done?id="+id, http.MethodPost, "If-Match", `"2"`, nil)
+	assert.Equal(t, http.StatusPreconditionFailed, code)
+	code, _, _ = requestConditional("api/task?id="+id+"&version=2", http.MethodDelete, "", "", nil)
+	assert.Equal(t, http.StatusPreconditionFailed, code)
+
+	delete(update, "version")
+	code, m, _ = requestConditional("api/task", http.MethodPut, "", "", update)
+	assert.Equal(t, http.StatusPreconditionRequired, code)
+	assert.Equal(t, "precondition_required", m["code"])
+	code, _, _ = requestConditional("api/task/done?id="+id, http.MethodPost, "", "", nil)
+	assert.Equal(t, http.StatusPreconditionRequired, code)
+	code, _, _ = requestConditional("api/task?id="+id, http.MethodDelete, "", "", nil)
+	assert.Equal(t, http.StatusPreconditionRequired, code)
+
+	code, _, listETag := requestConditional("api/tasks", http.MethodGet, "", "", nil)
+	assert.Equal(t, http.StatusOK, code)
+	assert.NotEmpty(t, listETag)
+	code, _, _ = requestConditional("api/tasks", http.MethodGet, "If-None-Match", listETag, nil)
+	assert.Equal(t, http.StatusNotModified, code)
+
+	code, _, _ = requestConditional("api/task?id="+id, http.MethodDelete, "If-Match", `"3"`, nil)
+	assert.Equal(t, http.StatusOK, code)
+	code, _, _ = requestConditional("api/tasks", http.MethodGet, "If-None-Match", listETag, nil)
+	assert.Equal(t, http.StatusOK, code)
+}
+
+func TestChangeMissingTask(t *testing.T) {
+	config.TODO_DBFILE = filepath.Join(t.TempDir(), "missing.db")
+	db := storage.InitDB()
+	defer db.Close()
+	repo := storage.NewSQLiteTaskRepository(db)
+
+	id, err := repo.AddTask(1, entities.Task{Date: "20240101", Title: "Gone"}, entities.Actor{})
+	assert.NoError(t, err)
+	taskID := strconv.FormatInt(id, 10)
+	_, err = repo.DeleteTask(1, taskID, "", entities.Actor{})
+	assert.NoError(t, err)
+
+	// A change to a task that is no longer there does not succeed: without
+	// a version it is not found, with one the task is not at that version.
+	title := "Back"
+	for version, want := range map[string]error{"": storage.ErrTaskNotFound, "1": storage.ErrVersionConflict} {
+		_, err = repo.UpdateTask(1, taskID, entities.TaskPatch{Title: &title}, version, entities.Actor{})
+		assert.ErrorIs(t, err, want)
+		_, err = repo.DeleteTask(1, taskID, version, entities.Actor{})
+		assert.ErrorIs(t, err, want)
+		_, err = repo.CompleteTask(1, taskID, version, entities.Actor{})
+		assert.ErrorIs(t, err, want)
+		err = repo.MarkTaskAsDone(1, taskID, "20240102", version, entities.Actor{})
+		assert.ErrorIs(t, err, want)
+	}
+}
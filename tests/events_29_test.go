@@ -0,0 +1,164 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type sseEvent struct {
+	ID   string
+	Type string
+	Data map[string]any
+}
+
+// openEvents connects to the event stream and returns the events that have
+// a type; the stream is closed when the test ends.
+func openEvents(t *testing.T, lastID string) (int, <-chan sseEvent) {
+	return openEventsAs(t, Token, lastID)
+}
+
+func openEventsAs(t *testing.T, token, lastID string) (int, <-chan sseEvent) {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, getURL("api/events"), nil)
+	require.NoError(t, err)
+	req.AddCookie(&http.Cookie{Name: "token", Value: token})
+	if lastID != "" {
+		req.Header.Set("Last-Event-ID", lastID)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return resp.StatusCode, nil
+	}
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	events := make(chan sseEvent)
+	go func() {
+		defer resp.Body.Close()
+		defer close(events)
+
+		var event sseEvent
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case strings.HasPrefix(line, "id: "):
+				event.ID = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "event: "):
+				event.Type = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event.Data)
+			case line == "":
+				if event.Type != "" {
+					select {
+					case events <- event:
+					case <-ctx.Done():
+						return
+					}
+				}
+				event = sseEvent{}
+			}
+		}
+	}()
+	return resp.StatusCode, events
+}
+
+func nextEvent(t *testing.T, events <-chan sseEvent, match func(sseEvent) bool) sseEvent {
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case event, ok := <-events:
+			require.True(t, ok, "event stream closed")
+			if match(event) {
+				return event
+			}
+		case <-timeout:
+			require.FailNow(t, "no matching event")
+		}
+	}
+}
+
+func TestEvents(t *testing.T) {
+	code, events := openEvents(t, "")
+	require.Equal(t, http.StatusOK, code)
+
+	id := addTask(t, task{date: time.Now().Format(`20060102`), title: "События: новая"})
+	forTask := func(eventType string) func(sseEvent) bool {
+		return func(event sseEvent) bool {
+			return event.Type == eventType && event.Data["task_id"] == id
+		}
+	}
+
+	created := nextEvent(t, events, forTask("created"))
+	createdTask, _ := created.Data["task"].(map[string]any)
+	assert.Equal(t, "События: новая", createdTask["title"])
+
+	code, _, err := requestAs(Token, "api/task?id="+id+"&version=1", nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	deleted := nextEvent(t, events, forTask("deleted"))
+	assert.Equal(t, true, deleted.Data["removed"])
+
+	code, replay := openEvents(t, created.ID)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, deleted.ID, nextEvent(t, replay, forTask("deleted")).ID)
+
+	code, replay = openEvents(t, "999999999")
+	require.Equal(t, http.StatusOK, code)
+	nextEvent(t, replay, func(event sseEvent) bool { return event.Type == "reset" })
+
+	code, _ = openEvents(t, "abc")
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestEventsSessionRevoked(t *testing.T) {
+	login := fmt.Sprintf("sse%d", time.Now().UnixNano())
+	session, userID := signUp(t, login)
+	defer requestAs(Token, "api/users?id="+userID, nil, http.MethodDelete)
+
+	code, m, err := requestAs(session, "api/sessions", nil, http.MethodGet)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, code)
+	sessions, _ := m["sessions"].([]any)
+	require.Len(t, sessions, 1)
+	current, _ := sessions[0].(map[string]any)
+
+	code, events := openEventsAs(t, session, "")
+	require.Equal(t, http.StatusOK, code)
+
+	other, _ := signInWith(t, map[string]any{"login": login, "password": "password-" + login})
+	code, _, err = requestAs(other, "api/sessions?id="+fmt.Sprint(current["id"]), nil, http.MethodDelete)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, code)
+
+	time.Sleep(1100 * time.Millisecond)
+	code, _, err = requestAs(other, "api/task", map[string]any{"title": "После отзыва", "date": time.Now().Format(`20060102`)}, http.MethodPost)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, code)
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			assert.NotEqual(t, "created", event.Type)
+		case <-timeout:
+			require.FailNow(t, "event stream not closed")
+		}
+	}
+}
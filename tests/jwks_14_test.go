@@ -0,0 +1,113 @@
+package tests
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jwk) publicKey(t *testing.T) interface{} {
+	decode := func(s string) []byte {
+		b, err := base64.RawURLEncoding.DecodeString(s)
+		assert.NoError(t, err)
+		return b
+	}
+
+	switch k.Kty {
+	case "RSA":
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(decode(k.N)), E: int(new(big.Int).SetBytes(decode(k.E)).Int64())}
+	case "EC":
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(decode(k.X)), Y: new(big.Int).SetBytes(decode(k.Y))}
+	case "OKP":
+		return ed25519.PublicKey(decode(k.X))
+	}
+	t.Fatalf("unexpected key type %s", k.Kty)
+	return nil
+}
+
+func getJWKS(t *testing.T) map[string]jwk {
+	code, body, err := requestRaw(".well-known/jwks.json", nil, "", http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &set))
+	assert.NotNil(t, set.Keys)
+
+	keys := map[string]jwk{}
+	for _, key := range set.Keys {
+		assert.NotEmpty(t, key.Kid)
+		assert.False(t, key.N == "" && key.X == "", "public key material expected")
+		keys[key.Kid] = key
+	}
+	return keys
+}
+
+func TestJWKS(t *testing.T) {
+	keys := getJWKS(t)
+	token, _ := signIn(t)
+
+	parsed, err := jwt.Parse(token, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		if !assert.NotEmpty(t, kid) {
+			return nil, fmt.Errorf("no kid")
+		}
+		if key, ok := keys[kid]; ok {
+			assert.Equal(t, key.Alg, token.Method.Alg())
+			return key.publicKey(t), nil
+		}
+		assert.Equal(t, jwt.SigningMethodHS256.Alg(), token.Method.Alg())
+		return []byte(jwtSecret()), nil
+	})
+	assert.NoError(t, err)
+	assert.True(t, parsed.Valid)
+
+	claims := jwt.MapClaims{
+		"sub": "1",
+		"jti": fmt.Sprintf("test-%d", time.Now().UnixNano()),
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	unknown := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	unknown.Header["kid"] = "unknown"
+	unknownToken, err := unknown.SignedString([]byte(jwtSecret()))
+	assert.NoError(t, err)
+
+	code, _, err := requestAs(unknownToken, "api/tasks", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	for kid, key := range keys {
+		confused := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		confused.Header["kid"] = kid
+		confusedToken, err := confused.SignedString([]byte(key.N + key.X))
+		assert.NoError(t, err)
+
+		code, _, err = requestAs(confusedToken, "api/tasks", nil, http.MethodGet)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusUnauthorized, code)
+	}
+}
@@ -0,0 +1,141 @@
+package tests
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getPage(t *testing.T, path string, form url.Values) (int, string) {
+	var resp *http.Response
+	var err error
+	if form == nil {
+		resp, err = http.Get(getURL(path))
+	} else {
+		resp, err = http.PostForm(getURL(path), form)
+	}
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+	assert.Contains(t, resp.Header.Get("Content-Type"), "text/html")
+	assert.Equal(t, "no-store", resp.Header.Get("Cache-Control"))
+	return resp.StatusCode, string(body)
+}
+
+func publicTitles(t *testing.T, m map[string]any) []string {
+	tasks, _ := m["tasks"].([]any)
+	titles := []string{}
+	for _, task := range tasks {
+		titles = append(titles, fmt.Sprint(task.(map[string]any)["title"]))
+	}
+	return titles
+}
+
+func TestShareLinks(t *testing.T) {
+	suffix := time.Now().Format("150405.000000")
+	alice, aliceID := signUp(t, "alice-link-"+suffix)
+	bob, bobID := signUp(t, "bob-link-"+suffix)
+	defer func() {
+		for _, id := range []string{aliceID, bobID} {
+			requestAs(Token, "api/users?id="+id, nil, http.MethodDelete)
+		}
+	}()
+
+	ids := map[string]string{}
+	for _, title := range []string{"Купить молоко", "Сдать отчёт", "Позвонить маме"} {
+		code, m, err := requestAs(alice, "api/task", map[string]any{"title": title}, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, code)
+		ids[title] = fmt.Sprint(m["id"])
+	}
+
+	createLink := func(values map[string]any) map[string]any {
+		code, m, err := requestAs(alice, "api/links", values, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, code)
+		assert.NotEmpty(t, m["token"])
+		assert.Equal(t, "/s/"+fmt.Sprint(m["token"]), m["url"])
+		return m
+	}
+
+	filtered := createLink(map[string]any{"name": "Покупки", "filter": "молоко"})
+	code, m, err := requestAs("", "api/public/"+fmt.Sprint(filtered["token"]), nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "Покупки", m["name"])
+	assert.Equal(t, []string{"Купить молоко"}, publicTitles(t, m))
+
+	code, page := getPage(t, strings.TrimPrefix(fmt.Sprint(filtered["url"]), "/"), nil)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, page, "Купить молоко")
+	assert.NotContains(t, page, "Сдать отчёт")
+
+	selected := createLink(map[string]any{"task_ids": []string{ids["Сдать отчёт"], ids["Позвонить маме"]}})
+	code, m, err = requestAs("", "api/public/"+fmt.Sprint(selected["token"]), nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.ElementsMatch(t, []string{"Сдать отчёт", "Позвонить маме"}, publicTitles(t, m))
+
+	code, _, err = requestAs(bob, "api/links", map[string]any{"task_ids": []string{ids["Сдать отчёт"]}}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	protected := createLink(map[string]any{"filter": "маме", "password": "секрет", "expires_in_days": 7})
+	assert.Equal(t, true, protected["has_password"])
+	assert.NotNil(t, protected["expires"])
+	publicPath := "api/public/" + fmt.Sprint(protected["token"])
+
+	code, _, err = requestAs("", publicPath, nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	code, _, err = requestAs("", publicPath, map[string]any{"password": "не тот"}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	code, m, err = requestAs("", publicPath, map[string]any{"password": "секрет"}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []string{"Позвонить маме"}, publicTitles(t, m))
+
+	pagePath := strings.TrimPrefix(fmt.Sprint(protected["url"]), "/")
+	code, page = getPage(t, pagePath, nil)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	assert.Contains(t, page, `type="password"`)
+	assert.NotContains(t, page, "Позвонить маме")
+	code, page = getPage(t, pagePath, url.Values{"password": {"секрет"}})
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, page, "Позвонить маме")
+
+	code, m, err = requestAs(alice, "api/links", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	links, _ := m["links"].([]any)
+	assert.Len(t, links, 3)
+
+	linkID := fmt.Sprint(filtered["id"])
+	code, _, err = requestAs(bob, "api/links?id="+linkID, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, code)
+	code, _, err = requestAs(alice, "api/links?id="+linkID, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+
+	code, _, err = requestAs("", "api/public/"+fmt.Sprint(filtered["token"]), nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, code)
+	code, _ = getPage(t, "s/unknown-token", nil)
+	assert.Equal(t, http.StatusNotFound, code)
+
+	code, _, err = requestAs("", "api/public/"+fmt.Sprint(selected["token"]), nil, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusMethodNotAllowed, code)
+}
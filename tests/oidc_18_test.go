@@ -0,0 +1,322 @@
+package tests
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/antonkazachenko/go-todo-list-api/config"
+	"github.com/antonkazachenko/go-todo-list-api/internal/service"
+	storage "github.com/antonkazachenko/go-todo-list-api/internal/storage/sqlite"
+	"github.com/antonkazachenko/go-todo-list-api/routes"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+type oidcGrant struct {
+	challenge   string
+	nonce       string
+	redirectURI string
+}
+
+// stubProvider is a minimal OpenID provider that approves every request for
+// the current subject.
+type stubProvider struct {
+	*httptest.Server
+	key      *rsa.PrivateKey
+	clientID string
+	secret   string
+
+	mu      sync.Mutex
+	subject string
+	grants  map[string]oidcGrant
+}
+
+func newStubProvider(t *testing.T) *stubProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	p := &stubProvider{key: key, clientID: "todo", secret: "s3cret", grants: map[string]oidcGrant{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 p.URL,
+			"authorization_endpoint": p.URL + "/authorize",
+			"token_endpoint":         p.URL + "/token",
+			"jwks_uri":               p.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/authorize", p.authorize)
+	mux.HandleFunc("/token", p.token)
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "stub",
+			"alg": "RS256",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	p.Server = httptest.NewServer(mux)
+	return p
+}
+
+func (p *stubProvider) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("client_id") != p.clientID || q.Get("response_type") != "code" || q.Get("code_challenge_method") != "S256" {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	code := fmt.Sprintf("code-%d", time.Now().UnixNano())
+	p.mu.Lock()
+	p.grants[code] = oidcGrant{challenge: q.Get("code_challenge"), nonce: q.Get("nonce"), redirectURI: q.Get("redirect_uri")}
+	p.mu.Unlock()
+
+	http.Redirect(w, r, q.Get("redirect_uri")+"?"+url.Values{"code": {code}, "state": {q.Get("state")}}.Encode(), http.StatusFound)
+}
+
+func (p *stubProvider) token(w http.ResponseWriter, r *http.Request) {
+	id, secret, ok := r.BasicAuth()
+	if !ok || id != p.clientID || secret != p.secret {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	p.mu.Lock()
+	grant, found := p.grants[r.PostFormValue("code")]
+	delete(p.grants, r.PostFormValue("code"))
+	subject := p.subject
+	p.mu.Unlock()
+
+	verifier := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+	if !found || grant.redirectURI != r.PostFormValue("redirect_uri") ||
+		base64.RawURLEncoding.EncodeToString(verifier[:]) != grant.challenge {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":                p.URL,
+		"sub":                subject,
+		"aud":                p.clientID,
+		"iat":                time.Now().Unix(),
+		"exp":                time.Now().Add(time.Minute).Unix(),
+		"nonce":              grant.nonce,
+		"email":              subject + "@example.com",
+		"preferred_username": "alice",
+	})
+	idToken.Header["kid"] = "stub"
+	signed, _ := idToken.SignedString(p.key)
+	json.NewEncoder(w).Encode(map[string]string{"access_token": "opaque", "token_type": "Bearer", "id_token": signed})
+}
+
+func startOIDCApp(t *testing.T, provider *stubProvider) *httptest.Server {
+	config.TODO_DBFILE = filepath.Join(t.TempDir(), "oidc.db")
+	db := storage.InitDB()
+	t.Cleanup(func() { db.Close() })
+
+	keyring, err := service.LoadKeyring(nil, "oidc-test-secret")
+	assert.NoError(t, err)
+
+	userService := service.NewUserService(storage.NewSQLiteUserRepository(db), "", nil)
+	userService.ExternalAuth = true
+	_, err = userService.EnsureAdmin()
+	assert.NoError(t, err)
+
+	oidcService := service.NewOIDCService(service.OIDCConfig{
+		Issuer:       provider.URL,
+		ClientID:     provider.clientID,
+		ClientSecret: provider.secret,
+		Admins:       []string{"boss@example.com"},
+	}, userService)
+
+	router := routes.RegisterRoutes(
+		service.NewTaskService(storage.NewSQLiteTaskRepository(db), storage.NewSQLiteShareRepository(db)),
+		service.NewBackupService(storage.NewSQLiteBackupRepository(db)),
+		userService,
+		service.NewTokenService(storage.NewSQLiteSessionRepository(db), keyring, time.Minute, time.Hour),
+		service.NewAccessTokenService(storage.NewSQLiteAccessTokenRepository(db)),
+		oidcService,
+		service.NewShareLinkService(storage.NewSQLiteShareLinkRepository(db), storage.NewSQLiteTaskRepository(db), nil),
+		service.NewAuditService(storage.NewSQLiteAuditRepository(db)),
+		service.NewWebhookService(storage.NewSQLiteWebhookRepository(db), nil, 1, time.Second),
+	)
+	app := httptest.NewServer(router)
+	t.Cleanup(app.Close)
+	return app
+}
+
+func noRedirectClient(t *testing.T) *http.Client {
+	jar, err := cookiejar.New(nil)
+	assert.NoError(t, err)
+	return &http.Client{Jar: jar, CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+}
+
+func step(t *testing.T, client *http.Client, location string) *http.Response {
+	resp, err := client.Get(location)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	resp.Body.Close()
+	return resp
+}
+
+// oidcCallback runs a sign-in through the provider and returns the URL
+// fragment the callback redirects the browser to.
+func oidcCallback(t *testing.T, app *httptest.Server, subject string, provider *stubProvider) url.Values {
+	provider.mu.Lock()
+	provider.subject = subject
+	provider.mu.Unlock()
+
+	client := noRedirectClient(t)
+	resp := step(t, client, app.URL+"/api/signin/oidc")
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+	resp = step(t, client, resp.Header.Get("Location"))
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+	resp = step(t, client, resp.Header.Get("Location"))
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	assert.NoError(t, err)
+	assert.Equal(t, "/login.html", location.Path)
+	for _, cookie := range resp.Cookies() {
+		assert.NotEqual(t, "token", cookie.Name)
+	}
+	fragment, err := url.ParseQuery(location.Fragment)
+	assert.NoError(t, err)
+	return fragment
+}
+
+func oidcRequest(t *testing.T, app *httptest.Server, token, method, path string, body any) (int, map[string]any) {
+	var data []byte
+	if body != nil {
+		var err error
+		data, err = json.Marshal(body)
+		assert.NoError(t, err)
+	}
+	req, err := http.NewRequest(method, app.URL+path, bytes.NewReader(data))
+	assert.NoError(t, err)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer resp.Body.Close()
+
+	var m map[string]any
+	json.NewDecoder(resp.Body).Decode(&m)
+	return resp.StatusCode, m
+}
+
+func oidcSignIn(t *testing.T, app *httptest.Server, subject string, provider *stubProvider) (url.Values, map[string]any) {
+	fragment := oidcCallback(t, app, subject, provider)
+	assert.NotEmpty(t, fragment.Get("token"))
+	assert.NotEmpty(t, fragment.Get("refresh_token"))
+
+	code, me := oidcRequest(t, app, fragment.Get("token"), http.MethodGet, "/api/me", nil)
+	assert.Equal(t, http.StatusOK, code)
+	return fragment, me
+}
+
+func TestOIDC(t *testing.T) {
+	provider := newStubProvider(t)
+	defer provider.Close()
+	app := startOIDCApp(t, provider)
+
+	tokens, first := oidcSignIn(t, app, "user-1", provider)
+	assert.Equal(t, "alice", first["login"])
+	assert.Equal(t, "user", first["role"])
+
+	code, refreshed := oidcRequest(t, app, "", http.MethodPost, "/api/refresh", map[string]any{"refresh_token": tokens.Get("refresh_token")})
+	assert.Equal(t, http.StatusOK, code)
+	assert.NotEmpty(t, refreshed["token"])
+
+	_, again := oidcSignIn(t, app, "user-1", provider)
+	assert.Equal(t, first["id"], again["id"])
+
+	_, other := oidcSignIn(t, app, "user-2", provider)
+	assert.NotEqual(t, first["id"], other["id"])
+	assert.Equal(t, "alice-2", other["login"])
+
+	_, boss := oidcSignIn(t, app, "boss", provider)
+	assert.Equal(t, "admin", boss["role"])
+
+	resp, err := http.Post(app.URL+"/api/signin", "application/json", nil)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.NotEqual(t, http.StatusOK, resp.StatusCode)
+
+	client := noRedirectClient(t)
+	resp = step(t, client, app.URL+"/api/signin/oidc/callback?code=forged&state=forged")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	victim := noRedirectClient(t)
+	resp = step(t, victim, app.URL+"/api/signin/oidc")
+	victimState, err := url.Parse(resp.Header.Get("Location"))
+	assert.NoError(t, err)
+
+	attacker := noRedirectClient(t)
+	resp = step(t, attacker, app.URL+"/api/signin/oidc")
+	resp = step(t, attacker, resp.Header.Get("Location"))
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	assert.NoError(t, err)
+
+	injected := callback.Query()
+	injected.Set("state", victimState.Query().Get("state"))
+	resp = step(t, victim, app.URL+"/api/signin/oidc/callback?"+injected.Encode())
+	assert.NotEqual(t, http.StatusFound, resp.StatusCode)
+}
+
+func TestOIDCSecondFactor(t *testing.T) {
+	provider := newStubProvider(t)
+	defer provider.Close()
+	app := startOIDCApp(t, provider)
+
+	tokens, _ := oidcSignIn(t, app, "user-1", provider)
+	session := tokens.Get("token")
+	code, m := oidcRequest(t, app, session, http.MethodPost, "/api/2fa/enroll", nil)
+	assert.Equal(t, http.StatusOK, code)
+	secret := fmt.Sprint(m["secret"])
+	code, _ = oidcRequest(t, app, session, http.MethodPost, "/api/2fa/confirm", map[string]any{"code": totp(t, secret, time.Now())})
+	assert.Equal(t, http.StatusOK, code)
+
+	fragment := oidcCallback(t, app, "user-1", provider)
+	assert.Equal(t, "true", fragment.Get("mfa_required"))
+	assert.Empty(t, fragment.Get("token"))
+	assert.Empty(t, fragment.Get("refresh_token"))
+	mfaToken := fragment.Get("mfa_token")
+
+	code, _ = oidcRequest(t, app, mfaToken, http.MethodGet, "/api/me", nil)
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	code, m = oidcRequest(t, app, "", http.MethodPost, "/api/signin/2fa", map[string]any{
+		"mfa_token": mfaToken,
+		"code":      totp(t, secret, time.Now().Add(30*time.Second)),
+	})
+	assert.Equal(t, http.StatusOK, code)
+	assert.NotEmpty(t, m["token"])
+	assert.NotEmpty(t, m["refresh_token"])
+}
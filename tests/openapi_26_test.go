@@ -0,0 +1,65 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/antonkazachenko/go-todo-list-api/routes"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+var routePattern = regexp.MustCompile(`\{(\w+):[^}]*\}`)
+
+func TestOpenAPICoversRoutes(t *testing.T) {
+	router := routes.RegisterRoutes(nil, nil, nil, nil, nil, nil, nil, nil, nil)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/openapi.json", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var spec struct {
+		OpenAPI    string                                `json:"openapi"`
+		Paths      map[string]map[string]json.RawMessage `json:"paths"`
+		Components struct {
+			Schemas         map[string]json.RawMessage `json:"schemas"`
+			SecuritySchemes map[string]json.RawMessage `json:"securitySchemes"`
+		} `json:"components"`
+	}
+	if !assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &spec)) {
+		t.FailNow()
+	}
+	assert.True(t, strings.HasPrefix(spec.OpenAPI, "3."))
+	for _, name := range []string{"Task", "IDResponse", "ErrorResponse"} {
+		assert.Contains(t, spec.Components.Schemas, name)
+	}
+	assert.Contains(t, spec.Components.SecuritySchemes, "cookieAuth")
+	assert.Contains(t, spec.Components.SecuritySchemes, "bearerAuth")
+
+	registered := map[string]bool{}
+	err := chi.Walk(router, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		route = routePattern.ReplaceAllString(route, "{$1}")
+		key := method + " " + route
+		registered[key] = true
+		assert.Contains(t, spec.Paths[route], strings.ToLower(method), "маршрут %s отсутствует в спецификации", key)
+		return nil
+	})
+	assert.NoError(t, err)
+
+	for path, operations := range spec.Paths {
+		for method := range operations {
+			key := strings.ToUpper(method) + " " + path
+			assert.True(t, registered[key], "в спецификации лишний маршрут %s", key)
+		}
+	}
+
+	resp, err := http.Get(getURL("docs.html"))
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+}
@@ -0,0 +1,61 @@
+package tests
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPatchTask(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	today := time.Now().Format(`20060102`)
+	id := addTask(t, task{date: today, title: "Частичное изменение", comment: "Комментарий"})
+
+	code, m, err := requestAs(Token, "api/task", map[string]any{"id": id, "version": "1", "title": "Новый заголовок"}, http.MethodPatch)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "Новый заголовок", m["title"])
+	assert.Equal(t, "Комментарий", m["comment"])
+	assert.Equal(t, "2", m["version"])
+
+	code, m, err = requestAs(Token, "api/task", map[string]any{"id": id, "version": "2", "comment": nil, "priority": "A"}, http.MethodPatch)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Nil(t, m["comment"])
+	assert.Equal(t, "A", m["priority"])
+
+	for _, body := range []map[string]any{
+		{"id": id, "owner_id": 1},
+		{"id": id, "title = 'x', comment": "y"},
+	} {
+		code, m, err = requestAs(Token, "api/task", body, http.MethodPatch)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, code)
+		assert.Equal(t, "unknown_field", m["code"])
+	}
+
+	code, m, err = requestAs(Token, "api/task", map[string]any{"id": id, "title": nil}, http.MethodPatch)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "title", m["field"])
+
+	code, m, err = requestAs(Token, "api/task", map[string]any{"id": id, "date": "завтра"}, http.MethodPatch)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "date", m["field"])
+
+	code, _, err = requestAs(Token, "api/task", map[string]any{"id": id, "version": "3", "date": today, "title": "Полная замена"}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+
+	var task Task
+	err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, id)
+	assert.NoError(t, err)
+	assert.Equal(t, "Полная замена", task.Title)
+	assert.Equal(t, "", task.Priority)
+	assert.Equal(t, int64(4), task.Version)
+}
@@ -0,0 +1,132 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var Token = generateTestToken()
+
+// generateTestToken signs in as the admin, so that the token belongs to a
+// session like any other. Without a password the server needs no token.
+func generateTestToken() string {
+	data, err := json.Marshal(map[string]string{"password": Password})
+	if err != nil {
+		panic(err)
+	}
+
+	resp, err := http.Post(getURL("api/signin"), "application/json", bytes.NewReader(data))
+	if err != nil {
+		return ""
+	}
+	defer resp.Body.Close()
+
+	var m map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&m); err != nil {
+		return ""
+	}
+	token, _ := m["token"].(string)
+	return token
+}
+
+func signIn(t *testing.T) (string, string) {
+	return signInWith(t, map[string]any{"password": Password})
+}
+
+func signInWith(t *testing.T, credentials map[string]any) (string, string) {
+	code, m, err := requestAs("", "api/signin", credentials, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.NotEmpty(t, m["expires_in"])
+	return fmt.Sprint(m["token"]), fmt.Sprint(m["refresh_token"])
+}
+
+func refresh(refreshToken string) (int, map[string]any, error) {
+	return requestAs("", "api/refresh", map[string]any{"refresh_token": refreshToken}, http.MethodPost)
+}
+
+func TestRefreshRotation(t *testing.T) {
+	_, refreshToken := signIn(t)
+
+	code, m, err := refresh(refreshToken)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	rotatedToken := fmt.Sprint(m["token"])
+	rotatedRefresh := fmt.Sprint(m["refresh_token"])
+	assert.NotEqual(t, refreshToken, rotatedRefresh)
+
+	code, _, err = requestAs(rotatedToken, "api/tasks", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+
+	code, _, err = refresh(refreshToken)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	code, _, err = requestAs(rotatedToken, "api/tasks", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	code, _, err = refresh(rotatedRefresh)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, code)
+}
+
+func TestSessions(t *testing.T) {
+	// Revoking every other session must not touch the admin sessions the
+	// other tests use, so this runs as a user of its own.
+	login := "sessions-" + time.Now().Format("150405.000000")
+	_, userID := signUp(t, login)
+	defer requestAs(Token, "api/users?id="+userID, nil, http.MethodDelete)
+
+	credentials := map[string]any{"login": login, "password": "password-" + login}
+	laptop, _ := signInWith(t, credentials)
+	phone, phoneRefresh := signInWith(t, credentials)
+
+	code, m, err := requestAs(laptop, "api/sessions", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+
+	var current, other string
+	for _, v := range m["sessions"].([]any) {
+		session := v.(map[string]any)
+		if session["current"] == true {
+			current = fmt.Sprint(session["id"])
+		} else if other == "" {
+			other = fmt.Sprint(session["id"])
+		}
+	}
+	assert.NotEmpty(t, current)
+	assert.NotEmpty(t, other)
+
+	for _, v := range m["sessions"].([]any) {
+		id := fmt.Sprint(v.(map[string]any)["id"])
+		if id == current {
+			continue
+		}
+		code, _, err = requestAs(laptop, "api/sessions?id="+id, nil, http.MethodDelete)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, code)
+	}
+
+	code, _, err = requestAs(phone, "api/tasks", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	code, _, err = refresh(phoneRefresh)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	code, _, err = requestAs(laptop, "api/signout", nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+
+	code, _, err = requestAs(laptop, "api/tasks", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, code)
+}
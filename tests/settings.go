@@ -1,29 +1,18 @@
 package tests
 
-import (
-	"time"
-
-	"github.com/golang-jwt/jwt/v4"
-)
+import "os"
 
 var Port = 7540
 var DBFile = "../scheduler.db"
 var FullNextDate = true
 var Search = true
-var Token = generateTestToken()
-
-func generateTestToken() string {
-	pass := "test12345"
-
-	token := jwt.New(jwt.SigningMethodHS256)
+var Password = "test12345"
+var JWTSecret = "test-jwt-secret"
+var SignInAttempts = 5
 
-	claims := token.Claims.(jwt.MapClaims)
-	claims["exp"] = time.Now().Add(time.Hour * 24).Unix()
-
-	tokenString, err := token.SignedString([]byte(pass))
-	if err != nil {
-		panic(err)
+func jwtSecret() string {
+	if envSecret := os.Getenv("TODO_JWT_SECRET"); len(envSecret) > 0 {
+		return envSecret
 	}
-
-	return tokenString
+	return JWTSecret
 }
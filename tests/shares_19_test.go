@@ -0,0 +1,212 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShares(t *testing.T) {
+	suffix := time.Now().Format("150405.000000")
+	alice, aliceID := signUp(t, "alice-share-"+suffix)
+	bob, bobID := signUp(t, "bob-share-"+suffix)
+	carol, carolID := signUp(t, "carol-share-"+suffix)
+	owner, err := strconv.ParseInt(aliceID, 10, 64)
+	assert.NoError(t, err)
+	defer func() {
+		for _, id := range []string{aliceID, bobID, carolID} {
+			requestAs(Token, "api/users?id="+id, nil, http.MethodDelete)
+		}
+	}()
+
+	addTask := func(token, path, title string) string {
+		code, m, err := requestAs(token, path, map[string]any{"title": title, "comment": "общий список"}, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, code)
+		return fmt.Sprint(m["id"])
+	}
+	milk := addTask(alice, "api/task", "Купить молоко")
+	report := addTask(alice, "api/task", "Сдать отчёт")
+
+	code, _, err := requestAs(bob, "api/task?id="+milk, nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, code)
+
+	code, m, err := requestAs(alice, "api/shares", map[string]any{"login": "bob-share-" + suffix, "role": "viewer"}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "viewer", m["role"])
+	bobShare := fmt.Sprint(m["id"])
+
+	code, m, err = requestAs(bob, "api/tasks?owner="+aliceID, nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	tasks, _ := m["tasks"].([]any)
+	assert.Len(t, tasks, 2)
+	for _, task := range tasks {
+		assert.Equal(t, "viewer", task.(map[string]any)["permission"])
+	}
+
+	code, m, err = requestAs(bob, "api/task?id="+milk, nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "viewer", m["permission"])
+
+	code, m, err = requestAs(alice, "api/task?id="+milk, nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, "owner", m["permission"])
+
+	update := map[string]any{"id": milk, "version": "1", "title": "Купить молоко и хлеб", "date": time.Now().Format("20060102")}
+	code, _, err = requestAs(bob, "api/task", update, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, code)
+	code, _, err = requestAs(bob, "api/task?id="+milk+"&version=1", nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, code)
+	code, _, err = requestAs(bob, "api/task/done?id="+milk+"&version=1", nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, code)
+	code, _, err = requestAs(bob, "api/task?owner="+aliceID, map[string]any{"title": "Чужая"}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, code)
+	code, _, err = requestAs(bob, "api/shares", map[string]any{"owner": owner, "login": "carol-share-" + suffix, "role": "viewer"}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, code)
+
+	shareID, _ := strconv.ParseInt(bobShare, 10, 64)
+	code, m, err = requestAs(alice, "api/shares", map[string]any{"id": shareID, "role": "editor"}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "editor", m["role"])
+
+	code, _, err = requestAs(bob, "api/task", update, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	shared := addTask(bob, "api/task?owner="+aliceID, "Задача от Боба")
+
+	code, m, err = requestAs(alice, "api/task?id="+shared, nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "Задача от Боба", m["title"])
+
+	code, _, err = requestAs(alice, "api/shares", map[string]any{"login": "carol-share-" + suffix, "role": "viewer", "filter": "молоко"}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+
+	code, m, err = requestAs(carol, "api/tasks?owner="+aliceID, nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	tasks, _ = m["tasks"].([]any)
+	if assert.Len(t, tasks, 1) {
+		assert.Equal(t, milk, tasks[0].(map[string]any)["id"])
+	}
+	code, _, err = requestAs(carol, "api/task?id="+report, nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, code)
+
+	code, _, err = requestAs(alice, "api/shares", map[string]any{"id": shareID, "role": "admin"}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	code, _, err = requestAs(bob, "api/shares", map[string]any{"owner": owner, "login": "carol-share-" + suffix, "role": "editor"}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusConflict, code)
+	code, m, err = requestAs(bob, "api/shares?owner="+aliceID, nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	shares, _ := m["shares"].([]any)
+	assert.Len(t, shares, 2)
+
+	for _, body := range []map[string]any{
+		{"login": "carol-share-" + suffix, "role": "superuser"},
+		{"login": "alice-share-" + suffix, "role": "viewer"},
+	} {
+		code, _, err = requestAs(alice, "api/shares", body, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, code)
+	}
+
+	code, m, err = requestAs(carol, "api/shares", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	shares, _ = m["shares"].([]any)
+	if assert.Len(t, shares, 1) {
+		code, _, err = requestAs(carol, "api/shares?id="+fmt.Sprint(shares[0].(map[string]any)["id"]), nil, http.MethodDelete)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, code)
+	}
+	code, _, err = requestAs(carol, "api/tasks?owner="+aliceID, nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, code)
+
+	code, _, err = requestAs(alice, "api/shares?id="+bobShare, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	code, _, err = requestAs(bob, "api/task?id="+milk, nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, code)
+}
+
+func TestSharesFilteredAdmin(t *testing.T) {
+	suffix := time.Now().Format("150405.000000")
+	alice, aliceID := signUp(t, "alice-narrow-"+suffix)
+	bob, bobID := signUp(t, "bob-narrow-"+suffix)
+	_, carolID := signUp(t, "carol-narrow-"+suffix)
+	owner, err := strconv.ParseInt(aliceID, 10, 64)
+	assert.NoError(t, err)
+	defer func() {
+		for _, id := range []string{aliceID, bobID, carolID} {
+			requestAs(Token, "api/users?id="+id, nil, http.MethodDelete)
+		}
+	}()
+
+	for _, title := range []string{"Купить молоко", "Сдать отчёт"} {
+		code, _, err := requestAs(alice, "api/task", map[string]any{"title": title}, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, code)
+	}
+
+	code, m, err := requestAs(alice, "api/shares", map[string]any{"login": "bob-narrow-" + suffix, "role": "admin", "filter": "молоко"}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	bobShare := m["id"]
+
+	code, _, err = requestAs(bob, "api/shares", map[string]any{"id": bobShare, "filter": ""}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, code)
+	code, _, err = requestAs(bob, "api/shares", map[string]any{"id": bobShare, "role": "editor"}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, code)
+
+	for _, filter := range []string{"", "отчёт", "олок", "01.02.2024"} {
+		body := map[string]any{"owner": owner, "login": "carol-narrow-" + suffix, "role": "viewer", "filter": filter}
+		code, _, err = requestAs(bob, "api/shares", body, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusForbidden, code, filter)
+	}
+
+	code, m, err = requestAs(bob, "api/shares", map[string]any{"owner": owner, "login": "carol-narrow-" + suffix, "role": "viewer", "filter": "купить молоко"}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	carolShare := m["id"]
+
+	code, _, err = requestAs(bob, "api/shares", map[string]any{"id": carolShare, "filter": "купить"}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, code)
+	code, _, err = requestAs(bob, "api/shares", map[string]any{"id": carolShare, "role": "editor"}, http.MethodPut)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+
+	code, m, err = requestAs(bob, "api/tasks?owner="+aliceID, nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	tasks, _ := m["tasks"].([]any)
+	assert.Len(t, tasks, 1)
+
+	code, _, err = requestAs(bob, "api/shares?id="+fmt.Sprint(bobShare), nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+}
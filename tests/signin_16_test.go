@@ -0,0 +1,52 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func postSignIn(t *testing.T, login, password string) *http.Response {
+	data, err := json.Marshal(map[string]any{"login": login, "password": password})
+	assert.NoError(t, err)
+
+	resp, err := http.Post(getURL("api/signin"), "application/json", bytes.NewReader(data))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	resp.Body.Close()
+	return resp
+}
+
+func TestSignInThrottling(t *testing.T) {
+	login := fmt.Sprintf("bf%d", time.Now().UnixNano())
+	signUp(t, login)
+
+	for i := 0; i < SignInAttempts; i++ {
+		resp := postSignIn(t, login, "wrong-password")
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	}
+
+	resp := postSignIn(t, login, "password-"+login)
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	retryAfter, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	assert.NoError(t, err)
+	assert.Greater(t, retryAfter, 0)
+
+	resp = postSignIn(t, "other-"+login, "wrong-password")
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	time.Sleep(time.Duration(retryAfter) * time.Second)
+
+	resp = postSignIn(t, login, "password-"+login)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp = postSignIn(t, login, "wrong-password")
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
@@ -0,0 +1,132 @@
+package tests
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func dialSocket(t *testing.T, token string) (*websocket.Conn, int) {
+	header := http.Header{}
+	if token != "" {
+		header.Set("Cookie", "token="+token)
+	}
+
+	conn, resp, err := websocket.DefaultDialer.Dial(strings.Replace(getURL("api/ws"), "http", "ws", 1), header)
+	if err != nil {
+		require.NotNil(t, resp, err)
+		return nil, resp.StatusCode
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn, resp.StatusCode
+}
+
+// readSocket reads messages until one has the type and ref.
+func readSocket(t *testing.T, conn *websocket.Conn, msgType, ref string) map[string]any {
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	for {
+		var msg map[string]any
+		require.NoError(t, conn.ReadJSON(&msg))
+		if msg["type"] == msgType && msg["ref"] == ref {
+			return msg
+		}
+	}
+}
+
+func TestSocket(t *testing.T) {
+	today := time.Now().Format(`20060102`)
+	old := addTask(t, task{date: today, title: "Сокет: старая"})
+
+	conn, code := dialSocket(t, Token)
+	require.Equal(t, http.StatusSwitchingProtocols, code)
+
+	require.NoError(t, conn.WriteJSON(map[string]any{"type": "subscribe", "ref": "q", "search": "Сокет"}))
+	snapshot := readSocket(t, conn, "snapshot", "q")
+	if assert.Len(t, snapshot["tasks"], 1) {
+		first, _ := snapshot["tasks"].([]any)[0].(map[string]any)
+		assert.Equal(t, old, first["id"])
+	}
+
+	require.NoError(t, conn.WriteJSON(map[string]any{
+		"type": "create", "ref": "c1",
+		"task": map[string]any{"date": today, "title": "Сокет: новая"},
+	}))
+	ack := readSocket(t, conn, "ack", "c1")
+	result, _ := ack["result"].(map[string]any)
+	assert.Equal(t, float64(http.StatusCreated), result["status"])
+	created, _ := result["id"].(string)
+	require.NotEmpty(t, created)
+
+	added := readSocket(t, conn, "add", "q")
+	assert.Equal(t, created, added["id"])
+	addedTask, _ := added["task"].(map[string]any)
+	assert.Equal(t, "Сокет: новая", addedTask["title"])
+
+	code, _, err := requestAs(Token, "api/task", map[string]any{"id": old, "version": "1", "comment": "по HTTP"}, http.MethodPatch)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	updated := readSocket(t, conn, "update", "q")
+	assert.Equal(t, old, updated["id"])
+	updatedTask, _ := updated["task"].(map[string]any)
+	assert.Equal(t, "по HTTP", updatedTask["comment"])
+
+	require.NoError(t, conn.WriteJSON(map[string]any{
+		"type": "update", "ref": "u1", "id": created, "version": "1", "task": map[string]any{"title": "Уже не подходит"},
+	}))
+	ack = readSocket(t, conn, "ack", "u1")
+	result, _ = ack["result"].(map[string]any)
+	assert.Equal(t, float64(http.StatusOK), result["status"])
+	assert.Equal(t, created, readSocket(t, conn, "remove", "q")["id"])
+
+	require.NoError(t, conn.WriteJSON(map[string]any{"type": "done", "ref": "d1", "id": old, "version": "2"}))
+	ack = readSocket(t, conn, "ack", "d1")
+	result, _ = ack["result"].(map[string]any)
+	assert.Equal(t, float64(http.StatusNoContent), result["status"])
+	assert.Equal(t, old, readSocket(t, conn, "remove", "q")["id"])
+
+	require.NoError(t, conn.WriteJSON(map[string]any{"type": "delete", "ref": "d2", "id": "999999999", "version": "1"}))
+	ack = readSocket(t, conn, "ack", "d2")
+	result, _ = ack["result"].(map[string]any)
+	assert.Equal(t, float64(http.StatusNotFound), result["status"])
+
+	require.NoError(t, conn.WriteJSON(map[string]any{"type": "archive", "ref": "x"}))
+	failed, _ := readSocket(t, conn, "error", "x")["error"].(map[string]any)
+	assert.Equal(t, "type", failed["field"])
+
+	deleteTask(Token, created)
+
+	_, code = dialSocket(t, "")
+	assert.Equal(t, http.StatusUnauthorized, code)
+}
+
+func TestSocketSearchLike(t *testing.T) {
+	today := time.Now().Format(`20060102`)
+	search := "LIKE-Лайк_Б"
+
+	conn, code := dialSocket(t, Token)
+	require.Equal(t, http.StatusSwitchingProtocols, code)
+	require.NoError(t, conn.WriteJSON(map[string]any{"type": "subscribe", "ref": "like", "search": search}))
+	readSocket(t, conn, "snapshot", "like")
+
+	other := addTask(t, task{date: today, title: "like-лайк+б"})
+	defer deleteTask(Token, other)
+	matching := addTask(t, task{date: today, title: "like-Лайк+Б"})
+	defer deleteTask(Token, matching)
+
+	assert.Equal(t, matching, readSocket(t, conn, "add", "like")["id"])
+
+	code, m, err := requestAs(Token, "api/tasks?search="+url.QueryEscape(search), nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	tasks, _ := m["tasks"].([]any)
+	if assert.Len(t, tasks, 1) {
+		found, _ := tasks[0].(map[string]any)
+		assert.Equal(t, matching, found["id"])
+	}
+}
@@ -0,0 +1,154 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func syncSince(t *testing.T, token string) map[string]any {
+	code, m, err := requestAs(Token, "api/sync?since="+url.QueryEscape(token), nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	return m
+}
+
+func syncTaskIDs(m map[string]any) []any {
+	var ids []any
+	tasks, _ := m["tasks"].([]any)
+	for _, item := range tasks {
+		task, _ := item.(map[string]any)
+		ids = append(ids, task["id"])
+	}
+	return ids
+}
+
+func TestSync(t *testing.T) {
+	today := time.Now().Format(`20060102`)
+
+	m := syncSince(t, "")
+	assert.Equal(t, true, m["reset"])
+	token, _ := m["token"].(string)
+	assert.NotEmpty(t, token)
+
+	kept := addTask(t, task{date: today, title: "Синхронизация: оставить"})
+	removed := addTask(t, task{date: today, title: "Синхронизация: удалить"})
+
+	m = syncSince(t, token)
+	assert.Equal(t, false, m["reset"])
+	assert.ElementsMatch(t, []any{kept, removed}, syncTaskIDs(m))
+	token, _ = m["token"].(string)
+
+	code, _, err := requestAs(Token, "api/task?id="+removed+"&version="+currentVersion(t, removed), nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+
+	m = syncSince(t, token)
+	assert.Empty(t, syncTaskIDs(m))
+	assert.Equal(t, []any{removed}, m["deleted"])
+	token, _ = m["token"].(string)
+
+	code, _, err = requestAs(Token, "api/task", map[string]any{"id": kept, "version": "1", "title": "Синхронизация: с сервера"}, http.MethodPatch)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+
+	offline := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	ref := fmt.Sprintf("local-%d", time.Now().UnixNano())
+	code, m, err = requestAs(Token, "api/sync", map[string]any{
+		"since": token,
+		"changes": []map[string]any{
+			{"op": "update", "id": kept, "changed": offline, "task": map[string]any{"title": "Синхронизация: офлайн", "comment": "из поезда"}},
+			{"op": "create", "ref": ref, "changed": offline, "task": map[string]any{"date": today, "title": "Синхронизация: новая"}},
+			{"op": "update", "id": removed, "changed": offline, "task": map[string]any{"comment": "поздно"}},
+			{"op": "delete", "id": kept, "changed": offline},
+			{"op": "update", "id": kept, "changed": "вчера", "task": map[string]any{"comment": "?"}},
+		},
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+
+	results := batchResults(m)
+	assert.Equal(t, []float64{200, 201, 404, 409, 400}, batchStatuses(results))
+	if assert.Len(t, results, 5) {
+		merged, _ := results[0]["task"].(map[string]any)
+		assert.Equal(t, "Синхронизация: с сервера", merged["title"])
+		assert.Equal(t, "из поезда", merged["comment"])
+		conflicts, _ := results[0]["conflicts"].([]any)
+		if assert.Len(t, conflicts, 1) {
+			conflict, _ := conflicts[0].(map[string]any)
+			assert.Equal(t, "title", conflict["field"])
+			assert.Equal(t, "Синхронизация: с сервера", conflict["server"])
+			assert.Equal(t, "Синхронизация: офлайн", conflict["client"])
+		}
+
+		assert.Equal(t, ref, results[1]["ref"])
+		created, _ := results[1]["id"].(string)
+		assert.NotEmpty(t, created)
+		assert.ElementsMatch(t, []any{kept, created}, syncTaskIDs(m))
+		deleteTask(Token, created)
+
+		deleteConflicts, _ := results[3]["conflicts"].([]any)
+		if assert.Len(t, deleteConflicts, 1) {
+			conflict, _ := deleteConflicts[0].(map[string]any)
+			assert.Equal(t, "title", conflict["field"])
+			assert.Nil(t, conflict["client"])
+		}
+
+		invalid, _ := results[4]["error"].(map[string]any)
+		assert.Equal(t, "changed", invalid["field"])
+	}
+	code, _, err = requestAs(Token, "api/task?id="+kept, nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+
+	code, m, err = requestAs(Token, "api/sync?since=bad", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, "since", m["field"])
+
+	m = syncSince(t, "c3luYzo5OTk5OTk5OTk")
+	assert.Equal(t, true, m["reset"])
+
+	deleteTask(Token, kept)
+}
+
+func TestSyncRetry(t *testing.T) {
+	today := time.Now().Format(`20060102`)
+	offline := time.Now().Add(-time.Minute).UTC().Format(time.RFC3339)
+	body := map[string]any{
+		"changes": []map[string]any{
+			{"op": "create", "ref": fmt.Sprintf("retry-%d", time.Now().UnixNano()), "changed": offline,
+				"task": map[string]any{"date": today, "title": "Синхронизация: повтор"}},
+		},
+	}
+
+	var ids []string
+	for i := 0; i < 2; i++ {
+		code, m, err := requestAs(Token, "api/sync", body, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, code)
+		results := batchResults(m)
+		assert.Equal(t, []float64{201}, batchStatuses(results))
+		if len(results) == 1 {
+			id, _ := results[0]["id"].(string)
+			ids = append(ids, id)
+		}
+	}
+	if !assert.Len(t, ids, 2) {
+		return
+	}
+	defer requestAs(Token, "api/task?id="+ids[0], nil, http.MethodDelete)
+	assert.NotEmpty(t, ids[0])
+	assert.Equal(t, ids[0], ids[1])
+
+	db := openDB(t)
+	defer db.Close()
+	var count int
+	err := db.Get(&count, "SELECT COUNT(*) FROM scheduler WHERE title = ?", "Синхронизация: повтор")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, count)
+}
@@ -0,0 +1,59 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTodoTxt(t *testing.T) {
+	db := openDB(t)
+	defer db.Close()
+
+	due := time.Now().AddDate(0, 0, 3)
+	data := "(B) 2024-03-01 Позвонить маме +семья @телефон due:" + due.Format("2006-01-02") + " comment:%D0%BD%D0%B5%20%D0%B7%D0%B0%D0%B1%D1%8B%D1%82%D1%8C\n" +
+		"Сделать зарядку @дом rec:w_1,3,5 due:" + due.Format("2006-01-02") + "\n" +
+		"x 2024-03-02 2024-03-01 Старая задача\n"
+
+	code, body, err := requestRaw("api/import/todotxt", []byte(data), "text/plain", http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+
+	var report struct {
+		Imported int     `json:"imported"`
+		Skipped  int     `json:"skipped"`
+		IDs      []int64 `json:"ids"`
+	}
+	assert.NoError(t, json.Unmarshal(body, &report))
+	assert.Equal(t, 2, report.Imported)
+	assert.Equal(t, 1, report.Skipped)
+	if !assert.Len(t, report.IDs, 2) {
+		return
+	}
+
+	var task Task
+	err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, report.IDs[0])
+	assert.NoError(t, err)
+	assert.Equal(t, "Позвонить маме +семья @телефон", task.Title)
+	assert.Equal(t, "не забыть", task.Comment)
+	assert.Equal(t, due.Format(`20060102`), task.Date)
+	assert.Equal(t, "B", task.Priority)
+	assert.Equal(t, "20240301", task.Created)
+
+	err = db.Get(&task, `SELECT * FROM scheduler WHERE id=?`, report.IDs[1])
+	assert.NoError(t, err)
+	assert.Equal(t, "w 1,3,5", task.Repeat)
+
+	code, body, err = requestRaw("api/export.txt", nil, "", http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, strings.Split(string(body), "\n"), strings.TrimSuffix(strings.SplitN(data, "\n", 2)[0], "\n"))
+
+	code, _, err = requestRaw("api/import/todotxt", []byte("Без даты due:2024-02-30\n"), "text/plain", http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, code)
+}
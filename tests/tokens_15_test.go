@@ -0,0 +1,135 @@
+package tests
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func requestBearer(token, apipath string, values map[string]any, method string) (int, map[string]any, error) {
+	return requestWith(func(req *http.Request) {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}, apipath, values, method)
+}
+
+func createAccessToken(t *testing.T, session string, scopes ...string) (string, string) {
+	code, m, err := requestAs(session, "api/tokens", map[string]any{
+		"name":   "ci " + strings.Join(scopes, ","),
+		"scopes": scopes,
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	token := fmt.Sprint(m["token"])
+	assert.True(t, strings.HasPrefix(token, "todo_pat_"))
+	return token, fmt.Sprint(m["id"])
+}
+
+func TestBearerAuth(t *testing.T) {
+	session, _ := signIn(t)
+
+	code, _, err := requestBearer(session, "api/tasks", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+
+	code, _, err = requestBearer("garbage", "api/tasks", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, code)
+}
+
+func TestAccessTokens(t *testing.T) {
+	session, _ := signIn(t)
+
+	readOnly, readOnlyID := createAccessToken(t, session, "tasks:read")
+
+	code, _, err := requestBearer(readOnly, "api/tasks", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+
+	code, _, err = requestBearer(readOnly, "api/task", map[string]any{"title": "Из CI"}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, code)
+
+	code, _, err = requestBearer(readOnly, "api/users", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, code)
+
+	code, _, err = requestBearer(readOnly, "api/tokens", map[string]any{"name": "more", "scopes": []string{"admin"}}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, code)
+
+	writer, _ := createAccessToken(t, session, "tasks:write")
+	code, m, err := requestBearer(writer, "api/task", map[string]any{"title": "Из CI", "date": time.Now().Format(`20060102`)}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	code, _, err = requestBearer(writer, "api/task?id="+fmt.Sprint(m["id"])+"&version=1", nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+
+	admin, _ := createAccessToken(t, session, "admin")
+	code, _, err = requestBearer(admin, "api/users", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+
+	code, _, err = requestAs(session, "api/tokens", map[string]any{"name": "bad", "scopes": []string{"tasks:delete"}}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	userSession, _ := signUp(t, fmt.Sprintf("pat%d", time.Now().UnixNano()))
+	code, _, err = requestAs(userSession, "api/tokens", map[string]any{"name": "admin", "scopes": []string{"admin"}}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, code)
+
+	code, m, err = requestAs(session, "api/tokens", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	var listed bool
+	for _, v := range m["tokens"].([]any) {
+		token := v.(map[string]any)
+		assert.Nil(t, token["token"])
+		if fmt.Sprint(token["id"]) == readOnlyID {
+			listed = true
+			assert.NotNil(t, token["last_used"])
+			assert.Equal(t, []any{"tasks:read"}, token["scopes"])
+		}
+	}
+	assert.True(t, listed)
+
+	code, _, err = requestAs(session, "api/tokens?id="+readOnlyID, nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+
+	code, _, err = requestBearer(readOnly, "api/tasks", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, code)
+}
+
+func TestAccessTokenSessions(t *testing.T) {
+	session, _ := signIn(t)
+	admin, adminID := createAccessToken(t, session, "admin", "tasks:write")
+	defer requestAs(session, "api/tokens?id="+adminID, nil, http.MethodDelete)
+
+	code, m, err := requestAs(session, "api/sessions", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	sessions, _ := m["sessions"].([]any)
+	if !assert.NotEmpty(t, sessions) {
+		return
+	}
+	current, _ := sessions[0].(map[string]any)
+
+	code, _, err = requestBearer(admin, "api/sessions", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, code)
+
+	code, _, err = requestBearer(admin, "api/sessions?id="+fmt.Sprint(current["id"]), nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, code)
+
+	code, _, err = requestAs(session, "api/sessions", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+}
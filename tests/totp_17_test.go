@@ -0,0 +1,104 @@
+package tests
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func totp(t *testing.T, secret string, at time.Time) string {
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	assert.NoError(t, err)
+
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(at.Unix()/30))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%06d", value%1000000)
+}
+
+func TestTOTP(t *testing.T) {
+	login := fmt.Sprintf("totp%d", time.Now().UnixNano())
+	password := "password-" + login
+	session, _ := signUp(t, login)
+
+	code, m, err := requestAs(session, "api/2fa/enroll", nil, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	secret := fmt.Sprint(m["secret"])
+	assert.True(t, strings.HasPrefix(fmt.Sprint(m["otpauth_uri"]), "otpauth://totp/"))
+	assert.Contains(t, m["otpauth_uri"], "secret="+secret)
+
+	code, _, err = requestAs(session, "api/2fa/confirm", map[string]any{"code": "000000"}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, m, err = requestAs(session, "api/2fa/confirm", map[string]any{"code": totp(t, secret, time.Now())}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	recoveryCodes, _ := m["recovery_codes"].([]any)
+	if !assert.Len(t, recoveryCodes, 10) {
+		return
+	}
+
+	code, m, err = requestAs(session, "api/me", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, true, m["totp_enabled"])
+
+	code, m, err = requestAs("", "api/signin", map[string]any{"login": login, "password": password}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, true, m["mfa_required"])
+	assert.Nil(t, m["token"])
+	mfaToken := fmt.Sprint(m["mfa_token"])
+
+	code, _, err = requestAs(mfaToken, "api/tasks", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	code, _, err = requestAs("", "api/signin/2fa", map[string]any{"mfa_token": mfaToken, "code": "12345"}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	next := totp(t, secret, time.Now().Add(30*time.Second))
+	code, m, err = requestAs("", "api/signin/2fa", map[string]any{"mfa_token": mfaToken, "code": next}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.NotEmpty(t, m["token"])
+
+	code, _, err = requestAs("", "api/signin/2fa", map[string]any{"mfa_token": mfaToken, "code": next}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	recovery := fmt.Sprint(recoveryCodes[0])
+	code, m, err = requestAs("", "api/signin", map[string]any{"login": login, "password": password, "code": recovery}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.NotEmpty(t, m["token"])
+
+	code, _, err = requestAs("", "api/signin", map[string]any{"login": login, "password": password, "code": recovery}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	code, _, err = requestAs(session, "api/2fa/disable", map[string]any{"code": fmt.Sprint(recoveryCodes[1])}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+
+	code, m, err = requestAs("", "api/signin", map[string]any{"login": login, "password": password}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.NotEmpty(t, m["token"])
+}
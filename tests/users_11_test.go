@@ -0,0 +1,127 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func requestAs(token, apipath string, values map[string]any, method string) (int, map[string]any, error) {
+	return requestWith(func(req *http.Request) {
+		if len(token) > 0 {
+			req.AddCookie(&http.Cookie{Name: "token", Value: token})
+		}
+	}, apipath, values, method)
+}
+
+func requestWith(authorize func(*http.Request), apipath string, values map[string]any, method string) (int, map[string]any, error) {
+	var data []byte
+	if len(values) > 0 {
+		var err error
+		if data, err = json.Marshal(values); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	req, err := http.NewRequest(method, getURL(apipath), bytes.NewReader(data))
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	authorize(req)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, nil, err
+	}
+
+	var m map[string]any
+	if len(bytes.TrimSpace(body)) > 0 && body[0] == '{' {
+		err = json.Unmarshal(body, &m)
+	}
+	return resp.StatusCode, m, err
+}
+
+func signUp(t *testing.T, login string) (string, string) {
+	code, m, err := requestAs("", "api/signup", map[string]any{
+		"login":    login,
+		"password": "password-" + login,
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	token := fmt.Sprint(m["token"])
+	assert.NotEmpty(t, token)
+
+	code, m, err = requestAs(token, "api/me", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, login, m["login"])
+	return token, fmt.Sprint(m["id"])
+}
+
+func TestUsers(t *testing.T) {
+	suffix := time.Now().Format("150405.000000")
+	alice, aliceID := signUp(t, "alice-"+suffix)
+	bob, bobID := signUp(t, "bob-"+suffix)
+
+	code, m, err := requestAs(alice, "api/task", map[string]any{"title": "Задача Алисы"}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	taskID := fmt.Sprint(m["id"])
+
+	code, m, err = requestAs(bob, "api/task?id="+taskID, nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, code)
+	assert.NotEmpty(t, m["error"])
+
+	code, _, err = requestAs(bob, "api/task?id="+taskID+"&version=1", nil, http.MethodDelete)
+	assert.NoError(t, err)
+	assert.NotEqual(t, http.StatusOK, code)
+
+	code, m, err = requestAs(bob, "api/tasks", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Empty(t, m["tasks"])
+
+	code, m, err = requestAs(alice, "api/task?id="+taskID, nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "Задача Алисы", m["title"])
+
+	code, _, err = requestAs(alice, "api/signin", map[string]any{
+		"login":    "alice-" + suffix,
+		"password": "wrong-password",
+	}, http.MethodPost)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	code, _, err = requestAs(bob, "api/users", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, code)
+
+	code, _, err = requestAs(Token, "api/users", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+
+	for _, id := range []string{aliceID, bobID} {
+		code, _, err = requestAs(Token, "api/users?id="+id, nil, http.MethodDelete)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, code)
+	}
+
+	code, _, err = requestAs(alice, "api/tasks", nil, http.MethodGet)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, code)
+}
@@ -0,0 +1,115 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func requestV2(t *testing.T, method, apipath string, values map[string]any) (*http.Response, map[string]any) {
+	var data []byte
+	if values != nil {
+		data, _ = json.Marshal(values)
+	}
+	req, err := http.NewRequest(method, getURL(apipath), bytes.NewReader(data))
+	assert.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	if len(Token) > 0 {
+		req.AddCookie(&http.Cookie{Name: "token", Value: Token})
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer resp.Body.Close()
+
+	var m map[string]any
+	json.NewDecoder(resp.Body).Decode(&m)
+	return resp, m
+}
+
+func TestTasksV2(t *testing.T) {
+	today := time.Now().Format(`20060102`)
+
+	resp, m := requestV2(t, http.MethodPost, "api/v2/tasks", map[string]any{"title": "Задача v2", "repeat": "d 2"})
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	id, ok := m["id"].(float64)
+	if !assert.True(t, ok, "id должен быть числом") {
+		return
+	}
+	location := fmt.Sprintf("/api/v2/tasks/%d", int64(id))
+	assert.Equal(t, location, resp.Header.Get("Location"))
+	assert.Equal(t, today, m["date"])
+	assert.Equal(t, float64(1), m["version"])
+
+	resp, m = requestV2(t, http.MethodGet, location[1:], nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, id, m["id"])
+	assert.Equal(t, "Задача v2", m["title"])
+
+	resp, m = requestV2(t, http.MethodPatch, location[1:], map[string]any{"comment": "из v2", "version": 1})
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "из v2", m["comment"])
+	assert.Equal(t, float64(2), m["version"])
+
+	resp, m = requestV2(t, http.MethodPut, location[1:], map[string]any{"date": today, "title": "Заменена", "version": 1})
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+	current, _ := m["current"].(map[string]any)
+	assert.Equal(t, id, current["id"])
+
+	resp, m = requestV2(t, http.MethodPost, location[1:]+"/done?version=2", nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, time.Now().AddDate(0, 0, 2).Format(`20060102`), m["date"])
+	version := fmt.Sprint(m["version"])
+
+	resp, m = requestV2(t, http.MethodGet, "api/v2/tasks", nil)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	tasks, _ := m["tasks"].([]any)
+	assert.NotEmpty(t, tasks)
+
+	resp, _ = requestV2(t, http.MethodDelete, location[1:]+"?version="+version, nil)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	for _, method := range []string{http.MethodGet, http.MethodPatch, http.MethodDelete} {
+		resp, m = requestV2(t, method, location[1:]+"?version="+version, map[string]any{"title": "x", "version": version})
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode, method)
+		assert.Equal(t, "task_not_found", m["code"])
+	}
+
+	resp, m = requestV2(t, http.MethodGet, "api/v2/tasks/abc", nil)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Equal(t, "not_found", m["code"])
+
+	resp, m = requestV2(t, http.MethodPost, "api/v2/tasks", map[string]any{"title": "Одноразовая"})
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	resp, _ = requestV2(t, http.MethodPost, fmt.Sprintf("api/v2/tasks/%d/done?version=1", int64(m["id"].(float64))), nil)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+}
+
+func TestTaskV2VersionTypes(t *testing.T) {
+	resp, m := requestV2(t, http.MethodPost, "api/v2/tasks", map[string]any{"title": "Версия v2"})
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	location := fmt.Sprintf("api/v2/tasks/%d", int64(m["id"].(float64)))
+
+	// Only a string or an integer names a version; a float such as 1e6
+	// used to be formatted as "1e+06" and could never match.
+	for _, version := range []any{1.5, json.Number("1e6"), true, []int{1}, map[string]int{"v": 1}} {
+		resp, m = requestV2(t, http.MethodPatch, location, map[string]any{"comment": "x", "version": version})
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode, version)
+		assert.Equal(t, "invalid_value", m["code"], version)
+		assert.Equal(t, "version", m["field"], version)
+	}
+
+	resp, m = requestV2(t, http.MethodPatch, location, map[string]any{"comment": "строкой", "version": "1"})
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, float64(2), m["version"])
+
+	resp, _ = requestV2(t, http.MethodDelete, location+"?version=2", nil)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+}
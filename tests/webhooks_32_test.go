@@ -0,0 +1,266 @@
+package tests
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/antonkazachenko/go-todo-list-api/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type webhookCall struct {
+	header http.Header
+	body   []byte
+	event  map[string]any
+}
+
+// webhookReceiver records the calls it gets. fail decides, per call, whether
+// to answer with an error.
+func webhookReceiver(t *testing.T, fail func(webhookCall) bool) (*httptest.Server, <-chan webhookCall) {
+	calls := make(chan webhookCall, 256)
+	receiver := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		call := webhookCall{header: req.Header.Clone(), body: body}
+		json.Unmarshal(body, &call.event)
+		select {
+		case calls <- call:
+		default:
+		}
+		if fail(call) {
+			res.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		res.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(receiver.Close)
+	return receiver, calls
+}
+
+func nextWebhookCall(t *testing.T, calls <-chan webhookCall, eventType, taskID string) webhookCall {
+	timeout := time.After(15 * time.Second)
+	for {
+		select {
+		case call := <-calls:
+			if call.header.Get("X-Webhook-Event") == eventType && call.event["task_id"] == taskID {
+				return call
+			}
+		case <-timeout:
+			require.FailNow(t, "no webhook call", "%s %s", eventType, taskID)
+		}
+	}
+}
+
+func verifyWebhookSignature(t *testing.T, secret string, call webhookCall) {
+	timestamp := call.header.Get("X-Webhook-Timestamp")
+	require.NotEmpty(t, timestamp)
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(call.body)
+	assert.Equal(t, "sha256="+hex.EncodeToString(mac.Sum(nil)), call.header.Get("X-Webhook-Signature"))
+	assert.Equal(t, "application/json", call.header.Get("Content-Type"))
+	assert.NotEmpty(t, call.header.Get("X-Webhook-Delivery"))
+}
+
+func webhookDeliveries(t *testing.T, hookID string) []map[string]any {
+	code, m, err := requestAs(Token, "api/webhooks/deliveries?id="+hookID, nil, http.MethodGet)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, code)
+
+	var deliveries []map[string]any
+	items, _ := m["deliveries"].([]any)
+	for _, item := range items {
+		delivery, _ := item.(map[string]any)
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries
+}
+
+func TestWebhooks(t *testing.T) {
+	var mu sync.Mutex
+	failed := map[string]bool{}
+	receiver, calls := webhookReceiver(t, func(call webhookCall) bool {
+		task, _ := call.event["task"].(map[string]any)
+		if task["title"] != "Веб-хуки: повтор" || call.event["type"] != "created" {
+			return false
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if failed[fmt.Sprint(call.event["task_id"])] {
+			return false
+		}
+		failed[fmt.Sprint(call.event["task_id"])] = true
+		return true
+	})
+
+	overdueDate := time.Now().AddDate(0, 0, -3).Format(`20060102`)
+	overdue := addTask(t, task{date: time.Now().Format(`20060102`), title: "Веб-хуки: просрочена"})
+	_, err := openDB(t).Exec(`UPDATE scheduler SET date = ? WHERE id = ?`, overdueDate, overdue)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		deleteTask(Token, overdue)
+	})
+
+	for _, body := range []map[string]any{
+		{"events": []string{"created"}},
+		{"url": "ftp://example.com/hook", "events": []string{"created"}},
+		{"url": receiver.URL, "events": []string{"created", "renamed"}},
+		{"url": receiver.URL},
+	} {
+		code, _, err := requestAs(Token, "api/webhooks", body, http.MethodPost)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, code, body)
+	}
+
+	code, m, err := requestAs(Token, "api/webhooks", map[string]any{
+		"url":    receiver.URL + "/hook",
+		"events": []string{"created", "completed", "deleted", "overdue"},
+	}, http.MethodPost)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusCreated, code)
+	secret, _ := m["secret"].(string)
+	require.NotEmpty(t, secret)
+	hookID := fmt.Sprint(m["id"])
+	t.Cleanup(func() {
+		requestAs(Token, "api/webhooks?id="+hookID, nil, http.MethodDelete)
+	})
+
+	code, m, err = requestAs(Token, "api/webhooks?id="+hookID, nil, http.MethodGet)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, true, m["active"])
+	assert.NotContains(t, m, "secret")
+
+	call := nextWebhookCall(t, calls, "overdue", overdue)
+	verifyWebhookSignature(t, secret, call)
+	payload, _ := call.event["task"].(map[string]any)
+	assert.Equal(t, overdueDate, payload["date"])
+
+	id := addTask(t, task{date: time.Now().Format(`20060102`), title: "Веб-хуки: новая"})
+	call = nextWebhookCall(t, calls, "created", id)
+	verifyWebhookSignature(t, secret, call)
+
+	code, _, err = requestAs(Token, "api/task/done?id="+id+"&version=1", nil, http.MethodPost)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, code)
+	nextWebhookCall(t, calls, "completed", id)
+
+	retried := addTask(t, task{date: time.Now().Format(`20060102`), title: "Веб-хуки: повтор"})
+	first := nextWebhookCall(t, calls, "created", retried)
+	second := nextWebhookCall(t, calls, "created", retried)
+	assert.Equal(t, first.header.Get("X-Webhook-Delivery"), second.header.Get("X-Webhook-Delivery"))
+	assert.Equal(t, first.body, second.body)
+	verifyWebhookSignature(t, secret, second)
+
+	var delivery map[string]any
+	require.Eventually(t, func() bool {
+		for _, item := range webhookDeliveries(t, hookID) {
+			if item["task_id"] == retried && item["status"] == "delivered" {
+				delivery = item
+				return true
+			}
+		}
+		return false
+	}, 5*time.Second, 100*time.Millisecond)
+	assert.Equal(t, float64(2), delivery["attempts"])
+	assert.Equal(t, float64(http.StatusNoContent), delivery["response_code"])
+	assert.NotEmpty(t, delivery["delivered"])
+
+	code, m, err = requestAs(Token, "api/webhooks/redeliver?id="+fmt.Sprint(delivery["id"]), nil, http.MethodPost)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusAccepted, code)
+	assert.Equal(t, "pending", m["status"])
+	redelivered := nextWebhookCall(t, calls, "created", retried)
+	assert.Equal(t, first.body, redelivered.body)
+	assert.Equal(t, fmt.Sprint(m["id"]), redelivered.header.Get("X-Webhook-Delivery"))
+
+	code, m, err = requestAs(Token, "api/webhooks?id="+hookID, map[string]any{"events": []string{"deleted"}, "rotate_secret": true}, http.MethodPut)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, code)
+	rotated, _ := m["secret"].(string)
+	assert.NotEmpty(t, rotated)
+	assert.NotEqual(t, secret, rotated)
+
+	code, _, err = requestAs(Token, "api/task?id="+retried+"&version="+currentVersion(t, retried), nil, http.MethodDelete)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, code)
+	verifyWebhookSignature(t, rotated, nextWebhookCall(t, calls, "deleted", retried))
+
+	code, _, err = requestAs(Token, "api/webhooks?id="+hookID, nil, http.MethodDelete)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, code)
+
+	for _, path := range []string{"api/webhooks?id=" + hookID, "api/webhooks/deliveries?id=" + hookID} {
+		code, m, err = requestAs(Token, path, nil, http.MethodGet)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, code)
+		assert.True(t, strings.Contains(fmt.Sprint(m["error"]), "веб-хук"), m)
+	}
+}
+
+func TestWebhookPrivateAddresses(t *testing.T) {
+	webhooks := service.NewWebhookService(nil, nil, 1, time.Second)
+	for _, hookURL := range []string{
+		"http://127.0.0.1:8080/hook",
+		"http://localhost/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://10.0.0.5/hook",
+		"http://192.168.1.1/hook",
+		"http://[::1]/hook",
+		"http://0.0.0.0/hook",
+	} {
+		_, err := webhooks.Create(1, hookURL, []string{"created"})
+		assert.ErrorIs(t, err, service.ErrWebhookURL, hookURL)
+	}
+
+	receiver, _ := webhookReceiver(t, func(webhookCall) bool { return false })
+	_, err := webhooks.Client.Post(receiver.URL, "application/json", nil)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "not allowed")
+	}
+
+	webhooks.AllowPrivate = true
+	resp, err := webhooks.Client.Post(receiver.URL, "application/json", nil)
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+		assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	}
+}
+
+func TestWebhooksDeletedWithUser(t *testing.T) {
+	receiver, _ := webhookReceiver(t, func(webhookCall) bool { return false })
+	user, userID := signUp(t, "webhook-user-"+time.Now().Format("150405.000000"))
+
+	code, m, err := requestAs(user, "api/webhooks", map[string]any{"url": receiver.URL, "events": []string{"created"}}, http.MethodPost)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusCreated, code)
+	hookID := fmt.Sprint(m["id"])
+	newTask := map[string]any{"title": "Веб-хуки: удалённый пользователь", "date": time.Now().Format(`20060102`)}
+	code, _, err = requestAs(user, "api/task", newTask, http.MethodPost)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, code)
+
+	code, _, err = requestAs(Token, "api/users?id="+userID, nil, http.MethodDelete)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, code)
+
+	db := openDB(t)
+	var hooks int
+	require.NoError(t, db.Get(&hooks, `SELECT COUNT(*) FROM webhooks WHERE id = ?`, hookID))
+	assert.Zero(t, hooks)
+	assert.Eventually(t, func() bool {
+		var deliveries int
+		return db.Get(&deliveries, `SELECT COUNT(*) FROM webhook_deliveries WHERE webhook_id = ?`, hookID) == nil && deliveries == 0
+	}, 5*time.Second, 100*time.Millisecond)
+}
@@ -0,0 +1,269 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/antonkazachenko/go-todo-list-api/models"
+)
+
+const DefaultLanguage = "ru"
+
+var supportedLanguages = []string{"ru", "en", "fr"}
+
+// errorMessage describes one error the API can return. Entries are keyed by
+// an id: the part before "/" is the stable code sent to clients, the rest
+// tells apart messages that share a code. If fieldArg is set the first
+// argument is the field path.
+type errorMessage struct {
+	field    string
+	fieldArg bool
+	ru       string
+	en       string
+	fr       string
+}
+
+var errorMessages = map[string]errorMessage{
+	"database_error":       {ru: "ошибка запроса к базе данных", en: "database query failed", fr: "échec de la requête à la base de données"},
+	"not_found":            {ru: "ресурс не найден", en: "resource not found", fr: "ressource introuvable"},
+	"method_not_allowed":   {ru: "метод не поддерживается", en: "method not allowed", fr: "méthode non autorisée"},
+	"invalid_json":         {ru: "ошибка декодирования JSON", en: "invalid JSON body", fr: "corps JSON invalide"},
+	"invalid_body":         {ru: "ошибка чтения тела запроса", en: "failed to read the request body", fr: "impossible de lire le corps de la requête"},
+	"internal_error":       {ru: "ошибка при сериализации ответа", en: "failed to serialize the response", fr: "échec de la sérialisation de la réponse"},
+	"internal_error/write": {ru: "ошибка записи ответа", en: "failed to write the response", fr: "échec de l'écriture de la réponse"},
+	"token_error":          {ru: "ошибка создания токена", en: "failed to create a token", fr: "échec de la création du jeton"},
+	"required/id":          {field: "id", ru: "не передан идентификатор", en: "id is missing", fr: "l'identifiant est manquant"},
+
+	"task_not_found":                {field: "id", ru: "задача с указанным id не найдена", en: "no task with this id", fr: "aucune tâche avec cet id"},
+	"list_not_found":                {field: "owner", ru: "список задач не найден", en: "task list not found", fr: "liste de tâches introuvable"},
+	"permission_denied":             {ru: "недостаточно прав для этого действия", en: "you are not allowed to do this", fr: "vous n'avez pas les droits pour cette action"},
+	"version_conflict":              {field: "version", ru: "задача была изменена с момента последнего чтения", en: "the task has changed since it was last read", fr: "la tâche a été modifiée depuis sa dernière lecture"},
+	"precondition_required":         {field: "version", ru: "требуется заголовок If-Match или поле version", en: "an If-Match header or a version field is required", fr: "un en-tête If-Match ou un champ version est requis"},
+	"batch_aborted":                 {ru: "операция не выполнена: пакет отменён из-за ошибки в другой операции", en: "not run: the batch was rolled back because another operation failed", fr: "non exécutée : le lot a été annulé à cause de l'échec d'une autre opération"},
+	"required/operations":           {field: "operations", ru: "пакет не содержит операций", en: "the batch contains no operations", fr: "le lot ne contient aucune opération"},
+	"events_unavailable":            {ru: "поток событий недоступен", en: "the event stream is unavailable", fr: "le flux d'événements est indisponible"},
+	"too_many_subscriptions":        {field: "ref", ru: "слишком много подписок, максимум %d", en: "too many subscriptions, the maximum is %d", fr: "trop d'abonnements, le maximum est %d"},
+	"too_many_changes":              {field: "changes", ru: "слишком много изменений, максимум %d", en: "too many changes, the maximum is %d", fr: "trop de modifications, le maximum est %d"},
+	"filter_required":               {ru: "не задан фильтр: укажите search, from или to", en: "no filter given: set search, from or to", fr: "aucun filtre indiqué : précisez search, from ou to"},
+	"too_many_operations":           {field: "operations", ru: "слишком много операций в пакете, максимум %d", en: "too many operations in the batch, the maximum is %d", fr: "trop d'opérations dans le lot, le maximum est %d"},
+	"required/title":                {field: "title", ru: "отсутствует описание задачи", en: "the task has no description", fr: "la tâche n'a pas de description"},
+	"invalid_repeat":                {field: "repeat", ru: "недопустимый символ", en: "invalid repeat rule type", fr: "type de règle de répétition invalide"},
+	"invalid_repeat/no_interval":    {field: "repeat", ru: "не указан интервал в днях", en: "the interval in days is missing", fr: "l'intervalle en jours est manquant"},
+	"invalid_repeat/rule":           {field: "repeat", ru: "некорректно указано правило repeat", en: "invalid repeat rule", fr: "règle repeat invalide"},
+	"invalid_repeat/interval":       {field: "repeat", ru: "превышен максимально допустимый интервал", en: "the interval exceeds the maximum", fr: "l'intervalle dépasse le maximum autorisé"},
+	"invalid_repeat/weekday":        {field: "repeat", ru: "ошибка конвертации значения дня недели", en: "invalid day of the week", fr: "jour de la semaine invalide"},
+	"invalid_repeat/weekday_range":  {field: "repeat", ru: "недопустимое значение дня недели", en: "day of the week out of range", fr: "jour de la semaine hors limites"},
+	"invalid_repeat/monthday":       {field: "repeat", ru: "ошибка конвертации значения дня месяца", en: "invalid day of the month", fr: "jour du mois invalide"},
+	"invalid_repeat/monthday_range": {field: "repeat", ru: "недопустимое значение дня месяца", en: "day of the month out of range", fr: "jour du mois hors limites"},
+	"invalid_repeat/month":          {field: "repeat", ru: "ошибка конвертации значения месяца", en: "invalid month", fr: "mois invalide"},
+	"invalid_repeat/month_range":    {field: "repeat", ru: "недопустимое значение месяца", en: "month out of range", fr: "mois hors limites"},
+	"invalid_rec":                   {field: "rec", ru: "некорректно указано правило rec", en: "invalid rec rule", fr: "règle rec invalide"},
+	"invalid_rec/monthly":           {field: "rec", ru: "правило rec в месяцах поддерживается только как 1m вместе с due", en: "a monthly rec rule is only supported as 1m together with due", fr: "une règle rec mensuelle n'est prise en charge que sous la forme 1m avec due"},
+	"invalid_rec/yearly":            {field: "rec", ru: "правило rec в годах поддерживается только как 1y", en: "a yearly rec rule is only supported as 1y", fr: "une règle rec annuelle n'est prise en charge que sous la forme 1y"},
+
+	"auth_not_configured":       {ru: "пароль не установлен", en: "no password is configured", fr: "aucun mot de passe n'est configuré"},
+	"auth_disabled":             {ru: "аутентификация отключена", en: "authentication is disabled", fr: "l'authentification est désactivée"},
+	"authentication_required":   {ru: "требуется аутентификация", en: "authentication required", fr: "authentification requise"},
+	"admin_required":            {ru: "требуются права администратора", en: "administrator access required", fr: "droits d'administrateur requis"},
+	"invalid_credentials":       {ru: "неверный логин или пароль", en: "invalid login or password", fr: "identifiant ou mot de passe incorrect"},
+	"invalid_password":          {field: "password", ru: "неверный пароль", en: "wrong password", fr: "mot de passe incorrect"},
+	"invalid_token":             {ru: "недействительный токен", en: "invalid token", fr: "jeton invalide"},
+	"unknown_signing_key":       {ru: "неизвестный ключ подписи", en: "unknown signing key", fr: "clé de signature inconnue"},
+	"session_revoked":           {ru: "сессия отозвана", en: "the session has been revoked", fr: "la session a été révoquée"},
+	"refresh_token_reused":      {ru: "refresh-токен уже использован, сессия отозвана", en: "the refresh token was already used, the session has been revoked", fr: "le jeton de rafraîchissement a déjà été utilisé, la session a été révoquée"},
+	"session_not_found":         {field: "id", ru: "сессия с указанным id не найдена", en: "no session with this id", fr: "aucune session avec cet id"},
+	"token_not_found":           {field: "id", ru: "токен с указанным id не найден", en: "no token with this id", fr: "aucun jeton avec cet id"},
+	"signup_disabled":           {ru: "регистрация отключена", en: "sign-up is disabled", fr: "l'inscription est désactivée"},
+	"login_taken":               {field: "login", ru: "пользователь с таким логином уже существует", en: "a user with this login already exists", fr: "un utilisateur avec cet identifiant existe déjà"},
+	"user_not_found":            {field: "id", ru: "пользователь с указанным id не найден", en: "no user with this id", fr: "aucun utilisateur avec cet id"},
+	"user_not_found/login":      {field: "login", ru: "пользователь с указанным логином не найден", en: "no user with this login", fr: "aucun utilisateur avec cet identifiant"},
+	"last_admin":                {field: "role", ru: "нельзя удалить или понизить последнего администратора", en: "the last administrator cannot be deleted or demoted", fr: "le dernier administrateur ne peut pas être supprimé ni rétrogradé"},
+	"password_too_short":        {field: "password", ru: "пароль должен содержать не менее 8 символов", en: "the password must be at least 8 characters long", fr: "le mot de passe doit contenir au moins 8 caractères"},
+	"password_too_long":         {field: "password", ru: "пароль должен содержать не более 72 байт", en: "the password must be at most 72 bytes long", fr: "le mot de passe doit contenir au plus 72 octets"},
+	"invalid_login":             {field: "login", ru: "логин должен содержать от 3 до 64 латинских букв, цифр или символов ._@-", en: "the login must be 3 to 64 Latin letters, digits or ._@- characters", fr: "l'identifiant doit contenir de 3 à 64 lettres latines, chiffres ou caractères ._@-"},
+	"too_many_attempts":         {ru: "слишком много попыток входа, повторите позже", en: "too many sign-in attempts, try again later", fr: "trop de tentatives de connexion, réessayez plus tard"},
+	"password_session_required": {ru: "действие доступно только после входа по паролю", en: "this action requires a password sign-in", fr: "cette action nécessite une connexion par mot de passe"},
+	"invalid_token_name":        {field: "name", ru: "название токена должно содержать от 1 до 64 символов", en: "the token name must be 1 to 64 characters long", fr: "le nom du jeton doit contenir de 1 à 64 caractères"},
+	"invalid_expiry/token":      {field: "expires_in_days", ru: "срок действия токена не может быть отрицательным", en: "the token lifetime cannot be negative", fr: "la durée de validité du jeton ne peut pas être négative"},
+	"scope_denied":              {field: "scopes", ru: "недостаточно прав для запрошенных scopes", en: "you are not allowed to grant the requested scopes", fr: "vous n'avez pas les droits pour les scopes demandés"},
+	"invalid_code":              {field: "code", ru: "неверный код подтверждения", en: "invalid verification code", fr: "code de vérification invalide"},
+	"totp_not_enrolled":         {ru: "двухфакторная аутентификация не настроена", en: "two-factor authentication is not set up", fr: "l'authentification à deux facteurs n'est pas configurée"},
+	"totp_already_enabled":      {ru: "двухфакторная аутентификация уже включена", en: "two-factor authentication is already enabled", fr: "l'authentification à deux facteurs est déjà activée"},
+	"oidc_disabled":             {ru: "вход через OIDC не настроен", en: "OIDC sign-in is not configured", fr: "la connexion OIDC n'est pas configurée"},
+	"oidc_invalid_state":        {field: "state", ru: "недействительный или просроченный запрос входа через OIDC", en: "invalid or expired OIDC sign-in request", fr: "demande de connexion OIDC invalide ou expirée"},
+	"oidc_invalid_token":        {ru: "недействительный ID-токен провайдера OIDC", en: "invalid ID token from the OIDC provider", fr: "jeton d'identité du fournisseur OIDC invalide"},
+	"oidc_failed":               {ru: "ошибка входа через OIDC", en: "OIDC sign-in failed", fr: "échec de la connexion OIDC"},
+	"oidc_unavailable":          {ru: "провайдер OIDC недоступен", en: "the OIDC provider is unavailable", fr: "le fournisseur OIDC est indisponible"},
+	"oidc_denied":               {ru: "провайдер OIDC отклонил вход: %s", en: "the OIDC provider denied the sign-in: %s", fr: "le fournisseur OIDC a refusé la connexion : %s"},
+
+	"share_not_found":     {field: "id", ru: "доступ с указанным id не найден", en: "no share with this id", fr: "aucun partage avec cet id"},
+	"share_exists":        {field: "login", ru: "пользователь уже имеет доступ к этому списку", en: "the user already has access to this list", fr: "l'utilisateur a déjà accès à cette liste"},
+	"share_with_owner":    {field: "login", ru: "нельзя открыть доступ владельцу списка", en: "a list cannot be shared with its owner", fr: "une liste ne peut pas être partagée avec son propriétaire"},
+	"own_share":           {field: "id", ru: "нельзя изменить собственный доступ к списку", en: "you cannot change your own access to the list", fr: "vous ne pouvez pas modifier votre propre accès à la liste"},
+	"filter_too_wide":     {field: "filter", ru: "filter не может быть шире вашего собственного", en: "the filter cannot be wider than your own", fr: "le filtre ne peut pas être plus large que le vôtre"},
+	"too_long/filter":     {field: "filter", ru: "filter не должен превышать 255 символов", en: "filter must be at most 255 characters long", fr: "filter doit contenir au plus 255 caractères"},
+	"link_not_found":      {ru: "ссылка не найдена или больше не действует", en: "the link does not exist or has expired", fr: "le lien n'existe pas ou a expiré"},
+	"password_required":   {field: "password", ru: "для просмотра ссылки требуется пароль", en: "this link requires a password", fr: "ce lien nécessite un mot de passe"},
+	"link_not_found/id":   {field: "id", ru: "ссылка с указанным id не найдена", en: "no link with this id", fr: "aucun lien avec cet id"},
+	"webhook_not_found":   {field: "id", ru: "веб-хук с указанным id не найден", en: "no webhook with this id", fr: "aucun webhook avec cet id"},
+	"delivery_not_found":  {field: "id", ru: "доставка с указанным id не найдена", en: "no delivery with this id", fr: "aucune livraison avec cet id"},
+	"too_many_webhooks":   {ru: "слишком много веб-хуков, максимум %d", en: "too many webhooks, the maximum is %d", fr: "trop de webhooks, le maximum est %d"},
+	"invalid_expiry/link": {field: "expires_in_days", ru: "срок действия ссылки не может быть отрицательным", en: "the link lifetime cannot be negative", fr: "la durée de validité du lien ne peut pas être négative"},
+	"too_long/link_name":  {field: "name", ru: "название ссылки не должно превышать 64 символа", en: "the link name must be at most 64 characters long", fr: "le nom du lien doit contenir au plus 64 caractères"},
+
+	"invalid_csv/empty":         {ru: "пустой файл CSV", en: "the CSV file is empty", fr: "le fichier CSV est vide"},
+	"invalid_csv/header":        {ru: "ошибка чтения заголовка CSV", en: "failed to read the CSV header", fr: "impossible de lire l'en-tête CSV"},
+	"invalid_csv/row":           {ru: "ошибка разбора строки CSV", en: "failed to parse a CSV row", fr: "impossible d'analyser une ligne CSV"},
+	"required/title_column":     {field: "title", ru: "в заголовке CSV отсутствует столбец title", en: "the CSV header has no title column", fr: "l'en-tête CSV n'a pas de colonne title"},
+	"invalid_value/delimiter":   {field: "delimiter", ru: "недопустимый разделитель", en: "invalid delimiter", fr: "séparateur invalide"},
+	"too_many_rows":             {ru: "слишком много строк, максимум %d", en: "too many rows, the maximum is %d", fr: "trop de lignes, le maximum est %d"},
+	"payload_too_large":         {ru: "тело запроса превышает %d байт", en: "the request body exceeds %d bytes", fr: "le corps de la requête dépasse %d octets"},
+	"invalid_column":            {field: "columns", ru: "недопустимое поле в columns: %s", en: "invalid field in columns: %s", fr: "champ invalide dans columns : %s"},
+	"duplicate_column":          {field: "columns", ru: "поле указано в columns дважды: %s", en: "field listed twice in columns: %s", fr: "champ indiqué deux fois dans columns : %s"},
+	"invalid_backup/read":       {ru: "ошибка чтения резервной копии", en: "failed to read the backup", fr: "impossible de lire la sauvegarde"},
+	"invalid_backup/format":     {ru: "неизвестный формат резервной копии", en: "unknown backup format", fr: "format de sauvegarde inconnu"},
+	"invalid_backup/version":    {ru: "неподдерживаемая версия резервной копии: %d", en: "unsupported backup version: %d", fr: "version de sauvegarde non prise en charge : %d"},
+	"invalid_backup/no_tables":  {ru: "резервная копия не содержит таблиц", en: "the backup contains no tables", fr: "la sauvegarde ne contient aucune table"},
+	"invalid_backup/table":      {ru: "неизвестная таблица в резервной копии: %s", en: "unknown table in the backup: %s", fr: "table inconnue dans la sauvegarde : %s"},
+	"invalid_backup/column":     {ru: "недопустимый столбец %s в таблице %s", en: "invalid column %s in table %s", fr: "colonne %s invalide dans la table %s"},
+	"invalid_backup/no_columns": {ru: "не указаны столбцы таблицы %s", en: "no columns given for table %s", fr: "aucune colonne indiquée pour la table %s"},
+	"invalid_backup/row":        {ru: "таблица %s, строка %d: неверное количество значений", en: "table %s, row %d: wrong number of values", fr: "table %s, ligne %d : nombre de valeurs incorrect"},
+	"import_failed":             {ru: "найдены ошибки в строках: %d", en: "errors found in rows: %d", fr: "erreurs trouvées dans les lignes : %d"},
+	"restore_failed":            {ru: "ошибка восстановления резервной копии", en: "failed to restore the backup", fr: "échec de la restauration de la sauvegarde"},
+
+	"insufficient_scope":       {ru: "у токена нет scope %s", en: "the token lacks the %s scope", fr: "le jeton n'a pas le scope %s"},
+	"unknown_field":            {fieldArg: true, ru: "недопустимое поле %s", en: "unknown field %s", fr: "champ inconnu %s"},
+	"invalid_number":           {fieldArg: true, ru: "%s должен быть числом", en: "%s must be a number", fr: "%s doit être un nombre"},
+	"required":                 {fieldArg: true, ru: "отсутствует обязательное поле %s", en: "the required field %s is missing", fr: "le champ obligatoire %s est manquant"},
+	"invalid_value":            {fieldArg: true, ru: "недопустимое значение %s", en: "invalid value of %s", fr: "valeur de %s invalide"},
+	"invalid_format":           {fieldArg: true, ru: "недопустимый формат %s", en: "invalid format of %s", fr: "format de %s invalide"},
+	"invalid_format/parameter": {fieldArg: true, ru: "неправильный формат параметра %s", en: "invalid format of the %s parameter", fr: "format du paramètre %s invalide"},
+	"too_long":                 {fieldArg: true, ru: "значение поля %s слишком длинное", en: "the value of %s is too long", fr: "la valeur de %s est trop longue"},
+	"not_unique":               {fieldArg: true, ru: "значение поля %s уже используется", en: "the value of %s is already taken", fr: "la valeur de %s est déjà utilisée"},
+	"constraint_violation":     {fieldArg: true, ru: "значение поля %s нарушает ограничения", en: "the value of %s violates a constraint", fr: "la valeur de %s enfreint une contrainte"},
+}
+
+// Error is an error reported to API clients. Its code and field are fixed
+// where it is created; the message is rendered from the catalog in the
+// language of the request.
+type Error struct {
+	Code  string
+	Field string
+
+	id   string
+	args []interface{}
+}
+
+// NewError creates the catalog error with the given id. The arguments fill
+// the verbs of the message. An unknown id is a programming error.
+func NewError(id string, args ...interface{}) *Error {
+	entry, ok := errorMessages[id]
+	if !ok {
+		panic("utils: unknown error " + id)
+	}
+
+	code, _, _ := strings.Cut(id, "/")
+	field := entry.field
+	if entry.fieldArg && len(args) > 0 {
+		field = fmt.Sprint(args[0])
+	}
+	return &Error{Code: code, Field: field, id: id, args: args}
+}
+
+// Error returns the message in the default language.
+func (e *Error) Error() string {
+	return e.Message(DefaultLanguage)
+}
+
+// Message returns the message in the given language, falling back to
+// Russian.
+func (e *Error) Message(language string) string {
+	entry := errorMessages[e.id]
+	text := entry.ru
+	switch language {
+	case "en":
+		text = entry.en
+	case "fr":
+		text = entry.fr
+	}
+	if len(e.args) == 0 {
+		return text
+	}
+	return fmt.Sprintf(text, e.args...)
+}
+
+var statusCodes = map[int]string{
+	http.StatusBadRequest:            "bad_request",
+	http.StatusUnauthorized:          "unauthorized",
+	http.StatusForbidden:             "forbidden",
+	http.StatusNotFound:              "not_found",
+	http.StatusMethodNotAllowed:      "method_not_allowed",
+	http.StatusConflict:              "conflict",
+	http.StatusPreconditionFailed:    "precondition_failed",
+	http.StatusPreconditionRequired:  "precondition_required",
+	http.StatusRequestEntityTooLarge: "payload_too_large",
+	http.StatusTooManyRequests:       "too_many_requests",
+	http.StatusBadGateway:            "bad_gateway",
+	http.StatusServiceUnavailable:    "service_unavailable",
+}
+
+// LocalizeError builds the response body for err. Catalog errors carry
+// their code and field and are translated; any other error keeps its text
+// and gets a code derived from status.
+func LocalizeError(err error, status int, language string) models.ErrorResponse {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return models.ErrorResponse{Error: apiErr.Message(language), Code: apiErr.Code, Field: apiErr.Field}
+	}
+
+	code, ok := statusCodes[status]
+	if !ok {
+		code = "internal_error"
+	}
+	return models.ErrorResponse{Error: err.Error(), Code: code}
+}
+
+// Language picks the best supported language from Accept-Language, falling
+// back to Russian.
+func Language(req *http.Request) string {
+	if req == nil {
+		return DefaultLanguage
+	}
+
+	type candidate struct {
+		language string
+		quality  float64
+		order    int
+	}
+	var candidates []candidate
+	for i, part := range strings.Split(req.Header.Get("Accept-Language"), ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		quality := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			quality = parsed
+		}
+
+		base, _, _ := strings.Cut(strings.ToLower(tag), "-")
+		for _, supported := range supportedLanguages {
+			if base == supported && quality > 0 {
+				candidates = append(candidates, candidate{supported, quality, i})
+			}
+		}
+	}
+
+	if len(candidates) == 0 {
+		return DefaultLanguage
+	}
+	sort.SliceStable(candidates, func(a, b int) bool {
+		return candidates[a].quality > candidates[b].quality
+	})
+	return candidates[0].language
+}
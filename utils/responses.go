@@ -3,16 +3,30 @@ package utils
 import (
 	"encoding/json"
 	"net/http"
-
-	"github.com/antonkazachenko/go-todo-list-api/models"
 )
 
-func SendErrorResponse(res http.ResponseWriter, errorMessage string, statusCode int) {
-	resp := models.ErrorResponse{Error: errorMessage}
+// SendErrorResponse writes err as a JSON error. Catalog errors are translated
+// according to the Accept-Language of req, which may be nil.
+func SendErrorResponse(res http.ResponseWriter, req *http.Request, err error, statusCode int) {
+	SendConflictResponse(res, req, err, statusCode, nil)
+}
+
+// SendConflictResponse is SendErrorResponse with the current server state of
+// the resource attached as "current".
+func SendConflictResponse(res http.ResponseWriter, req *http.Request, sendErr error, statusCode int, current interface{}) {
+	language := Language(req)
+	resp := LocalizeError(sendErr, statusCode, language)
+	resp.Current = current
 	respBytes, err := json.Marshal(resp)
 	if err != nil {
-		http.Error(res, "ошибка при сериализации ответа", http.StatusInternalServerError)
+		http.Error(res, NewError("internal_error").Message(language), http.StatusInternalServerError)
 		return
 	}
-	http.Error(res, string(respBytes), statusCode)
+
+	res.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	res.Header().Set("Content-Language", language)
+	res.Header().Add("Vary", "Accept-Language")
+	res.Header().Set("X-Content-Type-Options", "nosniff")
+	res.WriteHeader(statusCode)
+	res.Write(respBytes)
 }
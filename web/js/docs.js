@@ -0,0 +1,166 @@
+(function () {
+    var root = document.getElementById('docs');
+
+    function el(tag, attrs, children) {
+        var node = document.createElement(tag);
+        Object.keys(attrs || {}).forEach(function (key) {
+            node.setAttribute(key, attrs[key]);
+        });
+        (children || []).forEach(function (child) {
+            node.appendChild(typeof child === 'string' ? document.createTextNode(child) : child);
+        });
+        return node;
+    }
+
+    // example turns a schema into a sample value, following $ref into components.
+    function example(spec, schema, seen) {
+        seen = seen || {};
+        if (!schema) {
+            return null;
+        }
+        if (schema.allOf) {
+            return example(spec, schema.allOf[0], seen);
+        }
+        if (schema.$ref) {
+            var name = schema.$ref.split('/').pop();
+            if (seen[name]) {
+                return {};
+            }
+            seen[name] = true;
+            var value = example(spec, spec.components.schemas[name], seen);
+            delete seen[name];
+            return value;
+        }
+        switch (schema.type) {
+        case 'object':
+            var result = {};
+            Object.keys(schema.properties || {}).forEach(function (key) {
+                result[key] = example(spec, schema.properties[key], seen);
+            });
+            return result;
+        case 'array':
+            return [example(spec, schema.items, seen)];
+        case 'integer':
+        case 'number':
+            return 0;
+        case 'boolean':
+            return false;
+        case 'string':
+            return schema.format === 'date-time' ? '2024-01-01T00:00:00Z' : '';
+        }
+        return null;
+    }
+
+    function describe(spec, content) {
+        var type = Object.keys(content)[0];
+        var sample = example(spec, content[type].schema);
+        return { type: type, text: typeof sample === 'string' ? sample : JSON.stringify(sample, null, 2) };
+    }
+
+    function operation(spec, path, method, op) {
+        var body = el('div');
+        if (op.description) {
+            body.appendChild(el('p', { 'class': 'note' }, [op.description]));
+        }
+        if (!op.security || op.security.length === 0) {
+            body.appendChild(el('p', { 'class': 'note' }, ['No authentication required.']));
+        }
+
+        var inputs = {};
+        (op.parameters || []).forEach(function (param) {
+            var input = el('input', { name: param.name });
+            inputs[param.name] = { input: input, where: param.in };
+            body.appendChild(el('label', {}, [param.name + (param.in === 'path' ? ' (path) ' : ' '), input]));
+        });
+
+        var request = null;
+        var requestType = '';
+        if (op.requestBody) {
+            var described = describe(spec, op.requestBody.content);
+            requestType = described.type;
+            request = el('textarea', {}, [described.text]);
+            body.appendChild(el('p', {}, ['Request body (' + requestType + ')']));
+            body.appendChild(request);
+        }
+
+        Object.keys(op.responses).forEach(function (status) {
+            var response = op.responses[status];
+            body.appendChild(el('p', {}, [status + ': ' + response.description]));
+            if (response.content) {
+                body.appendChild(el('pre', {}, [describe(spec, response.content).text]));
+            }
+        });
+
+        var output = el('pre');
+        var send = el('button', { type: 'button' }, ['Send']);
+        send.addEventListener('click', function () {
+            var url = path;
+            var query = new URLSearchParams();
+            Object.keys(inputs).forEach(function (name) {
+                var value = inputs[name].input.value;
+                if (inputs[name].where === 'path') {
+                    url = url.replace('{' + name + '}', encodeURIComponent(value));
+                } else if (value !== '') {
+                    query.set(name, value);
+                }
+            });
+            if (query.toString()) {
+                url += '?' + query.toString();
+            }
+            var init = { method: method.toUpperCase(), credentials: 'same-origin' };
+            if (request) {
+                init.headers = { 'Content-Type': requestType };
+                init.body = request.value;
+            }
+            output.textContent = '…';
+            fetch(url, init).then(function (resp) {
+                return resp.text().then(function (text) {
+                    output.textContent = resp.status + ' ' + resp.statusText + '\n\n' + text;
+                });
+            }).catch(function (err) {
+                output.textContent = String(err);
+            });
+        });
+        body.appendChild(send);
+        body.appendChild(output);
+
+        return el('details', {}, [
+            el('summary', {}, [
+                el('span', { 'class': 'method ' + method }, [method.toUpperCase()]),
+                el('span', { 'class': 'path' }, [path]),
+                ' ' + op.summary
+            ]),
+            body
+        ]);
+    }
+
+    function render(spec) {
+        var tags = {};
+        var order = [];
+        Object.keys(spec.paths).sort().forEach(function (path) {
+            Object.keys(spec.paths[path]).forEach(function (method) {
+                var op = spec.paths[path][method];
+                var tag = op.tags[0];
+                if (!tags[tag]) {
+                    tags[tag] = [];
+                    order.push(tag);
+                }
+                tags[tag].push(operation(spec, path, method, op));
+            });
+        });
+
+        root.textContent = '';
+        order.forEach(function (tag) {
+            root.appendChild(el('h2', {}, [tag]));
+            tags[tag].forEach(function (node) {
+                root.appendChild(node);
+            });
+        });
+    }
+
+    fetch('/api/openapi.json').then(function (resp) {
+        return resp.json();
+    }).then(render).catch(function (err) {
+        root.textContent = 'Could not load the specification: ' + err;
+    });
+})();
@@ -0,0 +1,75 @@
+(function () {
+    // The task list reloads when the server reports a change on the event
+    // stream. Changes that come in quick succession cause one reload.
+    if (!window.EventSource) {
+        return;
+    }
+
+    var eventTypes = ['created', 'updated', 'deleted', 'completed', 'reset'];
+    var reloadDelay = 200;
+    var reopenDelay = 3000;
+    var source = null;
+    var lastEventId = '';
+    var timer = null;
+
+    function changed() {
+        clearTimeout(timer);
+        timer = setTimeout(function () {
+            window.dispatchEvent(new Event('tasks:changed'));
+        }, reloadDelay);
+    }
+
+    function received(event) {
+        if (event.lastEventId) {
+            lastEventId = event.lastEventId;
+        }
+        changed();
+    }
+
+    // open resumes after the last event seen, so that nothing is missed
+    // between two streams.
+    function open() {
+        var url = '/api/events';
+        if (lastEventId) {
+            url += '?last_event_id=' + encodeURIComponent(lastEventId);
+        }
+        source = new EventSource(url);
+        eventTypes.forEach(function (type) {
+            source.addEventListener(type, received);
+        });
+        source.onerror = failed;
+    }
+
+    // The browser reconnects on its own after a dropped connection. When the
+    // server refuses the stream, usually because the access token expired or
+    // the session was revoked, the token is refreshed and the stream opened
+    // again; without a session the page is left as it is.
+    function failed() {
+        if (source.readyState !== EventSource.CLOSED) {
+            return;
+        }
+        source = null;
+        var refresh = window.session ? window.session.refresh() : Promise.resolve(false);
+        refresh.then(function (ok) {
+            if (ok) {
+                setTimeout(open, reopenDelay);
+            }
+        });
+    }
+
+    window.addEventListener('pagehide', function () {
+        clearTimeout(timer);
+        if (source) {
+            source.close();
+            source = null;
+        }
+    });
+    window.addEventListener('pageshow', function (event) {
+        if (event.persisted && !source) {
+            open();
+            changed();
+        }
+    });
+
+    open();
+})();
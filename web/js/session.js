@@ -0,0 +1,116 @@
+(function () {
+    // The access token in the cookie is short-lived. The refresh token from
+    // sign-in is kept here and exchanged for a new pair when a request is
+    // rejected with 401, after which the request is sent again once.
+    var storageKey = 'refresh_token';
+    var cookieHours = 8;
+    var pending = null;
+
+    function setToken(token) {
+        var expires = new Date(Date.now() + cookieHours * 3600 * 1000);
+        document.cookie = 'token=' + token + ';expires=' + expires.toUTCString() + ';path=/';
+    }
+
+    function remember(data) {
+        if (data && data.token && data.refresh_token) {
+            localStorage.setItem(storageKey, data.refresh_token);
+        }
+    }
+
+    function forget() {
+        localStorage.removeItem(storageKey);
+    }
+
+    // refresh resolves to true when a new access token was stored. Requests
+    // that fail at the same time share one refresh.
+    function refresh() {
+        var refreshToken = localStorage.getItem(storageKey);
+        if (!refreshToken) {
+            return Promise.resolve(false);
+        }
+        if (!pending) {
+            pending = axios.post('/api/refresh', { refresh_token: refreshToken }, { skipRefresh: true })
+                .then(function (response) {
+                    setToken(response.data.token);
+                    remember(response.data);
+                    return true;
+                })
+                .catch(function () {
+                    forget();
+                    return false;
+                })
+                .then(function (ok) {
+                    pending = null;
+                    return ok;
+                });
+        }
+        return pending;
+    }
+
+    function isAuthCall(url) {
+        return /(^|\/)api\/(signin|refresh|signout)/.test(url || '');
+    }
+
+    axios.interceptors.response.use(function (response) {
+        if (isAuthCall(response.config.url)) {
+            if (/signout/.test(response.config.url)) {
+                forget();
+            } else {
+                remember(response.data);
+            }
+        }
+        return response;
+    }, function (error) {
+        var config = error.config || {};
+        var status = error.response && error.response.status;
+        if (status !== 401 || config.skipRefresh || config.retried || isAuthCall(config.url)) {
+            return Promise.reject(error);
+        }
+        return refresh().then(function (ok) {
+            if (!ok) {
+                return Promise.reject(error);
+            }
+            config.retried = true;
+            return axios.request(config);
+        });
+    });
+
+    function signedIn(data) {
+        remember(data);
+        setToken(data.token);
+        location.replace('/');
+    }
+
+    // A sign-in through an identity provider comes back to the login page
+    // with the answer /api/signin would give in the URL fragment: the tokens,
+    // or an MFA token to exchange together with a TOTP or recovery code.
+    function finishRedirect() {
+        var params = new URLSearchParams(location.hash.replace(/^#/, ''));
+        var mfaToken = params.get('mfa_token');
+        if (!params.get('token') && !mfaToken) {
+            return;
+        }
+        history.replaceState(null, '', location.pathname + location.search);
+
+        if (!mfaToken) {
+            signedIn({ token: params.get('token'), refresh_token: params.get('refresh_token') });
+            return;
+        }
+        var code = window.prompt('Введите код из приложения-аутентификатора или код восстановления');
+        if (!code) {
+            return;
+        }
+        axios.post('/api/signin/2fa', { mfa_token: mfaToken, code: code.trim() })
+            .then(function (response) {
+                signedIn(response.data);
+            })
+            .catch(function (error) {
+                var data = error.response && error.response.data;
+                window.alert(data && data.error ? data.error : 'Ошибка входа');
+            });
+    }
+
+    finishRedirect();
+
+    window.session = { refresh: refresh };
+})();
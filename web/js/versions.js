@@ -0,0 +1,74 @@
+(function () {
+    // The server only changes, deletes or completes a task when the request
+    // names the version it was read at. The versions of the tasks the page
+    // has loaded are kept here and sent as If-Match with every write, so a
+    // task changed elsewhere in the meantime is not overwritten.
+    var versions = {};
+    var taskWrite = /(^|\/)api\/task(\/done)?(\?|$)/;
+
+    function remember(id, version) {
+        if (id && version) {
+            versions[id] = String(version);
+        }
+    }
+
+    function taskID(config) {
+        var query = (config.url || '').split('?')[1] || '';
+        var id = new URLSearchParams(query).get('id');
+        var data = config.data;
+        if (!id && typeof data === 'string') {
+            // Responses carry the request body as it was sent.
+            try {
+                data = JSON.parse(data);
+            } catch (e) {
+                data = null;
+            }
+        }
+        if (!id && data && typeof data === 'object') {
+            id = data.id;
+        }
+        return id ? String(id) : '';
+    }
+
+    function isTaskWrite(config) {
+        return (config.method || 'get').toLowerCase() !== 'get' && taskWrite.test(config.url || '');
+    }
+
+    axios.interceptors.request.use(function (config) {
+        if (!isTaskWrite(config)) {
+            return config;
+        }
+        var version = versions[taskID(config)];
+        config.headers = config.headers || {};
+        if (version && !config.headers['If-Match']) {
+            config.headers['If-Match'] = '"' + version + '"';
+        }
+        return config;
+    });
+
+    axios.interceptors.response.use(function (response) {
+        var data = response.data || {};
+        if (Array.isArray(data.tasks)) {
+            data.tasks.forEach(function (task) {
+                remember(task.id, task.version);
+            });
+        } else if (data.id && data.version) {
+            remember(data.id, data.version);
+        }
+
+        var etag = response.headers && response.headers.etag;
+        if (etag && isTaskWrite(response.config)) {
+            remember(taskID(response.config), etag.replace(/^W\//, '').replace(/"/g, ''));
+        }
+        return response;
+    }, function (error) {
+        // A conflict answers with the task as it is now: its version is
+        // taken and the list reloaded, so the next attempt can succeed.
+        var data = error.response && error.response.data;
+        if (error.response && error.response.status === 412 && data && data.current) {
+            remember(data.current.id, data.current.version);
+            window.dispatchEvent(new Event('tasks:changed'));
+        }
+        return Promise.reject(error);
+    });
+})();